@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"opcmss/internal/coverage"
+	"opcmss/internal/model"
+	"opcmss/internal/opcua"
+)
+
+func runBrowse(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("browse")
+	readValues := fs.Bool("values", false, "read the current OPC UA value of each listed tag")
+	server := fs.Bool("server", false, "walk the OPC UA server's address space instead of listing the tag map")
+	root := fs.String("root", opcua.DefaultBrowseRoot, "NodeID to start walking the address space from, with -server")
+	depth := fs.Int("depth", 0, "references to follow from -root, 1 lists only its own variables, 0 for no limit, with -server")
+	standard := fs.Bool("standard", false, "also walk the server's own nodes in namespace 0, with -server")
+	unmapped := fs.Bool("unmapped", false, "only list variables that are not in the tag map, with -server")
+	format := fs.String("format", "", "export the variables found as csv, tsv or json instead of a table, with -server")
+	output := fs.String("o", "", "output file for -format (default stdout)")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	pattern := fs.Arg(0)
+
+	if *server {
+		return s.browseServer(ctx, pattern, serverBrowse{
+			options:  opcua.BrowseOptions{Root: *root, MaxDepth: *depth, Standard: *standard},
+			values:   *readValues,
+			unmapped: *unmapped,
+			format:   *format,
+			output:   *output,
+		})
+	}
+
+	var indexes []int
+	for i, tag := range s.opcTags {
+		if matchName(pattern, tag.Name) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	var read func(i int) string
+	if *readValues {
+		opcClient, err := s.dialOPC()
+		if err != nil {
+			return err
+		}
+		defer opcClient.Close()
+
+		read = func(i int) string {
+			value, err := opcClient.ReadTag(ctx, s.opcTags[i])
+			if err != nil {
+				return fmt.Sprintf("error: %v", err)
+			}
+			return fmt.Sprintf("%v", value)
+		}
+	}
+
+	for _, i := range indexes {
+		opcTag := s.opcTags[i]
+		modbusTag := s.modbusTags[i]
+		line := fmt.Sprintf("%-40s %-5s %-16s %6d  %s", opcTag.Name, opcTag.DataType, modbusTag.RegisterType, modbusTag.ModbusAddress, opcTag.NodeID)
+		if read != nil {
+			line += "  = " + read(i)
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\n%d of %d tags\n", len(indexes), len(s.opcTags))
+	return nil
+}
+
+// serverBrowse holds the flags of browse -server
+type serverBrowse struct {
+	options  opcua.BrowseOptions
+	values   bool
+	unmapped bool
+	format   string
+	output   string
+}
+
+// browsedVariable is a variable found on the server, named like the tag map
+// would name it
+type browsedVariable struct {
+	Name  string `json:"name"` // tag name for the configured prefix, else the browse path
+	InMap bool   `json:"in_map"`
+	opcua.Variable
+	Access string `json:"access"`
+	Value  string `json:"value,omitempty"`
+}
+
+// browseServer walks the OPC UA address space and lists the variables whose
+// name matches pattern, marking the ones the tag map does not have
+func (s *session) browseServer(ctx context.Context, pattern string, opts serverBrowse) error {
+	switch opts.format {
+	case "", "csv", "tsv", "json":
+	default:
+		return fmt.Errorf("unknown export format %q", opts.format)
+	}
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	variables, err := opcClient.BrowseVariables(ctx, opts.options)
+	if err != nil {
+		return err
+	}
+
+	mapped := make(map[string]bool, len(s.opcTags))
+	for _, tag := range s.opcTags {
+		mapped[tag.NodeID] = true
+	}
+
+	var rows []browsedVariable
+	for _, v := range variables {
+		name := coverage.Name(v, s.cfg.OPCNamespaceIndex, s.cfg.OPCNodePrefix)
+		row := browsedVariable{Name: name, InMap: mapped[v.NodeID], Variable: v, Access: v.Access()}
+		if !matchName(pattern, row.Name) || (opts.unmapped && row.InMap) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if opts.values {
+		readBrowsedValues(ctx, opcClient, rows)
+	}
+
+	if opts.format != "" {
+		return writeBrowsed(rows, opts.format, opts.output)
+	}
+
+	var missing int
+	for _, row := range rows {
+		where := "in map"
+		if !row.InMap {
+			where = "OPC only"
+			missing++
+		}
+		line := fmt.Sprintf("%-40s %-6s %-8s %-2s %-8s %s", row.Name, row.IECType, row.DataType, row.Access, where, row.NodeID)
+		if row.Description != "" {
+			line += "  # " + row.Description
+		}
+		if opts.values {
+			line += "  = " + row.Value
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\n%d variables, %d not in the tag map (%d variables below %s)\n", len(rows), missing, len(variables), opts.options.Root)
+	return nil
+}
+
+// readBrowsedValues reads the readable variables the client has a type for
+func readBrowsedValues(ctx context.Context, opcClient *opcua.Client, rows []browsedVariable) {
+	var indexes []int
+	for i, row := range rows {
+		if row.IECType != "" && row.Readable() {
+			indexes = append(indexes, i)
+		}
+	}
+
+	tags := make([]model.OPCTag, len(indexes))
+	for i, index := range indexes {
+		tags[i] = rows[index].Tag(rows[index].Name)
+	}
+	for i, v := range opcClient.ReadTags(ctx, tags) {
+		if v.Err != nil {
+			rows[indexes[i]].Value = fmt.Sprintf("error: %v", v.Err)
+		} else {
+			rows[indexes[i]].Value = fmt.Sprintf("%v", v.Value)
+		}
+	}
+}
+
+// writeBrowsed exports the browsed variables to file, or stdout without one
+func writeBrowsed(rows []browsedVariable, format, file string) error {
+	var w io.Writer = os.Stdout
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "csv":
+		return writeBrowsedCSV(w, rows, ',')
+	case "tsv":
+		return writeBrowsedCSV(w, rows, '\t')
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+}
+
+func writeBrowsedCSV(w io.Writer, rows []browsedVariable, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	cw.Write([]string{"name", "node_id", "iec_type", "data_type", "access", "in_map", "path", "description", "value"})
+	for _, row := range rows {
+		cw.Write([]string{
+			row.Name,
+			row.NodeID,
+			row.IECType,
+			row.DataType,
+			row.Access,
+			strconv.FormatBool(row.InMap),
+			row.Path,
+			row.Description,
+			row.Value,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// matchName matches a tag name against a glob pattern, falling back to a
+// case-insensitive substring match when the pattern has no glob characters
+func matchName(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(pattern))
+}
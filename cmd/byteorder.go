@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"opcmss/internal/compare"
+	"opcmss/internal/modbus"
+)
+
+func runByteOrder(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("byte-order")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one tag name")
+	}
+
+	index, err := s.findTag(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opcTag := s.opcTags[index]
+	modbusTag := s.modbusTags[index]
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	modbusClient, err := s.dialModbus()
+	if err != nil {
+		return err
+	}
+	defer modbusClient.Close()
+
+	fmt.Printf("Name: %s\n", opcTag.Name)
+	fmt.Printf("Type: %s, Address: %d, Size: %d, Data type: %s\n", modbusTag.RegisterType, modbusTag.Address, modbusTag.Size, modbusTag.ResolvedDataType())
+
+	registers, err := modbusClient.ReadRawRegisters(ctx, modbusTag)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Registers: %04X\n", registers)
+
+	opcValue, opcErr := opcClient.ReadTag(ctx, opcTag)
+	if opcErr != nil {
+		fmt.Printf("OPC UA: Error: %v\n\n", opcErr)
+	} else {
+		fmt.Printf("OPC UA: %v\n\n", opcValue)
+	}
+
+	for _, v := range modbus.DecodeAllByteOrders(modbusTag, registers) {
+		if v.Err != nil {
+			fmt.Printf("  %s  error: %v\n", v.ByteOrder, v.Err)
+			continue
+		}
+		marker := " "
+		if opcErr == nil && compare.Within(compare.TagTolerance(modbusTag), opcValue, v.Value) {
+			marker = "✓"
+		}
+		fmt.Printf("%s %s  %v\n", marker, v.ByteOrder, v.Value)
+	}
+	return nil
+}
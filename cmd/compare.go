@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"opcmss/internal/compare"
+	"opcmss/internal/modbus"
+	"opcmss/internal/model"
+	"opcmss/internal/opcua"
+	"opcmss/internal/report"
+	"opcmss/internal/selector"
+	"opcmss/internal/throttle"
+)
+
+func runCompare(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("compare")
+	selFlags := selector.BindFlags(fs)
+	reports := bindReportFlag(fs)
+	cmpFlags := bindCompareFlags(fs)
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	opts, err := selFlags.Options(s.cfg.TagsToCompare)
+	if err != nil {
+		return err
+	}
+	indexes, err := selector.Select(s.modbusTags, opts)
+	if err != nil {
+		return err
+	}
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	modbusClient, err := s.dialModbus()
+	if err != nil {
+		return err
+	}
+	defer modbusClient.Close()
+
+	totalTags := len(s.modbusTags)
+	fmt.Printf("Total tags available: %d\n", totalTags)
+	fmt.Printf("Comparing %d tags (%s):\n\n", len(indexes), describeSelection(opts))
+
+	startedAt := time.Now()
+	results, compared := s.compareTags(ctx, opcClient, modbusClient, indexes, cmpFlags.options())
+
+	for i, result := range results {
+		modbusTag := s.modbusTags[compared[i]]
+
+		fmt.Printf("=== Tag %d/%d (Index: %d) ===\n", i+1, len(indexes), compared[i])
+		fmt.Printf("Name: %s\n", result.Name)
+		fmt.Printf("Type: %s, Address: %d, Size: %d\n", modbusTag.RegisterType, modbusTag.Address, modbusTag.Size)
+		printResult(result)
+		fmt.Println()
+	}
+
+	summary := compare.Summarize(results, startedAt)
+	fmt.Printf("Summary: %d match, %d transient, %d differ, %d OPC errors, %d Modbus errors out of %d tags\n",
+		summary.Matches, summary.Transients, summary.Mismatches, summary.OPCErrors, summary.ModbusErrors, summary.Total)
+
+	stopped := len(compared) < len(indexes)
+	if err := reports.write(report.Report{Name: s.cfg.Name, Summary: summary, Results: results, Interrupted: stopped}); err != nil {
+		return err
+	}
+
+	if stopped {
+		return fmt.Errorf("interrupted after comparing %d of %d tags", len(compared), len(indexes))
+	}
+
+	if summary.Failed() {
+		return fmt.Errorf("%d of %d tags did not match", summary.Total-summary.Matches, summary.Total)
+	}
+	return nil
+}
+
+// compareOptions selects how tags are read and compared
+type compareOptions struct {
+	batch   bool // block reads of all tags up front
+	aligned bool // read both sides of a tag at the same time
+	retry   compare.RetryOptions
+}
+
+// compareFlags binds the flags that control how tags are read and compared
+type compareFlags struct {
+	batch, aligned *bool
+	retries        *int
+	retryDelay     *time.Duration
+}
+
+func bindCompareFlags(fs *flag.FlagSet) *compareFlags {
+	return &compareFlags{
+		batch:      fs.Bool("batch", true, "read Modbus tags in contiguous blocks and many OPC UA nodes per request"),
+		aligned:    fs.Bool("aligned", true, "read OPC UA and Modbus at the same time instead of one after the other"),
+		retries:    fs.Int("retries", 2, "re-read a mismatched tag this many times before reporting it"),
+		retryDelay: fs.Duration("retry-delay", 200*time.Millisecond, "pause before each re-read of a mismatched tag"),
+	}
+}
+
+func (f *compareFlags) options() compareOptions {
+	return compareOptions{
+		batch:   *f.batch,
+		aligned: *f.aligned,
+		retry:   compare.RetryOptions{Retries: *f.retries, Delay: *f.retryDelay},
+	}
+}
+
+// compareTags compares the tags at indexes, either with batch reads of all
+// tags up front or tag by tag on the configured workers, and re-checks the
+// mismatches. Once ctx is done, the tags whose reads were cut short are left
+// out: the results are for the returned indexes only.
+func (s *session) compareTags(ctx context.Context, opcClient *opcua.Client, modbusClient *modbus.Client, indexes []int, opts compareOptions) ([]compare.Result, []int) {
+	var opcReadings, modbusReadings []compare.Reading
+	switch {
+	case opts.batch && opts.aligned:
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opcReadings = s.readOPCBatch(ctx, opcClient, indexes)
+		}()
+		modbusReadings = s.readModbusBatch(ctx, modbusClient, indexes)
+		wg.Wait()
+	case opts.batch:
+		opcReadings = s.readOPCBatch(ctx, opcClient, indexes)
+		modbusReadings = s.readModbusBatch(ctx, modbusClient, indexes)
+	default:
+		opcReadings, modbusReadings = s.readEach(ctx, opcClient, modbusClient, indexes, opts.aligned)
+	}
+
+	var results []compare.Result
+	var compared []int
+	var opcTags []model.OPCTag
+	var modbusTags []model.ModbusTag
+	for i, index := range indexes {
+		opc, mb := opcReadings[i], modbusReadings[i]
+		if interrupted(ctx, opc.Err) || interrupted(ctx, mb.Err) {
+			continue
+		}
+
+		opcTag, modbusTag := s.opcTags[index], s.modbusTags[index]
+		results = append(results, compare.Build(opcTag, modbusTag, opc, mb))
+		compared = append(compared, index)
+		opcTags = append(opcTags, opcTag)
+		modbusTags = append(modbusTags, modbusTag)
+	}
+
+	compare.RecheckAll(ctx, results, opcTags, modbusTags, func(i int) (func() compare.Reading, func() compare.Reading) {
+		return s.opcReader(ctx, opcClient, compared[i]), s.modbusReader(ctx, modbusClient, compared[i])
+	}, opts.retry)
+	return results, compared
+}
+
+// readEach reads the tags at indexes one by one, as many at the same time as
+// the busier protocol has workers. The clients hold each protocol to its own
+// number of workers. Aligned reads take both sides of a tag together.
+func (s *session) readEach(ctx context.Context, opcClient *opcua.Client, modbusClient *modbus.Client, indexes []int, aligned bool) (opcReadings, modbusReadings []compare.Reading) {
+	opcReadings = make([]compare.Reading, len(indexes))
+	modbusReadings = make([]compare.Reading, len(indexes))
+
+	throttle.Each(len(indexes), max(s.cfg.OPCWorkers, s.cfg.ModbusWorkers), func(i int) {
+		readOPC, readModbus := s.opcReader(ctx, opcClient, indexes[i]), s.modbusReader(ctx, modbusClient, indexes[i])
+		if aligned {
+			opcReadings[i], modbusReadings[i] = compare.ReadPair(readOPC, readModbus)
+		} else {
+			opcReadings[i], modbusReadings[i] = readOPC(), readModbus()
+		}
+	})
+	return opcReadings, modbusReadings
+}
+
+// opcReader returns a function reading a single tag with the server's timestamps
+func (s *session) opcReader(ctx context.Context, client *opcua.Client, index int) func() compare.Reading {
+	return func() compare.Reading {
+		return opcReading(client.ReadTagValue(ctx, s.opcTags[index]))
+	}
+}
+
+// modbusReader returns a function reading a single tag over Modbus
+func (s *session) modbusReader(ctx context.Context, client *modbus.Client, index int) func() compare.Reading {
+	return func() compare.Reading {
+		return compare.Read(func() (any, error) { return client.ReadTag(ctx, s.modbusTags[index]) })
+	}
+}
+
+func opcReading(v opcua.TagValue) compare.Reading {
+	return compare.Reading{
+		Value:           v.Value,
+		Err:             v.Err,
+		ReadAt:          v.ReadAt,
+		Latency:         v.Latency,
+		SourceTimestamp: v.SourceTimestamp,
+		ServerTimestamp: v.ServerTimestamp,
+	}
+}
+
+// readOPCBatch reads the selected tags with as few OPC UA requests as possible
+func (s *session) readOPCBatch(ctx context.Context, client *opcua.Client, indexes []int) []compare.Reading {
+	tags := make([]model.OPCTag, len(indexes))
+	for i, index := range indexes {
+		tags[i] = s.opcTags[index]
+	}
+
+	values := client.ReadTags(ctx, tags)
+	readings := make([]compare.Reading, len(values))
+	for i, v := range values {
+		readings[i] = opcReading(v)
+	}
+	return readings
+}
+
+// readModbusBatch reads the selected tags with block reads
+func (s *session) readModbusBatch(ctx context.Context, client *modbus.Client, indexes []int) []compare.Reading {
+	tags := make([]model.ModbusTag, len(indexes))
+	for i, index := range indexes {
+		tags[i] = s.modbusTags[index]
+	}
+
+	values := client.ReadTags(ctx, tags, s.batchOptions())
+	readings := make([]compare.Reading, len(values))
+	for i, v := range values {
+		readings[i] = compare.Reading{Value: v.Value, Raw: v.Raw, Err: v.Err, ReadAt: v.ReadAt, Latency: v.Latency}
+	}
+	return readings
+}
+
+// printResult prints a comparison result in the console format
+func printResult(result compare.Result) {
+	if result.OPCError != "" {
+		fmt.Printf("OPC UA: Error: %s\n", result.OPCError)
+	} else {
+		fmt.Printf("OPC UA: Value: %v (type: %T)\n", result.OPCValue, result.OPCValue)
+	}
+
+	if result.ModbusError != "" {
+		fmt.Printf("Modbus: Error: %s\n", result.ModbusError)
+	} else {
+		fmt.Printf("Modbus: Value: %v (raw: %v)\n", result.ModbusValue, result.ModbusRaw)
+	}
+
+	switch result.Status {
+	case compare.StatusMatch:
+		fmt.Printf("✓ Values match!\n")
+	case compare.StatusTransient:
+		fmt.Printf("✓ Values match after %d reads (value was changing)\n", result.Attempts)
+	case compare.StatusMismatch:
+		if result.Attempts > 1 {
+			fmt.Printf("✗ Values differ! (tolerance %s, %d reads)\n", result.Tolerance, result.Attempts)
+		} else {
+			fmt.Printf("✗ Values differ! (tolerance %s)\n", result.Tolerance)
+		}
+	}
+}
+
+// describeSelection summarises how the compared tags were picked
+func describeSelection(opts selector.Options) string {
+	switch opts.Mode {
+	case selector.ModeEven:
+		return fmt.Sprintf("%d evenly spaced", opts.Count)
+	case selector.ModeRandom:
+		return fmt.Sprintf("%d random, seed %d", opts.Count, opts.Seed)
+	case selector.ModeNth:
+		return fmt.Sprintf("every %d starting at %d", opts.Every, opts.Offset)
+	default:
+		return "all matching tags"
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"opcmss/internal/coverage"
+	"opcmss/internal/opcua"
+)
+
+func runCoverage(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("coverage")
+	root := fs.String("root", opcua.DefaultBrowseRoot, "NodeID to start walking the address space from")
+	depth := fs.Int("depth", 0, "references to follow from -root, 0 for no limit")
+	standard := fs.Bool("standard", false, "also walk the server's own nodes in namespace 0")
+	all := fs.Bool("all", false, "also list the tags found in both, not only the differences")
+	format := fs.String("format", "", "export the report as csv, tsv or json instead of a table")
+	output := fs.String("o", "", "output file for -format (default stdout)")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "", "csv", "tsv", "json":
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	variables, err := opcClient.BrowseVariables(ctx, opcua.BrowseOptions{Root: *root, MaxDepth: *depth, Standard: *standard})
+	if err != nil {
+		return err
+	}
+
+	r := coverage.Build(s.modbusTags, s.opcTags, variables, s.cfg.OPCNamespaceIndex, s.cfg.OPCNodePrefix)
+	r.Name = s.cfg.Name
+
+	if *format != "" {
+		if err := writeCoverage(r, *format, *output); err != nil {
+			return err
+		}
+	} else {
+		printCoverage(r, *all)
+	}
+
+	if !r.Summary.Complete() {
+		return fmt.Errorf("%d of %d tags have no node of their type", r.Summary.ModbusOnly+r.Summary.TypeMismatch, r.Summary.TagsInMap)
+	}
+	return nil
+}
+
+// printCoverage prints the entries that differ, or all of them, and a summary
+func printCoverage(r coverage.Report, all bool) {
+	for _, e := range r.Entries {
+		if e.Status == coverage.StatusBoth && !all {
+			continue
+		}
+		switch e.Status {
+		case coverage.StatusOPCOnly:
+			fmt.Printf("%-13s %-40s %-8s %s\n", e.Status, e.Name, e.OPCDataType, e.NodeID)
+		case coverage.StatusModbusOnly:
+			fmt.Printf("%-13s %-40s %-8s %s (%s %d)\n", e.Status, e.Name, e.DataType, e.NodeID, e.RegisterType, e.ModbusAddress)
+		default:
+			fmt.Printf("%-13s %-40s %-8s %s (OPC UA %s)\n", e.Status, e.Name, e.DataType, e.NodeID, e.OPCDataType)
+		}
+	}
+
+	s := r.Summary
+	fmt.Printf("\nCoverage: %d in both, %d type mismatches, %d Modbus only, %d OPC only (%d tags in the map, %d variables on the server)\n",
+		s.Both, s.TypeMismatch, s.ModbusOnly, s.OPCOnly, s.TagsInMap, s.VariablesSeen)
+}
+
+// writeCoverage exports the report to file, or stdout without one
+func writeCoverage(r coverage.Report, format, file string) error {
+	var w io.Writer = os.Stdout
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "csv":
+		return coverage.WriteCSV(w, r, ',')
+	case "tsv":
+		return coverage.WriteCSV(w, r, '\t')
+	default:
+		return coverage.WriteJSON(w, r)
+	}
+}
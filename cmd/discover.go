@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"opcmss/internal/opcua"
+)
+
+func runDiscover(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("discover")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Discovery needs no tag map, only the connection settings
+	cfg, err := flags.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// The timeout was checked by config.Validate
+	if timeout, _ := time.ParseDuration(cfg.OPCTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	endpoints, err := opcua.GetEndpoints(ctx, cfg.OPCEndpoint)
+	if err != nil {
+		return err
+	}
+
+	security := opcSecurity(cfg)
+	prefs := security.Preferences()
+	selected, selectErr := opcua.SelectEndpoint(endpoints, prefs)
+
+	fmt.Printf("%d endpoints at %s:\n\n", len(endpoints), cfg.OPCEndpoint)
+	fmt.Printf("  %-5s %-24s %-15s %-32s %s\n", "Level", "Policy", "Mode", "Logins", "URL")
+	for _, e := range endpoints {
+		marker := " "
+		if selectErr == nil && e.SecurityPolicy == selected.SecurityPolicy && e.SecurityMode == selected.SecurityMode {
+			marker = "*"
+		}
+		policy := opcua.SecurityPolicyName(e.SecurityPolicy)
+		if !e.Supported() {
+			policy += " (unsupported)"
+		}
+		fmt.Printf("%s %5d %-24s %-15s %-32s %s\n", marker, e.SecurityLevel, policy, e.SecurityMode, loginNames(e), e.URL)
+	}
+	fmt.Println()
+
+	if selectErr != nil {
+		return selectErr
+	}
+	fmt.Printf("Selected %s/%s (login: %s)\n", opcua.SecurityPolicyName(selected.SecurityPolicy), selected.SecurityMode, prefs.UserToken)
+	return nil
+}
+
+func loginNames(e opcua.Endpoint) string {
+	names := make([]string, len(e.UserTokens))
+	for i, t := range e.UserTokens {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ",")
+}
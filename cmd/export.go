@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// exportRow is a single tag of the exported map
+type exportRow struct {
+	Name          string `json:"name"`
+	RegisterType  string `json:"register_type"`
+	Address       uint16 `json:"address"`
+	ModbusAddress uint32 `json:"modbus_address"`
+	Size          uint16 `json:"size"`
+	NodeID        string `json:"node_id"`
+	DataType      string `json:"data_type"`
+}
+
+func runExport(_ context.Context, args []string) error {
+	fs, flags := newFlagSet("export")
+	format := fs.String("format", "csv", "output format: csv, tsv or json")
+	output := fs.String("o", "", "output file (default stdout)")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]exportRow, len(s.modbusTags))
+	for i, modbusTag := range s.modbusTags {
+		rows[i] = exportRow{
+			Name:          modbusTag.Name,
+			RegisterType:  modbusTag.RegisterType,
+			Address:       modbusTag.Address,
+			ModbusAddress: modbusTag.ModbusAddress,
+			Size:          modbusTag.Size,
+			NodeID:        s.opcTags[i].NodeID,
+			DataType:      s.opcTags[i].DataType,
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *format {
+	case "csv":
+		return writeExportCSV(w, rows, ',')
+	case "tsv":
+		return writeExportCSV(w, rows, '\t')
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+}
+
+func writeExportCSV(w io.Writer, rows []exportRow, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	cw.Write([]string{"name", "register_type", "address", "modbus_address", "size", "node_id", "data_type"})
+	for _, row := range rows {
+		cw.Write([]string{
+			row.Name,
+			row.RegisterType,
+			strconv.Itoa(int(row.Address)),
+			strconv.FormatUint(uint64(row.ModbusAddress), 10),
+			strconv.Itoa(int(row.Size)),
+			row.NodeID,
+			row.DataType,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -1,216 +1,288 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"opcmss/internal/compare"
+	"opcmss/internal/config"
 	"opcmss/internal/converter"
 	"opcmss/internal/modbus"
+	"opcmss/internal/model"
 	"opcmss/internal/opcua"
 	"opcmss/internal/parser"
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
 )
 
-const (
-	// OPC UA configuration constants
-	OPC_NAMESPACE_INDEX = 4
-	OPC_NODE_PREFIX     = "|var|NEXTO PLC.Z.O83."
-	OPC_ENDPOINT        = "opc.tcp://172.29.48.69:4840"
-
-	MODBUS_ENDPOINT  = "172.29.48.69:502"
-	MODBUS_TAGS_FILE = "/home/maimus/GoProjects/OPCvsModSymSrv/cmd/example_tags.tsv"
+// command is a single opcmss subcommand
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
 
-	// Comparison settings
-	TAGS_TO_COMPARE = 20
-)
+var commands []command
+
+func init() {
+	commands = []command{
+		{"compare", "", "compare OPC UA and Modbus values for a set of tags", runCompare},
+		{"watch", "", "compare tags repeatedly and alert on mismatches that persist", runWatch},
+		{"read", "<tag>", "read a single tag from both servers", runRead},
+		{"write", "<tag> <value>", "write a value to a tag via OPC UA or Modbus", runWrite},
+		{"roundtrip", "", "write test values on one protocol, read them on the other and restore the originals", runRoundtrip},
+		{"byte-order", "<tag>", "show a register tag decoded with every byte order next to the OPC UA value", runByteOrder},
+		{"discover", "", "list the OPC UA server's endpoints and the one that would be used", runDiscover},
+		{"browse", "[pattern]", "list tags from the tag map, or with -server the variables on the OPC UA server", runBrowse},
+		{"coverage", "", "check which tags of the map have a node of their type on the OPC UA server, and which nodes have no tag", runCoverage},
+		{"validate-tags", "", "check the tag file for inconsistencies", runValidateTags},
+		{"export", "", "export the converted tag map", runExport},
+		{"simulate", "", "serve the tag map from in-process Modbus TCP and OPC UA simulators", runSimulate},
+	}
+}
 
 func main() {
-	// Parse Modbus tags from TSV using the proper TSV parser
-	modbusTags, err := parser.ParseTagsTSV(MODBUS_TAGS_FILE)
-	if err != nil {
-		log.Fatal("Failed to parse TSV:", err)
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	// SIGINT and SIGTERM cancel the command's context, so it can stop its
+	// reads and write what it has. A second signal ends the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	name := flag.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(ctx, flag.Args()[1:]); err != nil {
+				log.Fatalf("%s: %v", name, err)
+			}
+			return
+		}
 	}
 
-	// Convert Modbus tags to OPC tags using constants
-	opcTags := converter.ConvertAllModbusToOPC(modbusTags, OPC_NAMESPACE_INDEX, OPC_NODE_PREFIX)
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-	// Create OPC UA client
-	client, err := opcua.NewClient(OPC_ENDPOINT)
-	if err != nil {
-		log.Fatal("Failed to create OPC client:", err)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: opcmss <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.name, cmd.summary)
 	}
-	defer client.Close()
+	fmt.Fprintf(os.Stderr, "\nRun 'opcmss <command> -h' for the flags of a command.\n")
+}
 
-	// Create Modbus client
-	modbusClient, err := modbus.NewClient(MODBUS_ENDPOINT)
-	if err != nil {
-		log.Fatal("Failed to create Modbus client:", err)
+// newFlagSet creates a flag set for a subcommand with the shared configuration flags
+func newFlagSet(name string) (*flag.FlagSet, *config.Flags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	flags := config.BindFlags(fs)
+	fs.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(os.Stderr, "Usage: opcmss %s [flags] %s\n\n%s\n\nFlags:\n", name, cmd.args, cmd.summary)
+			}
+		}
+		fs.PrintDefaults()
 	}
-	defer modbusClient.Close()
+	return fs, flags
+}
 
-	totalTags := len(modbusTags)
-	fmt.Printf("Total tags available: %d\n", totalTags)
-	fmt.Printf("Comparing %d evenly spaced tags:\n\n", TAGS_TO_COMPARE)
+// session holds the resolved configuration and the parsed tag map
+type session struct {
+	cfg        config.Config
+	modbusTags []model.ModbusTag
+	opcTags    []model.OPCTag
+	limiter    *throttle.Limiter // shared by both clients, nil for no limit
+}
 
-	// Calculate step size for even spacing
-	step := totalTags / TAGS_TO_COMPARE
-	if step < 1 {
-		step = 1
+// newSession parses the command line, loads the configuration and the tag map
+func newSession(fs *flag.FlagSet, flags *config.Flags, args []string) (*session, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
 
-	successCount := 0
-	errorCount := 0
+	cfg, err := flags.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
 
-	// Compare evenly spaced tags
-	for i := 0; i < TAGS_TO_COMPARE && i*step < totalTags; i++ {
-		index := i * step
+	// Parse Modbus tags from TSV using the proper TSV parser
+	modbusTags, err := parser.ParseTagsTSV(cfg.TagsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TSV: %w", err)
+	}
 
-		// Ensure we don't exceed array bounds
-		if index >= totalTags {
-			break
-		}
+	rules, err := toleranceRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rules.Apply(modbusTags)
 
-		opcTag := opcTags[index]
-		modbusTag := modbusTags[index]
-
-		fmt.Printf("=== Tag %d/%d (Index: %d) ===\n", i+1, TAGS_TO_COMPARE, index)
-		fmt.Printf("Name: %s\n", opcTag.Name)
-		fmt.Printf("Type: %s, Address: %d, Size: %d\n", modbusTag.RegisterType, modbusTag.Address, modbusTag.Size)
-
-		// Read from OPC UA
-		fmt.Printf("OPC UA: ")
-		opcValue, opcErr := client.ReadTag(opcTag)
-		if opcErr != nil {
-			fmt.Printf("Error: %v\n", opcErr)
-			errorCount++
-		} else {
-			fmt.Printf("Value: %v (type: %T)\n", opcValue, opcValue)
-			successCount++
-		}
+	// Convert Modbus tags to OPC tags using the configured namespace and prefix
+	opcTags := converter.ConvertAllModbusToOPC(modbusTags, cfg.OPCNamespaceIndex, cfg.OPCNodePrefix)
 
-		// Read from Modbus
-		fmt.Printf("Modbus: ")
-		modbusValue, modbusErr := modbusClient.ReadTag(modbusTag)
-		if modbusErr != nil {
-			fmt.Printf("Error: %v\n", modbusErr)
-		} else {
-			fmt.Printf("Value: %v\n", modbusClient.FormatTagValue(modbusTag, modbusValue))
-		}
+	return &session{
+		cfg:        cfg,
+		modbusTags: modbusTags,
+		opcTags:    opcTags,
+		limiter:    throttle.NewLimiter(cfg.RequestRate),
+	}, nil
+}
 
-		// Compare values if both successful
-		if opcErr == nil && modbusErr == nil {
-			if compareValues(opcValue, modbusValue, modbusTag.RegisterType) {
-				fmt.Printf("✓ Values match!\n")
-			} else {
-				fmt.Printf("✗ Values differ!\n")
-			}
-		}
+// toleranceRules builds the comparison tolerances from the configuration
+func toleranceRules(cfg config.Config) (compare.ToleranceRules, error) {
+	rules := compare.DefaultToleranceRules()
 
-		fmt.Println()
+	var err error
+	if rules.Default, err = model.ParseTolerance(cfg.Tolerance); err != nil {
+		return rules, fmt.Errorf("tolerance: %w", err)
 	}
 
-	fmt.Printf("Summary: %d successful OPC reads, %d errors out of %d attempts\n",
-		successCount, errorCount, TAGS_TO_COMPARE)
-}
-
-// compareValues compares OPC and Modbus values based on the register type
-func compareValues(opcValue, modbusValue any, registerType string) bool {
-	switch registerType {
-	case "Coil", "DiscreteInput":
-		opcBool, opcOk := opcValue.(bool)
-		modbusBool, modbusOk := modbusValue.(bool)
-		return opcOk && modbusOk && opcBool == modbusBool
-
-	case "HoldingRegister", "InputRegister":
-		// Handle different numeric types
-		opcFloat := convertToFloat64(opcValue)
-		// Extract the best value from modbus (handles map[string]any for 2-register values)
-		bestModbusValue := extractBestModbusValue(modbusValue)
-		modbusFloat := convertToFloat64(bestModbusValue)
-
-		if opcFloat == nil || modbusFloat == nil {
-			return false
+	rules.Types = make(map[string]model.Tolerance, len(cfg.TypeTolerances))
+	for dataType, text := range cfg.TypeTolerances {
+		dt, err := model.ParseDataType(dataType)
+		if err != nil {
+			return rules, fmt.Errorf("type_tolerances: %w", err)
 		}
-
-		// Allow small floating point differences
-		diff := *opcFloat - *modbusFloat
-		if diff < 0 {
-			diff = -diff
+		if rules.Types[dt], err = model.ParseTolerance(text); err != nil {
+			return rules, fmt.Errorf("type_tolerances[%s]: %w", dataType, err)
 		}
-		return diff < 0.001
+	}
 
-	default:
-		return fmt.Sprintf("%v", opcValue) == fmt.Sprintf("%v", modbusValue)
+	for _, p := range cfg.PatternTolerances {
+		tolerance, err := model.ParseTolerance(p.Tolerance)
+		if err != nil {
+			return rules, fmt.Errorf("pattern_tolerances[%s]: %w", p.Pattern, err)
+		}
+		rules.Patterns = append(rules.Patterns, compare.PatternTolerance{Pattern: p.Pattern, Tolerance: tolerance})
 	}
+	return rules, nil
 }
 
-// extractBestModbusValue extracts the most reasonable value from modbus reading
-func extractBestModbusValue(value any) any {
-	// Handle modbus map results (2-register values)
-	if mapVal, ok := value.(map[string]any); ok {
-		var f float32
-		var i int32
-		var hasFloat, hasInt bool
-
-		if fVal, ok := mapVal["float32"].(float32); ok {
-			f = fVal
-			hasFloat = true
+// findTag returns the index of the tag with the given name
+func (s *session) findTag(name string) (int, error) {
+	for i, tag := range s.modbusTags {
+		if tag.Name == name {
+			return i, nil
 		}
-		if iVal, ok := mapVal["int32"].(int32); ok {
-			i = iVal
-			hasInt = true
+	}
+	for i, tag := range s.modbusTags {
+		if strings.EqualFold(tag.Name, name) {
+			return i, nil
 		}
+	}
+	return -1, fmt.Errorf("tag %q not found in %s", name, s.cfg.TagsFile)
+}
 
-		if !hasFloat || !hasInt {
-			return value // Return original if we can't extract both
-		}
+func (s *session) dialOPC() (*opcua.Client, error) {
+	// The timeouts were checked by config.Validate
+	timeout, _ := time.ParseDuration(s.cfg.OPCTimeout)
+	client, err := opcua.NewClient(s.cfg.OPCEndpoint,
+		opcua.WithSecurity(opcSecurity(s.cfg)),
+		opcua.WithRecovery(s.recovery("OPC UA")),
+		opcua.WithTimeout(timeout),
+		opcua.WithConcurrency(s.cfg.OPCWorkers),
+		opcua.WithRateLimit(s.limiter))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OPC client: %w", err)
+	}
+	return client, nil
+}
 
-		// Use same heuristic as modbus FormatTagValue to pick the most reasonable value
-		if f >= 0 && f < 100000 && f == float32(int(f)) {
-			// Whole number that makes sense as float - return the float
-			return f
-		}
-		if f > 0.001 && f < 1000000 {
-			// Reasonable float range - return float
-			return f
-		}
+// opcSecurity returns the configured OPC UA security and login
+func opcSecurity(cfg config.Config) opcua.Security {
+	return opcua.Security{
+		Policy:         cfg.OPCSecurityPolicy,
+		Mode:           cfg.OPCSecurityMode,
+		PreferPolicies: cfg.OPCPreferPolicies,
+		PreferModes:    cfg.OPCPreferModes,
+		CertFile:       cfg.OPCCertificate,
+		KeyFile:        cfg.OPCPrivateKey,
+		PKIDir:         cfg.OPCPKIDir,
+		TrustedCerts:   cfg.OPCTrustedCerts,
+		UserName:       cfg.OPCUserName,
+		Password:       cfg.OPCPassword,
+		UserCertFile:   cfg.OPCUserCertificate,
+		UserKeyFile:    cfg.OPCUserKey,
+	}
+}
 
-		// Prefer int32 if it's a reasonable integer and float looks unrealistic
-		if i >= 0 && i < 100000 {
-			return i
-		}
+// recovery returns the configured retries and reconnects, logging the
+// connection state changes of the protocol
+func (s *session) recovery(protocol string) retry.Recovery {
+	// Both durations were checked by config.Validate
+	backoff, _ := time.ParseDuration(s.cfg.RetryBackoff)
+	maxBackoff, _ := time.ParseDuration(s.cfg.RetryMaxBackoff)
 
-		// Default: return float32 (most common for industrial sensors)
-		return f
+	policy := retry.Policy{
+		Attempts: s.cfg.RequestRetries + 1,
+		Backoff:  retry.Backoff{Initial: backoff, Max: maxBackoff},
 	}
+	return retry.Recovery{
+		Read:      policy,
+		Write:     policy,
+		Reconnect: retry.Backoff{Initial: backoff, Max: maxBackoff},
+		OnState: func(state retry.State, err error) {
+			if err != nil {
+				log.Printf("%s connection %s: %v", protocol, state, err)
+			} else {
+				log.Printf("%s connection %s", protocol, state)
+			}
+		},
+	}
+}
 
-	// Return as-is for non-map values (single register, coils, etc.)
-	return value
+// interrupted reports whether err comes from a read that was cut short
+// because ctx was cancelled, rather than from a failed read
+func interrupted(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && errors.Is(err, context.Canceled)
 }
 
-// convertToFloat64 converts various numeric types to float64
-func convertToFloat64(value any) *float64 {
-	switch v := value.(type) {
-	case float32:
-		f := float64(v)
-		return &f
-	case float64:
-		return &v
-	case int16:
-		f := float64(v)
-		return &f
-	case int32:
-		f := float64(v)
-		return &f
-	case int:
-		f := float64(v)
-		return &f
-	case uint16:
-		f := float64(v)
-		return &f
-	case uint32:
-		f := float64(v)
-		return &f
-	default:
-		return nil
+func (s *session) batchOptions() modbus.BatchOptions {
+	return modbus.BatchOptions{
+		MaxGap:       s.cfg.ModbusMaxGap,
+		MaxRegisters: s.cfg.ModbusMaxRegisters,
+		MaxCoils:     s.cfg.ModbusMaxCoils,
+	}
+}
+
+func (s *session) dialModbus() (*modbus.Client, error) {
+	timeout, _ := time.ParseDuration(s.cfg.ModbusTimeout)
+	client, err := modbus.NewClient(s.cfg.ModbusEndpoint,
+		modbus.WithRecovery(s.recovery("Modbus")),
+		modbus.WithTimeout(timeout),
+		modbus.WithConnections(s.cfg.ModbusWorkers),
+		modbus.WithRateLimit(s.limiter),
+		modbus.WithSerial(modbus.Serial{
+			BaudRate: uint(s.cfg.ModbusBaudRate),
+			Parity:   s.cfg.ModbusParity,
+			StopBits: uint(s.cfg.ModbusStopBits),
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Modbus client: %w", err)
+	}
+	if err := client.SetByteOrder(s.cfg.ModbusByteOrder); err != nil {
+		client.Close()
+		return nil, err
 	}
+	return client, nil
 }
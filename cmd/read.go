@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"opcmss/internal/compare"
+)
+
+func runRead(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("read")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one tag name")
+	}
+
+	index, err := s.findTag(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opcTag := s.opcTags[index]
+	modbusTag := s.modbusTags[index]
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	modbusClient, err := s.dialModbus()
+	if err != nil {
+		return err
+	}
+	defer modbusClient.Close()
+
+	fmt.Printf("Name: %s\n", opcTag.Name)
+	fmt.Printf("NodeID: %s (%s)\n", opcTag.NodeID, opcTag.DataType)
+	fmt.Printf("Type: %s, Address: %d, Size: %d\n", modbusTag.RegisterType, modbusTag.Address, modbusTag.Size)
+
+	opcValue, opcErr := opcClient.ReadTag(ctx, opcTag)
+	if opcErr != nil {
+		fmt.Printf("OPC UA: Error: %v\n", opcErr)
+	} else {
+		fmt.Printf("OPC UA: Value: %v (type: %T)\n", opcValue, opcValue)
+	}
+
+	modbusValue, modbusErr := modbusClient.ReadTag(ctx, modbusTag)
+	if modbusErr != nil {
+		fmt.Printf("Modbus: Error: %v\n", modbusErr)
+	} else {
+		fmt.Printf("Modbus: Value: %v\n", modbusClient.FormatTagValue(modbusTag, modbusValue))
+	}
+
+	if opcErr != nil || modbusErr != nil {
+		return fmt.Errorf("read failed")
+	}
+	tolerance := compare.TagTolerance(modbusTag)
+	if compare.Within(tolerance, opcValue, modbusValue) {
+		fmt.Printf("✓ Values match! (tolerance %s)\n", tolerance)
+	} else {
+		fmt.Printf("✗ Values differ! (tolerance %s)\n", tolerance)
+	}
+	return nil
+}
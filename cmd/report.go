@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"opcmss/internal/report"
+)
+
+// reportTarget is a report format and the file it is written to
+type reportTarget struct {
+	format string
+	path   string
+}
+
+// reportTargets collects repeated -report flags
+type reportTargets []reportTarget
+
+func bindReportFlag(fs *flag.FlagSet) *reportTargets {
+	targets := &reportTargets{}
+	fs.Var(targets, "report", fmt.Sprintf("write a report as <format>=<file>, repeatable; formats: %s", strings.Join(report.Formats(), ", ")))
+	return targets
+}
+
+func (t *reportTargets) String() string {
+	var parts []string
+	for _, target := range *t {
+		parts = append(parts, target.format+"="+target.path)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (t *reportTargets) Set(value string) error {
+	format, path, ok := strings.Cut(value, "=")
+	if !ok || path == "" {
+		return fmt.Errorf("expected <format>=<file>, got %q", value)
+	}
+	if _, err := report.NewWriter(format); err != nil {
+		return err
+	}
+	*t = append(*t, reportTarget{format: format, path: path})
+	return nil
+}
+
+// write renders the report to every requested target, "-" meaning stdout
+func (t *reportTargets) write(r report.Report) error {
+	for _, target := range *t {
+		writer, err := report.NewWriter(target.format)
+		if err != nil {
+			return err
+		}
+
+		if target.path == "-" {
+			if err := writer.Write(os.Stdout, r); err != nil {
+				return err
+			}
+			continue
+		}
+
+		file, err := os.Create(target.path)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		if err := writer.Write(file, r); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write %s report: %w", target.format, err)
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"opcmss/internal/roundtrip"
+	"opcmss/internal/selector"
+)
+
+func runRoundtrip(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("roundtrip")
+	selFlags := selector.BindFlags(fs)
+	direction := fs.String("direction", "both", "both, opc-to-modbus or modbus-to-opc")
+	timeout := fs.Duration("timeout", roundtrip.DefaultOptions().Timeout, "how long a write may take to show up on the other protocol")
+	poll := fs.Duration("poll", roundtrip.DefaultOptions().Poll, "pause between reads while waiting for a write")
+	execute := fs.Bool("execute", false, "write to the PLC; without it only the planned writes are shown")
+	confirm := fs.String("confirm", "", "profile name (or Modbus endpoint without a profile) to confirm -execute without a prompt")
+	jsonFile := fs.String("json", "", "write the results as JSON to this file, - for stdout")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	directions, err := roundtrip.ParseDirections(*direction)
+	if err != nil {
+		return err
+	}
+
+	opts, err := selFlags.Options(s.cfg.TagsToCompare)
+	if err != nil {
+		return err
+	}
+	indexes, err := selector.Select(s.modbusTags, opts)
+	if err != nil {
+		return err
+	}
+
+	// Only coils and holding registers can be written over Modbus
+	var tags []roundtrip.Tag
+	for _, index := range indexes {
+		tag := roundtrip.Tag{OPC: s.opcTags[index], Modbus: s.modbusTags[index]}
+		if tag.Writable() {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("none of the %d selected tags is a coil or holding register", len(indexes))
+	}
+
+	if *execute {
+		if err := s.confirmWrites(*confirm, len(tags)*len(directions)); err != nil {
+			return err
+		}
+	}
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	modbusClient, err := s.dialModbus()
+	if err != nil {
+		return err
+	}
+	defer modbusClient.Close()
+
+	tester := roundtrip.Tester{
+		OPC:     opcClient,
+		Modbus:  modbusClient,
+		Options: roundtrip.Options{Timeout: *timeout, Poll: *poll},
+	}
+
+	if !*execute {
+		fmt.Printf("Dry run, planned writes for %d tags:\n\n", len(tags))
+		for _, tag := range tags {
+			for _, dir := range directions {
+				if ctx.Err() != nil {
+					return fmt.Errorf("interrupted, nothing was written")
+				}
+				step, err := tester.Plan(ctx, tag, dir)
+				if err != nil {
+					fmt.Printf("  %s (%s): %v\n", tag.OPC.Name, dir, err)
+					continue
+				}
+				fmt.Printf("  %s (%s): %v -> %v\n", step.Name, dir, step.Original, step.TestValue)
+			}
+		}
+		fmt.Printf("\nNothing was written. Re-run with -execute to write these values and restore the originals.\n")
+		return nil
+	}
+
+	var results []roundtrip.Result
+	var unrestored []roundtrip.Result
+	for i, tag := range tags {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("=== Tag %d/%d: %s (%s %d) ===\n", i+1, len(tags), tag.OPC.Name, tag.Modbus.RegisterType, tag.Modbus.Address)
+		for _, dir := range directions {
+			if ctx.Err() != nil {
+				break
+			}
+			result := tester.Run(ctx, tag, dir)
+			results = append(results, result)
+			printRoundtrip(result)
+			if !result.Restored {
+				unrestored = append(unrestored, result)
+				break // leave the tag alone once it could not be put back
+			}
+		}
+		fmt.Println()
+	}
+
+	passed := printRoundtripSummary(results)
+
+	if *jsonFile != "" {
+		if err := writeJSON(*jsonFile, results); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range unrestored {
+		fmt.Fprintf(os.Stderr, "WARNING: %s was not restored to %v: %s\n", r.Name, r.Original, r.RestoreError)
+	}
+	if len(unrestored) > 0 {
+		return fmt.Errorf("%d tags were not restored", len(unrestored))
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted after %d round trips", len(results))
+	}
+	if passed != len(results) {
+		return fmt.Errorf("%d of %d round trips failed", len(results)-passed, len(results))
+	}
+	return nil
+}
+
+// confirmWrites guards against writing to the wrong PLC. The target has to be
+// named with -confirm or typed in at the prompt.
+func (s *session) confirmWrites(confirm string, writes int) error {
+	target := s.cfg.Name
+	if target == "" {
+		target = s.cfg.ModbusEndpoint
+	}
+	if confirm == target {
+		return nil
+	}
+	if confirm != "" {
+		return fmt.Errorf("-confirm %q does not match %q", confirm, target)
+	}
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("-execute needs -confirm %q when not run from a terminal", target)
+	}
+
+	fmt.Printf("About to make %d test writes to %s (OPC UA %s, Modbus %s).\n", writes, target, s.cfg.OPCEndpoint, s.cfg.ModbusEndpoint)
+	fmt.Printf("Type %q to continue: ", target)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(line) != target {
+		return fmt.Errorf("not confirmed, nothing was written")
+	}
+	return nil
+}
+
+func printRoundtrip(r roundtrip.Result) {
+	restored := "restored"
+	if !r.Restored {
+		restored = "NOT RESTORED: " + r.RestoreError
+	}
+
+	switch r.Status {
+	case roundtrip.StatusPass:
+		fmt.Printf("✓ %s: wrote %v (was %v), seen after %s (%d reads), %s\n",
+			r.Direction, r.TestValue, r.Original, r.Latency.Round(time.Millisecond), r.Reads, restored)
+	case roundtrip.StatusFail:
+		fmt.Printf("✗ %s: wrote %v (was %v), %s (last value %v), %s\n",
+			r.Direction, r.TestValue, r.Original, r.Error, r.Observed, restored)
+	default:
+		fmt.Printf("✗ %s: %s, %s\n", r.Direction, r.Error, restored)
+	}
+}
+
+// printRoundtripSummary prints the counts and latencies and returns how many passed
+func printRoundtripSummary(results []roundtrip.Result) int {
+	var passed, failed, errors int
+	var total, slowest time.Duration
+	fastest := time.Duration(-1)
+	for _, r := range results {
+		switch r.Status {
+		case roundtrip.StatusPass:
+			passed++
+			total += r.Latency
+			slowest = max(slowest, r.Latency)
+			if fastest < 0 || r.Latency < fastest {
+				fastest = r.Latency
+			}
+		case roundtrip.StatusFail:
+			failed++
+		default:
+			errors++
+		}
+	}
+
+	fmt.Printf("Summary: %d passed, %d not visible, %d errors out of %d round trips\n", passed, failed, errors, len(results))
+	if passed > 0 {
+		fmt.Printf("Latency: min %s, avg %s, max %s\n",
+			fastest.Round(time.Millisecond), (total / time.Duration(passed)).Round(time.Millisecond), slowest.Round(time.Millisecond))
+	}
+	return passed
+}
+
+// writeJSON writes v as indented JSON to path, "-" meaning stdout
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"opcmss/internal/converter"
+	"opcmss/internal/modbus"
+	"opcmss/internal/opcua"
+	"opcmss/internal/parser"
+)
+
+func runSimulate(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("simulate")
+	listen := fs.String("listen", "", "Modbus endpoint to serve: host:port, rtuovertcp://host:port, or rtu:// for a new pseudo-terminal (default: the configured Modbus endpoint)")
+	serveOPC := fs.Bool("opc", true, "also serve the tag map over OPC UA")
+	opcListen := fs.String("opc-listen", "", "address to serve OPC UA on (default: the configured OPC UA endpoint)")
+	valuesFile := fs.String("values", "", "tab separated file of tag names and values to seed")
+	strict := fs.Bool("strict", false, "reject reads of addresses that are not in the tag map")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	values, err := s.seedValues(*valuesFile)
+	if err != nil {
+		return err
+	}
+
+	sim := modbus.NewSimulator()
+	sim.Strict = *strict
+	if err := sim.SetByteOrder(s.cfg.ModbusByteOrder); err != nil {
+		return err
+	}
+	if err := sim.Seed(s.modbusTags, values); err != nil {
+		return err
+	}
+
+	address := *listen
+	if address == "" {
+		address = s.cfg.ModbusEndpoint
+	}
+	if err := sim.Start(address); err != nil {
+		return fmt.Errorf("failed to start simulator: %w", err)
+	}
+	defer sim.Stop()
+
+	fmt.Printf("Simulating %d tags (%d with values) on %s: %s\n", len(s.modbusTags), len(values), sim.Address(), sim)
+
+	if *serveOPC {
+		address := *opcListen
+		if address == "" {
+			u, err := url.Parse(s.cfg.OPCEndpoint)
+			if err != nil {
+				return fmt.Errorf("invalid OPC UA endpoint: %w", err)
+			}
+			address = u.Host
+		}
+
+		// The simulator asks for the same login the clients are configured with
+		var opts []opcua.SimulatorOption
+		if s.cfg.OPCUserName != "" {
+			opts = append(opts, opcua.WithSimulatorUser(s.cfg.OPCUserName, s.cfg.OPCPassword))
+		}
+
+		opcSim, err := opcua.NewSimulator(address, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create OPC UA simulator: %w", err)
+		}
+		if err := opcSim.Seed(s.opcTags, values); err != nil {
+			return err
+		}
+		if err := opcSim.Start(); err != nil {
+			return err
+		}
+		defer opcSim.Stop()
+		if s.cfg.OPCUserName != "" {
+			fmt.Printf("Serving OPC UA on %s, login as %s\n", opcSim.Endpoint(), s.cfg.OPCUserName)
+		} else {
+			fmt.Printf("Serving OPC UA on %s\n", opcSim.Endpoint())
+		}
+	}
+	fmt.Printf("Press Ctrl+C to stop\n")
+
+	<-ctx.Done()
+
+	fmt.Printf("Served %d requests\n", sim.Requests())
+	return nil
+}
+
+// seedValues parses the values file into values typed for each tag
+func (s *session) seedValues(path string) (map[string]any, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	text, err := parser.ParseValuesTSV(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse values: %w", err)
+	}
+
+	values := make(map[string]any, len(text))
+	for name, v := range text {
+		index, err := s.findTag(name)
+		if err != nil {
+			return nil, err
+		}
+		tag := s.modbusTags[index]
+		value, err := converter.ParseValue(tag.ResolvedDataType(), v)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s: %w", name, err)
+		}
+		values[tag.Name] = value
+	}
+	return values, nil
+}
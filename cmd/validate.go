@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"opcmss/internal/parser"
+)
+
+func runValidateTags(_ context.Context, args []string) error {
+	fs, flags := newFlagSet("validate-tags")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	issues := parser.ValidateTags(s.modbusTags)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+
+	fmt.Printf("%d tags checked, %d issues\n", len(s.modbusTags), len(issues))
+	if len(issues) > 0 {
+		return fmt.Errorf("tag file %s has %d issues", s.cfg.TagsFile, len(issues))
+	}
+	return nil
+}
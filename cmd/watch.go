@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"opcmss/internal/compare"
+	"opcmss/internal/modbus"
+	"opcmss/internal/model"
+	"opcmss/internal/opcua"
+	"opcmss/internal/selector"
+	"opcmss/internal/watch"
+)
+
+func runWatch(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("watch")
+	selFlags := selector.BindFlags(fs)
+	alerts := bindAlertFlag(fs)
+	cmpFlags := bindCompareFlags(fs)
+	interval := fs.Duration("interval", 5*time.Second, "time between comparison cycles")
+	debounce := fs.Duration("debounce", 30*time.Second, "how long a tag must keep failing before it is alerted on")
+	cycles := fs.Int("cycles", 0, "stop after this many cycles, 0 runs until interrupted")
+	subscribe := fs.Bool("subscribe", false, "receive OPC UA values through a subscription and compare a tag as soon as it changes")
+	publishing := fs.Duration("publishing", time.Second, "OPC UA publishing interval with -subscribe")
+	sampling := fs.Duration("sampling", 0, "OPC UA sampling interval with -subscribe (default the publishing interval)")
+	queue := fs.Uint("queue", 1, "OPC UA queue size per tag with -subscribe")
+	deadband := fs.Float64("deadband", 0, "absolute deadband for numeric tags with -subscribe")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	opts, err := selFlags.Options(s.cfg.TagsToCompare)
+	if err != nil {
+		return err
+	}
+	indexes, err := selector.Select(s.modbusTags, opts)
+	if err != nil {
+		return err
+	}
+
+	// Without an explicit target alerts still need to be seen
+	if len(*alerts) == 0 {
+		alerts.add(watch.WriterAlerter{W: os.Stderr})
+	}
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	modbusClient, err := s.dialModbus()
+	if err != nil {
+		return err
+	}
+	defer modbusClient.Close()
+
+	w := &watcher{
+		s:       s,
+		opc:     opcClient,
+		modbus:  modbusClient,
+		indexes: indexes,
+		opts:    cmpFlags.options(),
+		tracker: watch.NewTracker(*debounce),
+		alerts:  alerts,
+	}
+
+	var changes <-chan opcua.DataChange
+	if *subscribe {
+		tags := make([]model.OPCTag, len(indexes))
+		for i, index := range indexes {
+			tags[i] = s.opcTags[index]
+		}
+		sub, err := opcClient.Subscribe(ctx, tags, opcua.SubscriptionOptions{
+			PublishingInterval: *publishing,
+			SamplingInterval:   *sampling,
+			QueueSize:          uint32(*queue),
+			Deadband:           *deadband,
+		})
+		if err != nil {
+			return err
+		}
+		defer sub.Close()
+		w.sub = sub
+		changes = sub.Changes()
+
+		if err := w.awaitInitialValues(ctx, max(*interval, 3*(*publishing))); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Watching %d tags (%s) every %s, alerting after %s\n", len(indexes), describeSelection(opts), *interval, *debounce)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for cycle := 1; ; cycle++ {
+		results := w.cycle(ctx)
+		if ctx.Err() != nil {
+			return nil // a cycle cut short says nothing about the tags
+		}
+		now := time.Now()
+		w.record(results, now)
+
+		failing, alerted := w.tracker.Failing()
+		fmt.Printf("%s cycle %d: %d match, %d failing (%d alerted)\n",
+			now.Format(time.TimeOnly), cycle, len(results)-failing, failing, alerted)
+
+		if *cycles > 0 && cycle >= *cycles {
+			return nil
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				break wait
+			case change, ok := <-changes:
+				if !ok {
+					return fmt.Errorf("OPC UA subscription ended: %w", w.sub.Err())
+				}
+				w.onChange(ctx, change)
+			}
+		}
+	}
+}
+
+// watcher runs the comparisons of the watch command. When subscribed, the
+// latest OPC UA values come from the subscription and only Modbus is polled.
+type watcher struct {
+	s       *session
+	opc     *opcua.Client
+	modbus  *modbus.Client
+	indexes []int
+	opts    compareOptions
+	tracker *watch.Tracker
+	alerts  *alertTargets
+
+	sub         *opcua.Subscription // nil when polling
+	opcReadings []compare.Reading   // latest subscribed value per selected tag
+}
+
+// awaitInitialValues waits until the subscription has delivered a value for
+// every tag, so the first cycle does not report tags as missing
+func (w *watcher) awaitInitialValues(ctx context.Context, timeout time.Duration) error {
+	w.opcReadings = make([]compare.Reading, len(w.indexes))
+	for i := range w.opcReadings {
+		w.opcReadings[i] = compare.Reading{Err: fmt.Errorf("no value received from the subscription yet")}
+	}
+
+	deadline := time.After(timeout)
+	seen := make(map[int]bool, len(w.indexes))
+	for len(seen) < len(w.indexes) {
+		select {
+		case change, ok := <-w.sub.Changes():
+			if !ok {
+				return fmt.Errorf("OPC UA subscription ended: %w", w.sub.Err())
+			}
+			w.opcReadings[change.Index] = subscribedReading(change)
+			seen[change.Index] = true
+		case <-deadline:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+func subscribedReading(change opcua.DataChange) compare.Reading {
+	return compare.Reading{
+		Value:           change.Value,
+		Err:             change.Err,
+		ReadAt:          change.ReceivedAt,
+		SourceTimestamp: change.SourceTimestamp,
+		ServerTimestamp: change.ServerTimestamp,
+	}
+}
+
+// cycle compares every selected tag once
+func (w *watcher) cycle(ctx context.Context) []compare.Result {
+	if w.sub == nil {
+		results, _ := w.s.compareTags(ctx, w.opc, w.modbus, w.indexes, w.opts)
+		return results
+	}
+
+	var modbusReadings []compare.Reading
+	if w.opts.batch {
+		modbusReadings = w.s.readModbusBatch(ctx, w.modbus, w.indexes)
+	} else {
+		modbusReadings = make([]compare.Reading, len(w.indexes))
+		for i, index := range w.indexes {
+			modbusReadings[i] = w.s.modbusReader(ctx, w.modbus, index)()
+		}
+	}
+
+	results := make([]compare.Result, len(w.indexes))
+	opcTags := make([]model.OPCTag, len(w.indexes))
+	modbusTags := make([]model.ModbusTag, len(w.indexes))
+	for i, index := range w.indexes {
+		opcTags[i] = w.s.opcTags[index]
+		modbusTags[i] = w.s.modbusTags[index]
+		results[i] = compare.Build(opcTags[i], modbusTags[i], w.opcReadings[i], modbusReadings[i])
+	}
+
+	// Mismatches are re-checked with live reads on both sides
+	compare.RecheckAll(ctx, results, opcTags, modbusTags, func(i int) (func() compare.Reading, func() compare.Reading) {
+		return w.s.opcReader(ctx, w.opc, w.indexes[i]), w.s.modbusReader(ctx, w.modbus, w.indexes[i])
+	}, w.opts.retry)
+	return results
+}
+
+// onChange compares a tag right after its OPC UA value changed
+func (w *watcher) onChange(ctx context.Context, change opcua.DataChange) {
+	w.opcReadings[change.Index] = subscribedReading(change)
+
+	index := w.indexes[change.Index]
+	opcTag, modbusTag := w.s.opcTags[index], w.s.modbusTags[index]
+	readModbus := w.s.modbusReader(ctx, w.modbus, index)
+
+	result := compare.Build(opcTag, modbusTag, w.opcReadings[change.Index], readModbus())
+	result = compare.Recheck(ctx, result, opcTag, modbusTag, w.s.opcReader(ctx, w.opc, index), readModbus, w.opts.retry)
+	if ctx.Err() != nil {
+		return
+	}
+	w.record([]compare.Result{result}, time.Now())
+}
+
+// record feeds results to the tracker and sends the resulting alerts
+func (w *watcher) record(results []compare.Result, now time.Time) {
+	for _, event := range w.tracker.Update(results, now) {
+		w.alerts.alert(event)
+	}
+}
+
+// alertTargets collects repeated -alert flags
+type alertTargets []watch.Alerter
+
+func bindAlertFlag(fs *flag.FlagSet) *alertTargets {
+	targets := &alertTargets{}
+	fs.Var(targets, "alert", fmt.Sprintf("send alerts to a target, repeatable; targets: %s (default stderr)", strings.Join(watch.AlertKinds, ", ")))
+	return targets
+}
+
+func (t *alertTargets) String() string {
+	return fmt.Sprintf("%d targets", len(*t))
+}
+
+func (t *alertTargets) Set(value string) error {
+	alerter, err := watch.NewAlerter(value)
+	if err != nil {
+		return err
+	}
+	t.add(alerter)
+	return nil
+}
+
+func (t *alertTargets) add(alerter watch.Alerter) {
+	*t = append(*t, alerter)
+}
+
+// alert delivers an event to every target. A failing target must not stop
+// the watch, so errors are only reported.
+func (t *alertTargets) alert(event watch.Event) {
+	for _, alerter := range *t {
+		if err := alerter.Alert(event); err != nil {
+			fmt.Fprintf(os.Stderr, "alert failed: %v\n", err)
+		}
+	}
+}
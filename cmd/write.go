@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"opcmss/internal/converter"
+)
+
+func runWrite(ctx context.Context, args []string) error {
+	fs, flags := newFlagSet("write")
+	via := fs.String("via", "opc", "protocol to write with: opc or modbus")
+	verify := fs.Bool("verify", true, "read the tag back after writing it")
+	s, err := newSession(fs, flags, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a tag name and a value")
+	}
+
+	index, err := s.findTag(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch *via {
+	case "opc":
+		return s.writeOPC(ctx, index, fs.Arg(1), *verify)
+	case "modbus":
+		return s.writeModbus(ctx, index, fs.Arg(1), *verify)
+	default:
+		return fmt.Errorf("unknown protocol %q for -via (expected opc or modbus)", *via)
+	}
+}
+
+func (s *session) writeOPC(ctx context.Context, index int, text string, verify bool) error {
+	opcTag := s.opcTags[index]
+
+	value, err := converter.ParseValue(opcTag.DataType, text)
+	if err != nil {
+		return err
+	}
+
+	opcClient, err := s.dialOPC()
+	if err != nil {
+		return err
+	}
+	defer opcClient.Close()
+
+	if err := opcClient.WriteTag(ctx, opcTag, value); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %v to %s\n", value, opcTag.NodeID)
+
+	if !verify {
+		return nil
+	}
+	readBack, err := opcClient.ReadTag(ctx, opcTag)
+	if err != nil {
+		return fmt.Errorf("read-back failed: %w", err)
+	}
+	fmt.Printf("Read back: %v\n", readBack)
+	return nil
+}
+
+func (s *session) writeModbus(ctx context.Context, index int, text string, verify bool) error {
+	modbusTag := s.modbusTags[index]
+
+	value, err := converter.ParseValue(modbusTag.ResolvedDataType(), text)
+	if err != nil {
+		return err
+	}
+
+	modbusClient, err := s.dialModbus()
+	if err != nil {
+		return err
+	}
+	defer modbusClient.Close()
+
+	if !verify {
+		if err := modbusClient.WriteTag(ctx, modbusTag, value); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %v to %s %d\n", value, modbusTag.RegisterType, modbusTag.Address)
+		return nil
+	}
+
+	readBack, err := modbusClient.WriteTagVerified(ctx, modbusTag, value)
+	if readBack != nil {
+		fmt.Printf("Wrote %v to %s %d\n", value, modbusTag.RegisterType, modbusTag.Address)
+		fmt.Printf("Read back: %s\n", modbusClient.FormatTagValue(modbusTag, readBack))
+	}
+	return err
+}
@@ -3,12 +3,16 @@ module opcmss
 go 1.24.4
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/awcullen/opcua v1.4.0
 	github.com/simonvetter/modbus v1.6.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/djherbis/buffer v1.2.0 // indirect
+	github.com/gammazero/deque v1.0.0 // indirect
+	github.com/gammazero/workerpool v1.1.3 // indirect
 	github.com/goburrow/serial v0.1.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
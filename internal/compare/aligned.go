@@ -0,0 +1,90 @@
+package compare
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"opcmss/internal/model"
+	"opcmss/internal/retry"
+)
+
+// RetryOptions controls how a mismatch is re-checked before it is reported
+type RetryOptions struct {
+	Retries int           // re-reads of a mismatched tag, 0 reports it straight away
+	Delay   time.Duration // pause before each re-read
+}
+
+// ReadPair runs both reads at the same time, so a changing value is seen by
+// both sides at nearly the same moment
+func ReadPair(readOPC, readModbus func() Reading) (opc, mb Reading) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		opc = readOPC()
+	}()
+	mb = readModbus()
+	wg.Wait()
+	return opc, mb
+}
+
+// Aligned reads a tag on both sides concurrently and compares the values,
+// re-checking a mismatch as described by Recheck
+func Aligned(ctx context.Context, opcTag model.OPCTag, modbusTag model.ModbusTag, readOPC, readModbus func() Reading, opts RetryOptions) Result {
+	opc, mb := ReadPair(readOPC, readModbus)
+	return Recheck(ctx, Build(opcTag, modbusTag, opc, mb), opcTag, modbusTag, readOPC, readModbus, opts)
+}
+
+// Recheck re-reads a mismatched tag up to opts.Retries times. If the values
+// match on a re-read the result is reported as transient, otherwise the last
+// mismatch is kept. Other results are returned unchanged. The re-checks stop
+// once ctx is done.
+func Recheck(ctx context.Context, result Result, opcTag model.OPCTag, modbusTag model.ModbusTag, readOPC, readModbus func() Reading, opts RetryOptions) Result {
+	attempts := result.Attempts
+	for i := 0; i < opts.Retries && result.Status == StatusMismatch; i++ {
+		if !retry.Sleep(ctx, opts.Delay) {
+			break
+		}
+
+		opc, mb := ReadPair(readOPC, readModbus)
+		recheck := Build(opcTag, modbusTag, opc, mb)
+		attempts++
+		recheck.Attempts = attempts
+
+		switch recheck.Status {
+		case StatusMatch:
+			recheck.Status = StatusTransient
+			return recheck
+		case StatusMismatch:
+			result = recheck
+		default:
+			// A read error on a re-read says nothing about the mismatch
+			result.Attempts = attempts
+		}
+	}
+	return result
+}
+
+// RecheckAll re-checks every mismatched result concurrently. results, opcTags
+// and modbusTags are indexed alike, and read returns the read functions for a
+// position.
+func RecheckAll(ctx context.Context, results []Result, opcTags []model.OPCTag, modbusTags []model.ModbusTag, read func(i int) (readOPC, readModbus func() Reading), opts RetryOptions) {
+	if opts.Retries <= 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		if results[i].Status != StatusMismatch {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			readOPC, readModbus := read(i)
+			results[i] = Recheck(ctx, results[i], opcTags[i], modbusTags[i], readOPC, readModbus, opts)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,136 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+)
+
+// sequence returns a read function yielding the given readings in turn,
+// repeating the last one
+func sequence(readings ...Reading) func() Reading {
+	i := 0
+	return func() Reading {
+		r := readings[min(i, len(readings)-1)]
+		i++
+		return r
+	}
+}
+
+func value(v any) Reading {
+	return Reading{Value: v, ReadAt: time.Now()}
+}
+
+func TestReadPair_Concurrent(t *testing.T) {
+	started := make(chan struct{})
+	readOPC := func() Reading {
+		close(started)
+		return value(1)
+	}
+	readModbus := func() Reading {
+		// Only returns once the OPC read is running at the same time
+		select {
+		case <-started:
+			return value(2)
+		case <-time.After(time.Second):
+			return Reading{Err: errors.New("reads were not concurrent")}
+		}
+	}
+
+	opc, mb := ReadPair(readOPC, readModbus)
+	if opc.Value != 1 || mb.Value != 2 || mb.Err != nil {
+		t.Errorf("Unexpected readings: %+v, %+v", opc, mb)
+	}
+}
+
+func TestAligned_Outcomes(t *testing.T) {
+	opcTag := model.OPCTag{Name: "Level", DataType: "REAL"}
+	modbusTag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Size: 2}
+	opts := RetryOptions{Retries: 2}
+	readErr := errors.New("timeout")
+
+	testCases := []struct {
+		name       string
+		opc, mb    func() Reading
+		expected   Status
+		attempts   int
+		modbusLast any
+	}{
+		{"match", sequence(value(float32(1))), sequence(value(float32(1))), StatusMatch, 1, float32(1)},
+		{"transient", sequence(value(float32(1)), value(float32(2))), sequence(value(float32(2))), StatusTransient, 2, float32(2)},
+		{"persistent", sequence(value(float32(1)), value(float32(3)), value(float32(4))), sequence(value(float32(2))), StatusMismatch, 3, float32(2)},
+		{"error on retry keeps mismatch", sequence(value(float32(1))), sequence(value(float32(2)), Reading{Err: readErr}), StatusMismatch, 3, float32(2)},
+		{"errors are not retried", sequence(Reading{Err: readErr}), sequence(value(float32(2))), StatusOPCError, 1, float32(2)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Aligned(context.Background(), opcTag, modbusTag, tc.opc, tc.mb, opts)
+			if result.Status != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, result.Status)
+			}
+			if result.Attempts != tc.attempts {
+				t.Errorf("Expected %d attempts, got %d", tc.attempts, result.Attempts)
+			}
+			if result.ModbusValue != tc.modbusLast {
+				t.Errorf("Expected Modbus value %v, got %v", tc.modbusLast, result.ModbusValue)
+			}
+		})
+	}
+}
+
+func TestAligned_NoRetries(t *testing.T) {
+	opcTag := model.OPCTag{Name: "Level", DataType: "REAL"}
+	modbusTag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Size: 2}
+
+	result := Aligned(context.Background(), opcTag, modbusTag, sequence(value(float32(1)), value(float32(2))), sequence(value(float32(2))), RetryOptions{})
+	if result.Status != StatusMismatch || result.Attempts != 1 {
+		t.Errorf("Expected a mismatch after one read, got %s after %d", result.Status, result.Attempts)
+	}
+}
+
+func TestBuild_OPCTimestamps(t *testing.T) {
+	source := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	opc := Reading{Value: true, SourceTimestamp: source, ServerTimestamp: source.Add(time.Millisecond)}
+
+	result := Build(model.OPCTag{DataType: "BOOL"}, model.ModbusTag{RegisterType: "Coil"}, opc, value(true))
+	if !result.OPCSourceTimestamp.Equal(source) || !result.OPCServerTimestamp.Equal(source.Add(time.Millisecond)) {
+		t.Errorf("Unexpected timestamps: %v, %v", result.OPCSourceTimestamp, result.OPCServerTimestamp)
+	}
+}
+
+func TestRecheckAll(t *testing.T) {
+	opcTags := []model.OPCTag{{Name: "A", DataType: "INT"}, {Name: "B", DataType: "INT"}, {Name: "C", DataType: "INT"}}
+	modbusTags := []model.ModbusTag{{Name: "A"}, {Name: "B"}, {Name: "C"}}
+	results := []Result{
+		{Name: "A", Status: StatusMatch, Attempts: 1},
+		{Name: "B", Status: StatusMismatch, Attempts: 1},
+		{Name: "C", Status: StatusMismatch, Attempts: 1},
+	}
+
+	read := func(i int) (func() Reading, func() Reading) {
+		if i == 0 {
+			t.Error("Matching tags should not be re-read")
+		}
+		// B settles, C keeps differing
+		return sequence(value(int16(i))), sequence(value(int16(1)))
+	}
+	RecheckAll(context.Background(), results, opcTags, modbusTags, read, RetryOptions{Retries: 1})
+
+	expected := []Status{StatusMatch, StatusTransient, StatusMismatch}
+	for i, result := range results {
+		if result.Status != expected[i] {
+			t.Errorf("%s: expected %s, got %s", result.Name, expected[i], result.Status)
+		}
+	}
+}
+
+func TestSummarize_Transients(t *testing.T) {
+	s := Summarize([]Result{{Status: StatusMatch}, {Status: StatusTransient}}, time.Now())
+	if s.Transients != 1 || s.Failed() {
+		t.Errorf("Expected one transient and no failure, got %+v", s)
+	}
+}
@@ -0,0 +1,202 @@
+package compare
+
+import (
+	"context"
+	"time"
+
+	"opcmss/internal/model"
+)
+
+// Status is the outcome of comparing a single tag
+type Status string
+
+const (
+	StatusMatch       Status = "match"
+	StatusMismatch    Status = "mismatch"
+	StatusOPCError    Status = "opc_error"
+	StatusModbusError Status = "modbus_error"
+	StatusReadError   Status = "read_error" // both sides failed
+	StatusTransient   Status = "transient"  // mismatched at first, matched on a re-read
+)
+
+// OPCReader reads a tag over OPC UA
+type OPCReader interface {
+	ReadTag(ctx context.Context, tag model.OPCTag) (any, error)
+}
+
+// ModbusReader reads a tag over Modbus
+type ModbusReader interface {
+	ReadTag(ctx context.Context, tag model.ModbusTag) (any, error)
+}
+
+// Result is the outcome of comparing one tag across both protocols
+type Result struct {
+	Name          string        `json:"name"`
+	NodeID        string        `json:"node_id"`
+	DataType      string        `json:"data_type"`
+	RegisterType  string        `json:"register_type"`
+	Address       uint16        `json:"address"`
+	ModbusAddress uint32        `json:"modbus_address"`
+	OPCValue      any           `json:"opc_value"`
+	ModbusRaw     any           `json:"modbus_raw"`
+	ModbusValue   any           `json:"modbus_value"`
+	Status        Status        `json:"status"`
+	OPCError      string        `json:"opc_error,omitempty"`
+	ModbusError   string        `json:"modbus_error,omitempty"`
+	OPCReadAt     time.Time     `json:"opc_read_at"`
+	ModbusReadAt  time.Time     `json:"modbus_read_at"`
+	OPCLatency    time.Duration `json:"opc_latency_ns"`
+	ModbusLatency time.Duration `json:"modbus_latency_ns"`
+
+	// Timestamps reported by the OPC UA server, if the reader exposes them
+	OPCSourceTimestamp time.Time `json:"opc_source_timestamp,omitempty"`
+	OPCServerTimestamp time.Time `json:"opc_server_timestamp,omitempty"`
+
+	// Tolerance the values were compared with, like "abs:0.001"
+	Tolerance string `json:"tolerance"`
+
+	// Attempts is the number of times both sides were read, more than one
+	// when a mismatch was re-checked
+	Attempts int `json:"attempts"`
+}
+
+// Failed reports whether the result should fail a comparison run. A
+// transient mismatch is a value that changed between the two reads.
+func (r Result) Failed() bool {
+	return r.Status != StatusMatch && r.Status != StatusTransient
+}
+
+// Error returns a combined description of the read errors, if any
+func (r Result) Error() string {
+	switch {
+	case r.OPCError != "" && r.ModbusError != "":
+		return "OPC UA: " + r.OPCError + "; Modbus: " + r.ModbusError
+	case r.OPCError != "":
+		return "OPC UA: " + r.OPCError
+	case r.ModbusError != "":
+		return "Modbus: " + r.ModbusError
+	}
+	return ""
+}
+
+// Reading is the outcome of reading a tag on one side
+type Reading struct {
+	Value   any
+	Raw     any // undecoded value, if the reader exposes it
+	Err     error
+	ReadAt  time.Time
+	Latency time.Duration
+
+	// Timestamps reported by an OPC UA server
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+// Read times a single read
+func Read(read func() (any, error)) Reading {
+	readAt := time.Now()
+	value, err := read()
+	return Reading{Value: value, Err: err, ReadAt: readAt, Latency: time.Since(readAt)}
+}
+
+// Tag reads a tag from OPC UA and then from Modbus and compares the values
+func Tag(ctx context.Context, opc OPCReader, mb ModbusReader, opcTag model.OPCTag, modbusTag model.ModbusTag) Result {
+	opcReading := Read(func() (any, error) { return opc.ReadTag(ctx, opcTag) })
+	modbusReading := Read(func() (any, error) { return mb.ReadTag(ctx, modbusTag) })
+	return Build(opcTag, modbusTag, opcReading, modbusReading)
+}
+
+// Build compares readings that were taken elsewhere, e.g. by batch reads
+func Build(opcTag model.OPCTag, modbusTag model.ModbusTag, opc, mb Reading) Result {
+	tolerance := TagTolerance(modbusTag)
+	result := Result{
+		Name:          opcTag.Name,
+		NodeID:        opcTag.NodeID,
+		DataType:      opcTag.DataType,
+		RegisterType:  modbusTag.RegisterType,
+		Address:       modbusTag.Address,
+		ModbusAddress: modbusTag.ModbusAddress,
+		OPCReadAt:     opc.ReadAt,
+		OPCLatency:    opc.Latency,
+		ModbusReadAt:  mb.ReadAt,
+		ModbusLatency: mb.Latency,
+
+		OPCSourceTimestamp: opc.SourceTimestamp,
+		OPCServerTimestamp: opc.ServerTimestamp,
+		Tolerance:          tolerance.String(),
+		Attempts:           1,
+	}
+
+	if opc.Err != nil {
+		result.OPCError = opc.Err.Error()
+	} else {
+		result.OPCValue = opc.Value
+	}
+
+	if mb.Err != nil {
+		result.ModbusError = mb.Err.Error()
+	} else {
+		result.ModbusValue = mb.Value
+		result.ModbusRaw = mb.Raw
+		if mb.Raw == nil {
+			result.ModbusRaw = mb.Value
+		}
+	}
+
+	switch {
+	case opc.Err != nil && mb.Err != nil:
+		result.Status = StatusReadError
+	case opc.Err != nil:
+		result.Status = StatusOPCError
+	case mb.Err != nil:
+		result.Status = StatusModbusError
+	case Within(tolerance, opc.Value, mb.Value):
+		result.Status = StatusMatch
+	default:
+		result.Status = StatusMismatch
+	}
+	return result
+}
+
+// Summary counts results by outcome
+type Summary struct {
+	Total        int           `json:"total"`
+	Matches      int           `json:"matches"`
+	Mismatches   int           `json:"mismatches"`
+	Transients   int           `json:"transients"`
+	OPCErrors    int           `json:"opc_errors"`
+	ModbusErrors int           `json:"modbus_errors"`
+	StartedAt    time.Time     `json:"started_at"`
+	Duration     time.Duration `json:"duration_ns"`
+}
+
+// Summarize counts the results of a run that started at startedAt
+func Summarize(results []Result, startedAt time.Time) Summary {
+	s := Summary{
+		Total:     len(results),
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}
+	for _, r := range results {
+		switch r.Status {
+		case StatusMatch:
+			s.Matches++
+		case StatusMismatch:
+			s.Mismatches++
+		case StatusTransient:
+			s.Transients++
+		}
+		if r.OPCError != "" {
+			s.OPCErrors++
+		}
+		if r.ModbusError != "" {
+			s.ModbusErrors++
+		}
+	}
+	return s
+}
+
+// Failed reports whether any tag mismatched or could not be read
+func (s Summary) Failed() bool {
+	return s.Matches+s.Transients != s.Total
+}
@@ -0,0 +1,139 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+)
+
+type fakeOPC struct {
+	value any
+	err   error
+}
+
+func (f fakeOPC) ReadTag(ctx context.Context, tag model.OPCTag) (any, error) {
+	return f.value, f.err
+}
+
+type fakeModbus struct {
+	value any
+	err   error
+}
+
+func (f fakeModbus) ReadTag(ctx context.Context, tag model.ModbusTag) (any, error) {
+	return f.value, f.err
+}
+
+func TestTag_Statuses(t *testing.T) {
+	opcTag := model.OPCTag{Name: "Level", NodeID: "ns=4;s=Level", DataType: "REAL"}
+	modbusTag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Address: 1, ModbusAddress: 400001, Size: 2}
+	readErr := errors.New("timeout")
+
+	testCases := []struct {
+		name     string
+		opc      fakeOPC
+		modbus   fakeModbus
+		expected Status
+	}{
+		{"match", fakeOPC{value: float32(60)}, fakeModbus{value: float32(60)}, StatusMatch},
+		{"mismatch", fakeOPC{value: float32(61)}, fakeModbus{value: float32(60)}, StatusMismatch},
+		{"opc error", fakeOPC{err: readErr}, fakeModbus{value: float32(60)}, StatusOPCError},
+		{"modbus error", fakeOPC{value: float32(60)}, fakeModbus{err: readErr}, StatusModbusError},
+		{"both errors", fakeOPC{err: readErr}, fakeModbus{err: readErr}, StatusReadError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Tag(context.Background(), tc.opc, tc.modbus, opcTag, modbusTag)
+			if result.Status != tc.expected {
+				t.Errorf("Expected status %s, got %s", tc.expected, result.Status)
+			}
+			if result.Name != "Level" || result.NodeID != "ns=4;s=Level" || result.ModbusAddress != 400001 {
+				t.Errorf("Tag identity not copied into result: %+v", result)
+			}
+			if result.Failed() != (tc.expected != StatusMatch) {
+				t.Errorf("Failed() mismatch for status %s", result.Status)
+			}
+		})
+	}
+}
+
+func TestBuild_RawAndDecodedValues(t *testing.T) {
+	raw := []uint16{0x4270, 0}
+	result := Build(
+		model.OPCTag{Name: "Level", DataType: "REAL"},
+		model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Size: 2},
+		Reading{Value: float32(60), ReadAt: time.Now()},
+		Reading{Value: float32(60), Raw: raw, ReadAt: time.Now()},
+	)
+
+	if regs, ok := result.ModbusRaw.([]uint16); !ok || len(regs) != 2 || regs[0] != 0x4270 {
+		t.Errorf("Expected raw registers, got %v", result.ModbusRaw)
+	}
+	if result.ModbusValue != float32(60) {
+		t.Errorf("Expected decoded value 60, got %v", result.ModbusValue)
+	}
+	if result.Status != StatusMatch {
+		t.Errorf("Expected match, got %s", result.Status)
+	}
+}
+
+func TestTag_Timestamps(t *testing.T) {
+	result := Tag(context.Background(), fakeOPC{value: true}, fakeModbus{value: true}, model.OPCTag{DataType: "BOOL"}, model.ModbusTag{RegisterType: "Coil", Size: 1})
+
+	if result.OPCReadAt.IsZero() || result.ModbusReadAt.IsZero() {
+		t.Error("Expected read timestamps to be set")
+	}
+	if result.ModbusRaw != true {
+		t.Errorf("Expected raw to fall back to the value, got %v", result.ModbusRaw)
+	}
+}
+
+func TestValuesMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opc      any
+		modbus   any
+		expected bool
+	}{
+		{"bools", true, true, true},
+		{"bool vs number", true, int16(1), false},
+		{"ints", int16(5), int16(5), true},
+		{"widened ints", int32(70000), int32(70000), true},
+		{"floats within tolerance", float32(1.0001), float32(1.0), true},
+		{"floats apart", float32(1.1), float32(1.0), false},
+		{"strings", "abc", "abc", true},
+		{"strings differ", "abc", "abd", false},
+	}
+
+	for _, tc := range testCases {
+		if got := ValuesMatch(tc.opc, tc.modbus); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	results := []Result{
+		{Status: StatusMatch},
+		{Status: StatusMatch},
+		{Status: StatusMismatch},
+		{Status: StatusOPCError, OPCError: "bad node"},
+		{Status: StatusReadError, OPCError: "x", ModbusError: "y"},
+	}
+
+	s := Summarize(results, time.Now())
+	if s.Total != 5 || s.Matches != 2 || s.Mismatches != 1 || s.OPCErrors != 2 || s.ModbusErrors != 1 {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	if !s.Failed() {
+		t.Error("Expected summary to be failed")
+	}
+
+	if Summarize(results[:2], time.Now()).Failed() {
+		t.Error("Expected all-match summary not to be failed")
+	}
+}
@@ -0,0 +1,85 @@
+package compare
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"opcmss/internal/converter"
+	"opcmss/internal/modbus"
+	"opcmss/internal/model"
+	"opcmss/internal/opcua"
+)
+
+// TestTag_AgainstSimulators runs the comparison end-to-end against both
+// simulators seeded from the same tag map
+func TestTag_AgainstSimulators(t *testing.T) {
+	modbusTags := []model.ModbusTag{
+		{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1, DataType: "BOOL"},
+		{Name: "Setpoint", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"},
+		{Name: "Flow", RegisterType: "HoldingRegister", Address: 2, Size: 2, DataType: "REAL"},
+		{Name: "Level", RegisterType: "InputRegister", Address: 1, Size: 2, DataType: "REAL"},
+	}
+	opcTags := converter.ConvertAllModbusToOPC(modbusTags, 4, "PLC.")
+	values := map[string]any{
+		"Pump":     true,
+		"Setpoint": int16(-42),
+		"Flow":     float32(12.5),
+		"Level":    float32(3.25),
+	}
+
+	mbSim := modbus.NewSimulator()
+	if err := mbSim.Seed(modbusTags, values); err != nil {
+		t.Fatalf("Modbus seed failed: %v", err)
+	}
+	// The PLC's Modbus mapping lags behind for one tag
+	if err := mbSim.SetValue(modbusTags[3], float32(3.5)); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	if err := mbSim.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Modbus start failed: %v", err)
+	}
+	defer mbSim.Stop()
+
+	opcSim, err := opcua.NewSimulator("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("NewSimulator failed: %v", err)
+	}
+	if err := opcSim.Seed(opcTags, values); err != nil {
+		t.Fatalf("OPC seed failed: %v", err)
+	}
+	if err := opcSim.Start(); err != nil {
+		t.Fatalf("OPC start failed: %v", err)
+	}
+	defer opcSim.Stop()
+
+	opcClient, err := opcua.NewClient(opcSim.Endpoint())
+	if err != nil {
+		t.Fatalf("OPC client failed: %v", err)
+	}
+	defer opcClient.Close()
+
+	modbusClient, err := modbus.NewClient(mbSim.Address())
+	if err != nil {
+		t.Fatalf("Modbus client failed: %v", err)
+	}
+	defer modbusClient.Close()
+
+	startedAt := time.Now()
+	var results []Result
+	for i := range modbusTags {
+		results = append(results, Tag(context.Background(), opcClient, modbusClient, opcTags[i], modbusTags[i]))
+	}
+
+	expected := []Status{StatusMatch, StatusMatch, StatusMatch, StatusMismatch}
+	for i, result := range results {
+		if result.Status != expected[i] {
+			t.Errorf("%s: expected %s, got %s (%s)", result.Name, expected[i], result.Status, result.Error())
+		}
+	}
+
+	summary := Summarize(results, startedAt)
+	if summary.Matches != 3 || summary.Mismatches != 1 {
+		t.Errorf("Expected 3 matches and 1 mismatch, got %+v", summary)
+	}
+}
@@ -0,0 +1,203 @@
+package compare
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+
+	"opcmss/internal/model"
+)
+
+// ToleranceRules picks the tolerance of each tag. A tolerance in the tag
+// file wins over the first matching name pattern, which wins over the
+// tolerance for the tag's data type, which wins over the default.
+type ToleranceRules struct {
+	Default  model.Tolerance
+	Types    map[string]model.Tolerance // by IEC data type
+	Patterns []PatternTolerance
+}
+
+// PatternTolerance applies a tolerance to the tags whose name matches a glob
+type PatternTolerance struct {
+	Pattern   string
+	Tolerance model.Tolerance
+}
+
+// DefaultToleranceRules applies model.DefaultTolerance to every tag
+func DefaultToleranceRules() ToleranceRules {
+	return ToleranceRules{Default: model.DefaultTolerance}
+}
+
+// Resolve returns the tolerance for a tag and where it came from: "tag",
+// "pattern", "type" or "default"
+func (r ToleranceRules) Resolve(tag model.ModbusTag) (model.Tolerance, string) {
+	if tag.Tolerance != "" {
+		if t, err := model.ParseTolerance(tag.Tolerance); err == nil {
+			return t, "tag"
+		}
+	}
+	for _, p := range r.Patterns {
+		if ok, _ := path.Match(p.Pattern, tag.Name); ok {
+			return p.Tolerance, "pattern"
+		}
+	}
+	if t, ok := r.Types[tag.ResolvedDataType()]; ok {
+		return t, "type"
+	}
+	if r.Default.Kind == "" {
+		return model.DefaultTolerance, "default"
+	}
+	return r.Default, "default"
+}
+
+// TagTolerance returns the tolerance set on a tag, or the default when the
+// tag has none
+func TagTolerance(tag model.ModbusTag) model.Tolerance {
+	if t, err := model.ParseTolerance(tag.Tolerance); err == nil {
+		return t
+	}
+	return model.DefaultTolerance
+}
+
+// Apply sets the resolved tolerance on every tag that has none of its own
+func (r ToleranceRules) Apply(tags []model.ModbusTag) {
+	for i := range tags {
+		if tags[i].Tolerance == "" {
+			t, _ := r.Resolve(tags[i])
+			tags[i].Tolerance = t.String()
+		}
+	}
+}
+
+// Within compares decoded OPC and Modbus values under a tolerance. Booleans
+// and strings must be identical whatever the tolerance.
+func Within(t model.Tolerance, opcValue, modbusValue any) bool {
+	switch opc := opcValue.(type) {
+	case bool:
+		modbusBool, ok := modbusValue.(bool)
+		return ok && opc == modbusBool
+
+	case string:
+		modbusString, ok := modbusValue.(string)
+		return ok && opc == modbusString
+	}
+
+	// Integers are compared as integers under every tolerance, as floats
+	// would lose LINT precision
+	if a, ok := convertToInt64(opcValue); ok {
+		if b, ok := convertToInt64(modbusValue); ok {
+			return withinInt(t, a, b)
+		}
+	}
+
+	opcFloat := convertToFloat64(opcValue)
+	modbusFloat := convertToFloat64(modbusValue)
+	if opcFloat != nil && modbusFloat != nil {
+		a, b := *opcFloat, *modbusFloat
+		diff := math.Abs(a - b)
+
+		switch t.Kind {
+		case model.ToleranceExact:
+			return a == b
+		case model.ToleranceRelative:
+			return diff <= t.Value/100*math.Max(math.Abs(a), math.Abs(b))
+		case model.ToleranceULP:
+			return ulpDistance(opcValue, modbusValue, a, b) <= uint64(t.Value)
+		default:
+			return diff <= t.Value
+		}
+	}
+
+	if reflect.DeepEqual(opcValue, modbusValue) {
+		return true
+	}
+	return fmt.Sprintf("%v", opcValue) == fmt.Sprintf("%v", modbusValue)
+}
+
+// withinInt compares two integers under a tolerance. The difference is taken
+// in integers, and since it is whole, it is within a fractional limit exactly
+// when it is within the limit rounded down. ULPs of integers are exact.
+func withinInt(t model.Tolerance, a, b int64) bool {
+	var limit float64
+	switch t.Kind {
+	case model.ToleranceExact, model.ToleranceULP:
+		return a == b
+	case model.ToleranceRelative:
+		limit = t.Value / 100 * math.Max(math.Abs(float64(a)), math.Abs(float64(b)))
+	default:
+		limit = t.Value
+	}
+
+	diff := uint64(a) - uint64(b) // the true difference fits a uint64
+	if a < b {
+		diff = uint64(b) - uint64(a)
+	}
+	if limit >= math.MaxUint64 {
+		return true
+	}
+	return diff <= uint64(limit)
+}
+
+// ulpDistance counts the representable floats between two values, in float32
+// steps when either value is a float32 and float64 steps otherwise
+func ulpDistance(opcValue, modbusValue any, a, b float64) uint64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.MaxUint64
+	}
+	_, opc32 := opcValue.(float32)
+	_, modbus32 := modbusValue.(float32)
+	if opc32 || modbus32 {
+		return distance(orderedBits32(float32(a)), orderedBits32(float32(b)))
+	}
+	return distance(orderedBits64(a), orderedBits64(b))
+}
+
+// orderedBits32 maps a float32 to an integer that grows with the float
+func orderedBits32(f float32) int64 {
+	bits := int64(int32(math.Float32bits(f)))
+	if bits < 0 {
+		bits = math.MinInt32 - bits
+	}
+	return bits
+}
+
+func orderedBits64(f float64) int64 {
+	bits := int64(math.Float64bits(f))
+	if bits < 0 {
+		bits = math.MinInt64 - bits
+	}
+	return bits
+}
+
+func distance(a, b int64) uint64 {
+	if a > b {
+		return uint64(a) - uint64(b)
+	}
+	return uint64(b) - uint64(a)
+}
+
+// convertToInt64 converts the integer types to int64
+func convertToInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
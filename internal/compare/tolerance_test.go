@@ -0,0 +1,133 @@
+package compare
+
+import (
+	"math"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func mustTolerance(t *testing.T, s string) model.Tolerance {
+	t.Helper()
+	tol, err := model.ParseTolerance(s)
+	if err != nil {
+		t.Fatalf("ParseTolerance(%q): %v", s, err)
+	}
+	return tol
+}
+
+func TestParseTolerance(t *testing.T) {
+	valid := map[string]string{
+		"exact":     "exact",
+		" ABS:0.01": "abs:0.01",
+		"rel:0.5%":  "rel:0.5%",
+		"rel:2":     "rel:2%",
+		"ulp:4":     "ulp:4",
+	}
+	for in, want := range valid {
+		if got := mustTolerance(t, in).String(); got != want {
+			t.Errorf("ParseTolerance(%q) = %q, expected %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "abs", "abs:-1", "abs:x", "ulp:1.5", "near:1", "abs:NaN", "abs:inf", "rel:+Inf%"} {
+		if _, err := model.ParseTolerance(in); err == nil {
+			t.Errorf("ParseTolerance(%q): expected an error", in)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	next32 := math.Nextafter32(1, 2)
+	testCases := []struct {
+		tolerance string
+		opc       any
+		modbus    any
+		expected  bool
+	}{
+		{"abs:0.5", float32(10.4), float32(10), true},
+		{"abs:0.5", float32(10.6), float32(10), false},
+		{"abs:2", int16(5), int16(7), true},
+		{"rel:1%", float64(1000), float64(1009), true},
+		{"rel:1%", float64(1000), float64(1011), false},
+		{"rel:1%", float64(0), float64(0), true},
+		{"ulp:1", float32(1), next32, true},
+		{"ulp:1", float32(1), math.Nextafter32(next32, 2), false},
+		{"ulp:2", float32(-0.0), math.SmallestNonzeroFloat32, true},
+		{"ulp:4", float32(math.NaN()), float32(math.NaN()), false},
+		{"ulp:100", int32(5), int32(6), false},
+		{"exact", float32(1), next32, false},
+		{"exact", int64(math.MaxInt64), int64(math.MaxInt64 - 1), false},
+		{"exact", uint16(7), uint16(7), true},
+		{"abs:0.001", int64(1 << 60), int64(1<<60 + 1), false},
+		{"abs:1.9", int64(math.MaxInt64), int64(math.MaxInt64 - 1), true},
+		{"abs:1.9", int64(math.MinInt64), int64(math.MaxInt64), false},
+		{"rel:1%", int64(1 << 60), int64(1<<60 + 1), true},
+		{"rel:0.00000001%", int64(1 << 60), int64(1<<60 + 1<<30), false},
+		{"abs:1e30", int64(math.MinInt64), int64(math.MaxInt64), true},
+		{"abs:10", true, false, false},
+		{"rel:50%", "abc", "abd", false},
+	}
+
+	for _, tc := range testCases {
+		if got := Within(mustTolerance(t, tc.tolerance), tc.opc, tc.modbus); got != tc.expected {
+			t.Errorf("Within(%s, %v, %v): expected %v, got %v", tc.tolerance, tc.opc, tc.modbus, tc.expected, got)
+		}
+	}
+}
+
+func TestToleranceRules_Precedence(t *testing.T) {
+	rules := ToleranceRules{
+		Default: mustTolerance(t, "abs:0.1"),
+		Types:   map[string]model.Tolerance{"REAL": mustTolerance(t, "ulp:4")},
+		Patterns: []PatternTolerance{
+			{Pattern: "Flow*", Tolerance: mustTolerance(t, "rel:1%")},
+			{Pattern: "*", Tolerance: mustTolerance(t, "exact")},
+		},
+	}
+
+	testCases := []struct {
+		tag    model.ModbusTag
+		want   string
+		source string
+	}{
+		{model.ModbusTag{Name: "FlowIn", DataType: "REAL", Tolerance: "abs:2"}, "abs:2", "tag"},
+		{model.ModbusTag{Name: "FlowIn", DataType: "REAL"}, "rel:1%", "pattern"},
+		{model.ModbusTag{Name: "Level", DataType: "REAL"}, "exact", "pattern"},
+	}
+	for _, tc := range testCases {
+		got, source := rules.Resolve(tc.tag)
+		if got.String() != tc.want || source != tc.source {
+			t.Errorf("%s: expected %s from %s, got %s from %s", tc.tag.Name, tc.want, tc.source, got, source)
+		}
+	}
+
+	rules.Patterns = rules.Patterns[:1]
+	tags := []model.ModbusTag{
+		{Name: "Level", DataType: "REAL"},
+		{Name: "Count", DataType: "INT"},
+		{Name: "Pump", RegisterType: "Coil", Size: 1, Tolerance: "exact"},
+	}
+	rules.Apply(tags)
+	for i, want := range []string{"ulp:4", "abs:0.1", "exact"} {
+		if tags[i].Tolerance != want {
+			t.Errorf("%s: expected %s after Apply, got %s", tags[i].Name, want, tags[i].Tolerance)
+		}
+	}
+}
+
+func TestBuild_ReportsTolerance(t *testing.T) {
+	opcTag := model.OPCTag{Name: "Level", DataType: "REAL"}
+	modbusTag := model.ModbusTag{Name: "Level", DataType: "REAL", Tolerance: "abs:0.5"}
+
+	result := Build(opcTag, modbusTag, Reading{Value: float32(10.4)}, Reading{Value: float32(10)})
+	if result.Status != StatusMatch || result.Tolerance != "abs:0.5" {
+		t.Errorf("Expected a match under abs:0.5, got %s under %q", result.Status, result.Tolerance)
+	}
+
+	modbusTag.Tolerance = ""
+	result = Build(opcTag, modbusTag, Reading{Value: float32(10.4)}, Reading{Value: float32(10)})
+	if result.Status != StatusMismatch || result.Tolerance != model.DefaultTolerance.String() {
+		t.Errorf("Expected a mismatch under the default, got %s under %q", result.Status, result.Tolerance)
+	}
+}
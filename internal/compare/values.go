@@ -0,0 +1,37 @@
+package compare
+
+import "opcmss/internal/model"
+
+// ValuesMatch compares decoded OPC and Modbus values of the same data type
+// under the default tolerance
+func ValuesMatch(opcValue, modbusValue any) bool {
+	return Within(model.DefaultTolerance, opcValue, modbusValue)
+}
+
+// convertToFloat64 converts various numeric types to float64
+func convertToFloat64(value any) *float64 {
+	var f float64
+	switch v := value.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case int16:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	default:
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,412 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"opcmss/internal/model"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables that override values from the config file
+const (
+	EnvConfigFile        = "OPCMSS_CONFIG"
+	EnvProfile           = "OPCMSS_PROFILE"
+	EnvOPCEndpoint       = "OPCMSS_OPC_ENDPOINT"
+	EnvOPCNamespaceIndex = "OPCMSS_OPC_NAMESPACE_INDEX"
+	EnvOPCNodePrefix     = "OPCMSS_OPC_NODE_PREFIX"
+	EnvModbusEndpoint    = "OPCMSS_MODBUS_ENDPOINT"
+	EnvTagsFile          = "OPCMSS_TAGS_FILE"
+	EnvTagsToCompare     = "OPCMSS_TAGS_TO_COMPARE"
+	EnvModbusByteOrder   = "OPCMSS_MODBUS_BYTE_ORDER"
+	EnvTolerance         = "OPCMSS_TOLERANCE"
+	EnvOPCSecurityPolicy = "OPCMSS_OPC_SECURITY_POLICY"
+	EnvOPCSecurityMode   = "OPCMSS_OPC_SECURITY_MODE"
+	EnvOPCUserName       = "OPCMSS_OPC_USERNAME"
+	EnvOPCPassword       = "OPCMSS_OPC_PASSWORD"
+)
+
+// Config holds the settings for a single PLC
+type Config struct {
+	Name              string `json:"-"` // selected profile, empty without one
+	OPCEndpoint       string `json:"opc_endpoint"`
+	OPCNamespaceIndex uint16 `json:"opc_namespace_index"`
+	OPCNodePrefix     string `json:"opc_node_prefix"`
+	ModbusEndpoint    string `json:"modbus_endpoint"`   // host:port, or with a tcp, udp, rtu or rtuovertcp scheme
+	ModbusByteOrder   string `json:"modbus_byte_order"` // ABCD, CDAB, BADC or DCBA
+	TagsFile          string `json:"tags_file"`
+	TagsToCompare     int    `json:"tags_to_compare"`
+
+	// Serial line of an rtu:// Modbus endpoint, see modbus.Serial. Zero
+	// values keep the defaults: 19200 baud, no parity and 2 stop bits.
+	ModbusBaudRate int    `json:"modbus_baud_rate"`
+	ModbusParity   string `json:"modbus_parity"`    // none, even or odd
+	ModbusStopBits int    `json:"modbus_stop_bits"` // 1 or 2
+
+	// OPC UA security and login, see opcua.Security. Paths are relative to
+	// the config file.
+	OPCSecurityPolicy  string   `json:"opc_security_policy"` // None, Basic256Sha256, Aes128, Aes256, empty for the best available
+	OPCSecurityMode    string   `json:"opc_security_mode"`   // None, Sign or SignAndEncrypt, empty for any
+	OPCPreferPolicies  []string `json:"opc_prefer_policies"` // pick the endpoint by policy, most preferred first
+	OPCPreferModes     []string `json:"opc_prefer_modes"`    // and by mode, instead of a fixed policy and mode
+	OPCCertificate     string   `json:"opc_certificate"`     // client certificate, generated when empty
+	OPCPrivateKey      string   `json:"opc_private_key"`
+	OPCPKIDir          string   `json:"opc_pki_dir"`       // generated and rejected certificates
+	OPCTrustedCerts    string   `json:"opc_trusted_certs"` // empty skips server certificate verification
+	OPCUserName        string   `json:"opc_username"`
+	OPCPassword        string   `json:"opc_password"`
+	OPCUserCertificate string   `json:"opc_user_certificate"`
+	OPCUserKey         string   `json:"opc_user_key"`
+
+	// Modbus block reads, see modbus.BatchOptions
+	ModbusMaxGap       uint16 `json:"modbus_max_gap"`
+	ModbusMaxRegisters uint16 `json:"modbus_max_registers"`
+	ModbusMaxCoils     uint16 `json:"modbus_max_coils"`
+
+	// How long a single request may take, like "10s". An OPC UA request
+	// includes its retries, "0s" for no limit. A Modbus request is bounded
+	// by the time the server has to answer.
+	OPCTimeout    string `json:"opc_timeout"`
+	ModbusTimeout string `json:"modbus_timeout"`
+
+	// Recovery from failed calls and lost connections, see retry.Recovery.
+	// Backoffs are durations like "200ms" that double with every attempt.
+	RequestRetries  int    `json:"request_retries"`   // retries of a call that failed with a transient error
+	RetryBackoff    string `json:"retry_backoff"`     // before the first retry or reconnect
+	RetryMaxBackoff string `json:"retry_max_backoff"` // limit of the doubled backoff
+
+	// Load on the servers. OPC UA workers bound the requests in flight, and
+	// each Modbus worker holds its own connection, so keep that within what
+	// the device accepts. The request rate covers both protocols together,
+	// as they usually reach the same controller; 0 means no limit.
+	OPCWorkers    int     `json:"opc_workers"`
+	ModbusWorkers int     `json:"modbus_workers"`
+	RequestRate   float64 `json:"request_rate"` // requests per second
+
+	// Tolerances for comparing values, see compare.ToleranceRules. A
+	// tolerance in the tag file wins over a pattern, which wins over a type.
+	Tolerance         string             `json:"tolerance"`          // default for every tag, like "abs:0.001"
+	TypeTolerances    map[string]string  `json:"type_tolerances"`    // by data type, like {"REAL": "ulp:4"}
+	PatternTolerances []PatternTolerance `json:"pattern_tolerances"` // by tag name glob, the first match wins
+}
+
+// PatternTolerance sets the tolerance of the tags whose name matches a glob
+type PatternTolerance struct {
+	Pattern   string `json:"pattern"`
+	Tolerance string `json:"tolerance"`
+}
+
+// File is the on-disk layout of a config file. Top-level settings are shared
+// by every profile, and each profile only needs to list what it changes.
+type File struct {
+	DefaultProfile string                     `json:"default_profile"`
+	Profiles       map[string]json.RawMessage `json:"profiles"`
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() Config {
+	return Config{
+		OPCEndpoint:        "opc.tcp://localhost:4840",
+		OPCNamespaceIndex:  4,
+		ModbusEndpoint:     "localhost:502",
+		ModbusByteOrder:    "ABCD",
+		TagsFile:           "example_tags.tsv",
+		TagsToCompare:      20,
+		ModbusMaxRegisters: 125,
+		ModbusMaxCoils:     2000,
+		OPCTimeout:         "10s",
+		ModbusTimeout:      "1s",
+		RequestRetries:     2,
+		RetryBackoff:       "200ms",
+		RetryMaxBackoff:    "30s",
+		OPCWorkers:         4,
+		ModbusWorkers:      1,
+		Tolerance:          model.DefaultTolerance.String(),
+	}
+}
+
+// Load builds the configuration from defaults, the config file at path and
+// the environment, in that order of precedence. An empty path skips the file.
+// An empty profile selects the file's default_profile, if any. Files ending
+// in .yaml, .yml or .toml are read as such, any other file as JSON.
+func Load(path, profile string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := loadFile(&cfg, path, profile); err != nil {
+			return Config{}, err
+		}
+	} else if profile != "" {
+		return Config{}, fmt.Errorf("profile %q requested but no config file given", profile)
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path, profile string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if data, err = toJSON(path, data); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	// Relative paths in the config are relative to the config itself. Only
+	// the settings the file sets are resolved, whatever their value.
+	set := map[string]bool{}
+	defer func() {
+		for key, p := range cfg.paths() {
+			if set[key] && *p != "" && !filepath.IsAbs(*p) {
+				*p = filepath.Join(filepath.Dir(path), *p)
+			}
+		}
+	}()
+
+	// Shared settings live at the top level of the file
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := addKeys(set, data); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	if profile == "" {
+		return nil
+	}
+
+	raw, ok := file.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s (available: %v)", profile, path, ProfileNames(file))
+	}
+
+	// Unmarshalling over the shared settings only replaces the fields the
+	// profile actually sets
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return fmt.Errorf("failed to parse profile %q: %w", profile, err)
+	}
+	if err := addKeys(set, raw); err != nil {
+		return fmt.Errorf("failed to parse profile %q: %w", profile, err)
+	}
+	cfg.Name = profile
+	return nil
+}
+
+// toJSON converts a YAML or TOML config file to JSON, so that every format
+// shares the same keys and profile handling. JSON is returned unchanged.
+func toJSON(path string, data []byte) ([]byte, error) {
+	var v map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+	if v == nil {
+		v = map[string]any{} // an empty YAML file
+	}
+	return json.Marshal(v)
+}
+
+// addKeys adds the keys of the JSON object in data to set
+func addKeys(set map[string]bool, data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key := range fields {
+		set[key] = true
+	}
+	return nil
+}
+
+// paths returns the settings that hold file or directory paths, by their
+// key in the config file
+func (c *Config) paths() map[string]*string {
+	return map[string]*string{
+		"tags_file":            &c.TagsFile,
+		"opc_certificate":      &c.OPCCertificate,
+		"opc_private_key":      &c.OPCPrivateKey,
+		"opc_pki_dir":          &c.OPCPKIDir,
+		"opc_trusted_certs":    &c.OPCTrustedCerts,
+		"opc_user_certificate": &c.OPCUserCertificate,
+		"opc_user_key":         &c.OPCUserKey,
+	}
+}
+
+func applyEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv(EnvOPCEndpoint); ok {
+		cfg.OPCEndpoint = v
+	}
+	if v, ok := os.LookupEnv(EnvOPCNamespaceIndex); ok {
+		if err := parseUint16(v, &cfg.OPCNamespaceIndex); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvOPCNamespaceIndex, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvOPCNodePrefix); ok {
+		cfg.OPCNodePrefix = v
+	}
+	if v, ok := os.LookupEnv(EnvModbusEndpoint); ok {
+		cfg.ModbusEndpoint = v
+	}
+	if v, ok := os.LookupEnv(EnvModbusByteOrder); ok {
+		cfg.ModbusByteOrder = v
+	}
+	if v, ok := os.LookupEnv(EnvTagsFile); ok {
+		cfg.TagsFile = v
+	}
+	if v, ok := os.LookupEnv(EnvOPCSecurityPolicy); ok {
+		cfg.OPCSecurityPolicy = v
+	}
+	if v, ok := os.LookupEnv(EnvOPCSecurityMode); ok {
+		cfg.OPCSecurityMode = v
+	}
+	if v, ok := os.LookupEnv(EnvOPCUserName); ok {
+		cfg.OPCUserName = v
+	}
+	if v, ok := os.LookupEnv(EnvOPCPassword); ok {
+		cfg.OPCPassword = v
+	}
+	if v, ok := os.LookupEnv(EnvTolerance); ok {
+		cfg.Tolerance = v
+	}
+	if v, ok := os.LookupEnv(EnvTagsToCompare); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvTagsToCompare, err)
+		}
+		cfg.TagsToCompare = n
+	}
+	return nil
+}
+
+// ProfileNames returns the profile names defined in a config file, sorted
+func ProfileNames(file File) []string {
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseUint16(s string, dst *uint16) error {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return err
+	}
+	*dst = uint16(v)
+	return nil
+}
+
+// Validate reports settings that would make the tool fail later on
+func (c Config) Validate() error {
+	if c.OPCEndpoint == "" {
+		return fmt.Errorf("opc_endpoint is required")
+	}
+	if c.ModbusEndpoint == "" {
+		return fmt.Errorf("modbus_endpoint is required")
+	}
+	if _, err := model.ParseByteOrder(c.ModbusByteOrder); err != nil {
+		return fmt.Errorf("modbus_byte_order: %w", err)
+	}
+	if c.ModbusBaudRate < 0 {
+		return fmt.Errorf("modbus_baud_rate cannot be negative, got %d", c.ModbusBaudRate)
+	}
+	switch c.ModbusParity {
+	case "", "none", "even", "odd":
+	default:
+		return fmt.Errorf("modbus_parity must be none, even or odd, got %q", c.ModbusParity)
+	}
+	if c.ModbusStopBits < 0 || c.ModbusStopBits > 2 {
+		return fmt.Errorf("modbus_stop_bits must be 1 or 2, got %d", c.ModbusStopBits)
+	}
+	if c.TagsFile == "" {
+		return fmt.Errorf("tags_file is required")
+	}
+	if (c.OPCCertificate == "") != (c.OPCPrivateKey == "") {
+		return fmt.Errorf("opc_certificate and opc_private_key must be set together")
+	}
+	if (c.OPCUserCertificate == "") != (c.OPCUserKey == "") {
+		return fmt.Errorf("opc_user_certificate and opc_user_key must be set together")
+	}
+	if (len(c.OPCPreferPolicies) > 0 || len(c.OPCPreferModes) > 0) && (c.OPCSecurityPolicy != "" || c.OPCSecurityMode != "") {
+		return fmt.Errorf("opc_prefer_policies and opc_prefer_modes cannot be combined with opc_security_policy or opc_security_mode")
+	}
+	if c.OPCUserName != "" && c.OPCUserCertificate != "" {
+		return fmt.Errorf("opc_username and opc_user_certificate cannot both be set")
+	}
+	if c.TagsToCompare < 1 {
+		return fmt.Errorf("tags_to_compare must be at least 1, got %d", c.TagsToCompare)
+	}
+	if d, err := time.ParseDuration(c.OPCTimeout); err != nil || d < 0 {
+		return fmt.Errorf("opc_timeout must be a duration of 0s or more, got %q", c.OPCTimeout)
+	}
+	if d, err := time.ParseDuration(c.ModbusTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("modbus_timeout must be a positive duration, got %q", c.ModbusTimeout)
+	}
+	if c.RequestRetries < 0 {
+		return fmt.Errorf("request_retries cannot be negative, got %d", c.RequestRetries)
+	}
+	if _, err := time.ParseDuration(c.RetryBackoff); err != nil {
+		return fmt.Errorf("retry_backoff: %w", err)
+	}
+	if _, err := time.ParseDuration(c.RetryMaxBackoff); err != nil {
+		return fmt.Errorf("retry_max_backoff: %w", err)
+	}
+	if c.OPCWorkers < 1 {
+		return fmt.Errorf("opc_workers must be at least 1, got %d", c.OPCWorkers)
+	}
+	if c.ModbusWorkers < 1 {
+		return fmt.Errorf("modbus_workers must be at least 1, got %d", c.ModbusWorkers)
+	}
+	if c.RequestRate < 0 {
+		return fmt.Errorf("request_rate cannot be negative, got %g", c.RequestRate)
+	}
+	if _, err := model.ParseTolerance(c.Tolerance); err != nil {
+		return fmt.Errorf("tolerance: %w", err)
+	}
+	for dataType, tolerance := range c.TypeTolerances {
+		if _, err := model.ParseDataType(dataType); err != nil {
+			return fmt.Errorf("type_tolerances: %w", err)
+		}
+		if _, err := model.ParseTolerance(tolerance); err != nil {
+			return fmt.Errorf("type_tolerances[%s]: %w", dataType, err)
+		}
+	}
+	for _, p := range c.PatternTolerances {
+		if _, err := path.Match(p.Pattern, ""); err != nil {
+			return fmt.Errorf("pattern_tolerances: invalid pattern %q: %w", p.Pattern, err)
+		}
+		if _, err := model.ParseTolerance(p.Tolerance); err != nil {
+			return fmt.Errorf("pattern_tolerances[%s]: %w", p.Pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+  "tags_file": "tags.tsv",
+  "opc_namespace_index": 4,
+  "default_profile": "plant",
+  "profiles": {
+    "plant": {
+      "opc_endpoint": "opc.tcp://10.0.0.1:4840",
+      "opc_node_prefix": "|var|PLC.",
+      "modbus_endpoint": "10.0.0.1:502"
+    },
+    "lab": {
+      "opc_endpoint": "opc.tcp://lab:4840",
+      "opc_namespace_index": 2,
+      "tags_to_compare": 5
+    }
+  }
+}`
+
+func writeConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "opcmss.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_NoFile(t *testing.T) {
+	cfg, err := Load("", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Expected defaults, got: %+v", cfg)
+	}
+}
+
+func TestLoad_DefaultProfile(t *testing.T) {
+	path := writeConfig(t)
+
+	cfg, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.OPCEndpoint != "opc.tcp://10.0.0.1:4840" {
+		t.Errorf("Expected plant endpoint, got: %s", cfg.OPCEndpoint)
+	}
+	if cfg.OPCNodePrefix != "|var|PLC." {
+		t.Errorf("Expected plant prefix, got: %s", cfg.OPCNodePrefix)
+	}
+	if cfg.OPCNamespaceIndex != 4 {
+		t.Errorf("Expected shared namespace 4, got: %d", cfg.OPCNamespaceIndex)
+	}
+	if cfg.TagsFile != filepath.Join(filepath.Dir(path), "tags.tsv") {
+		t.Errorf("Expected tags file relative to config, got: %s", cfg.TagsFile)
+	}
+	if cfg.TagsToCompare != 20 {
+		t.Errorf("Expected default tags_to_compare 20, got: %d", cfg.TagsToCompare)
+	}
+}
+
+func TestLoad_NamedProfile(t *testing.T) {
+	cfg, err := Load(writeConfig(t), "lab")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.OPCEndpoint != "opc.tcp://lab:4840" {
+		t.Errorf("Expected lab endpoint, got: %s", cfg.OPCEndpoint)
+	}
+	if cfg.OPCNamespaceIndex != 2 {
+		t.Errorf("Expected profile namespace 2, got: %d", cfg.OPCNamespaceIndex)
+	}
+	if cfg.ModbusEndpoint != "localhost:502" {
+		t.Errorf("Expected default modbus endpoint, got: %s", cfg.ModbusEndpoint)
+	}
+	if cfg.TagsToCompare != 5 {
+		t.Errorf("Expected tags_to_compare 5, got: %d", cfg.TagsToCompare)
+	}
+}
+
+func TestLoad_YAMLAndTOML(t *testing.T) {
+	files := map[string]string{
+		"opcmss.yaml": `tags_file: tags.tsv
+opc_namespace_index: 4
+default_profile: plant
+type_tolerances:
+  REAL: ulp:4
+profiles:
+  plant:
+    opc_endpoint: opc.tcp://10.0.0.1:4840
+  lab:
+    opc_endpoint: opc.tcp://lab:4840
+    tags_to_compare: 5
+`,
+		"opcmss.toml": `tags_file = "tags.tsv"
+opc_namespace_index = 4
+default_profile = "plant"
+
+[type_tolerances]
+REAL = "ulp:4"
+
+[profiles.plant]
+opc_endpoint = "opc.tcp://10.0.0.1:4840"
+
+[profiles.lab]
+opc_endpoint = "opc.tcp://lab:4840"
+tags_to_compare = 5
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := Load(path, "lab")
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", name, err)
+		}
+		if cfg.Name != "lab" || cfg.OPCEndpoint != "opc.tcp://lab:4840" || cfg.TagsToCompare != 5 {
+			t.Errorf("%s: expected the lab profile, got %s at %s comparing %d", name, cfg.Name, cfg.OPCEndpoint, cfg.TagsToCompare)
+		}
+		if cfg.OPCNamespaceIndex != 4 || cfg.TypeTolerances["REAL"] != "ulp:4" {
+			t.Errorf("%s: expected the shared settings, got namespace %d and %v", name, cfg.OPCNamespaceIndex, cfg.TypeTolerances)
+		}
+		if cfg.TagsFile != filepath.Join(filepath.Dir(path), "tags.tsv") {
+			t.Errorf("%s: expected tags file relative to config, got: %s", name, cfg.TagsFile)
+		}
+
+		if cfg, err = Load(path, ""); err != nil || cfg.OPCEndpoint != "opc.tcp://10.0.0.1:4840" {
+			t.Errorf("%s: expected the default profile, got %s (err %v)", name, cfg.OPCEndpoint, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.yml")
+	if err := os.WriteFile(path, []byte("profiles: [plant"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, ""); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected a parse error naming %s, got: %v", path, err)
+	}
+}
+
+func TestLoad_UnknownProfile(t *testing.T) {
+	_, err := Load(writeConfig(t), "missing")
+	if err == nil {
+		t.Fatal("Expected error for unknown profile, got none")
+	}
+	if !strings.Contains(err.Error(), "[lab plant]") {
+		t.Errorf("Expected available profiles in error, got: %v", err)
+	}
+}
+
+func TestLoad_EnvOverrides(t *testing.T) {
+	t.Setenv(EnvModbusEndpoint, "192.168.1.5:502")
+	t.Setenv(EnvOPCNamespaceIndex, "7")
+
+	cfg, err := Load(writeConfig(t), "plant")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.ModbusEndpoint != "192.168.1.5:502" {
+		t.Errorf("Expected env modbus endpoint, got: %s", cfg.ModbusEndpoint)
+	}
+	if cfg.OPCNamespaceIndex != 7 {
+		t.Errorf("Expected env namespace 7, got: %d", cfg.OPCNamespaceIndex)
+	}
+}
+
+func TestLoad_InvalidEnv(t *testing.T) {
+	t.Setenv(EnvTagsToCompare, "many")
+
+	if _, err := Load("", ""); err == nil {
+		t.Fatal("Expected error for invalid env value, got none")
+	}
+}
+
+func TestFlags_OverrideEnvAndFile(t *testing.T) {
+	t.Setenv(EnvOPCEndpoint, "opc.tcp://env:4840")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs)
+	err := fs.Parse([]string{"-config", writeConfig(t), "-profile", "lab", "-opc-endpoint", "opc.tcp://flag:4840", "-opc-namespace", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.OPCEndpoint != "opc.tcp://flag:4840" {
+		t.Errorf("Expected flag endpoint, got: %s", cfg.OPCEndpoint)
+	}
+	if cfg.OPCNamespaceIndex != 3 {
+		t.Errorf("Expected flag namespace 3, got: %d", cfg.OPCNamespaceIndex)
+	}
+	// Unset flags must not clobber the profile
+	if cfg.TagsToCompare != 5 {
+		t.Errorf("Expected profile tags_to_compare 5, got: %d", cfg.TagsToCompare)
+	}
+}
+
+func TestValidate_Tolerances(t *testing.T) {
+	cfg := Default()
+	cfg.TypeTolerances = map[string]string{"real": "ulp:4"}
+	cfg.PatternTolerances = []PatternTolerance{{Pattern: "Flow*", Tolerance: "rel:1%"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid tolerances, got: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*Config){
+		"default": func(c *Config) { c.Tolerance = "close" },
+		"type":    func(c *Config) { c.TypeTolerances = map[string]string{"REAL": "abs:-1"} },
+		"unknown": func(c *Config) { c.TypeTolerances = map[string]string{"FLOAT": "exact"} },
+		"pattern": func(c *Config) { c.PatternTolerances = []PatternTolerance{{Pattern: "[", Tolerance: "exact"}} },
+	} {
+		bad := Default()
+		mutate(&bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestLoad_ToleranceEnv(t *testing.T) {
+	t.Setenv(EnvTolerance, "rel:0.1%")
+
+	cfg, err := Load("", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Tolerance != "rel:0.1%" {
+		t.Errorf("Expected env tolerance, got: %s", cfg.Tolerance)
+	}
+}
+
+func TestLoad_DefaultValuedPathRelativeToConfig(t *testing.T) {
+	// A path the file sets is resolved even when it equals the default, so
+	// the config works from any directory
+	dir := t.TempDir()
+	path := filepath.Join(dir, "opcmss.json")
+	data := `{"tags_file": "` + Default().TagsFile + `", "profiles": {"lab": {"opc_pki_dir": "pki"}}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, "lab")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.TagsFile != filepath.Join(dir, Default().TagsFile) {
+		t.Errorf("Expected tags file relative to config, got: %s", cfg.TagsFile)
+	}
+	if cfg.OPCPKIDir != filepath.Join(dir, "pki") {
+		t.Errorf("Expected profile PKI dir relative to config, got: %s", cfg.OPCPKIDir)
+	}
+}
+
+func TestLoad_SecurityPathsRelativeToConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "opcmss.json")
+	data := `{"opc_certificate": "pki/client.pem", "opc_private_key": "/etc/opcmss/client.key", "opc_security_policy": "Basic256Sha256"}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvOPCPassword, "secret")
+
+	cfg, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.OPCCertificate != filepath.Join(dir, "pki", "client.pem") {
+		t.Errorf("Expected certificate relative to config, got: %s", cfg.OPCCertificate)
+	}
+	if cfg.OPCPrivateKey != "/etc/opcmss/client.key" {
+		t.Errorf("Expected absolute key path unchanged, got: %s", cfg.OPCPrivateKey)
+	}
+	if cfg.OPCPKIDir != "" {
+		t.Errorf("Expected unset PKI dir to stay empty, got: %s", cfg.OPCPKIDir)
+	}
+	if cfg.OPCPassword != "secret" {
+		t.Errorf("Expected password from the environment, got: %q", cfg.OPCPassword)
+	}
+
+	cfg.OPCPrivateKey = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an error for a certificate without a key")
+	}
+}
+
+func TestValidate_RecoveryAndTimeouts(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs)
+	if err := fs.Parse([]string{"-request-retries", "0", "-retry-backoff", "1s", "-opc-timeout", "0s"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := flags.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.RequestRetries != 0 || cfg.RetryBackoff != "1s" || cfg.RetryMaxBackoff != "30s" {
+		t.Errorf("Expected retries 0, backoff 1s and max 30s, got: %d, %s, %s", cfg.RequestRetries, cfg.RetryBackoff, cfg.RetryMaxBackoff)
+	}
+	if cfg.OPCTimeout != "0s" || cfg.ModbusTimeout != "1s" {
+		t.Errorf("Expected no OPC UA timeout and a 1s Modbus timeout, got: %s, %s", cfg.OPCTimeout, cfg.ModbusTimeout)
+	}
+
+	for name, mutate := range map[string]func(*Config){
+		"retries":     func(c *Config) { c.RequestRetries = -1 },
+		"backoff":     func(c *Config) { c.RetryBackoff = "soon" },
+		"max backoff": func(c *Config) { c.RetryMaxBackoff = "" },
+		"opc timeout": func(c *Config) { c.OPCTimeout = "-1s" },
+		"modbus zero": func(c *Config) { c.ModbusTimeout = "0s" },
+	} {
+		bad := Default()
+		mutate(&bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestValidate_Workers(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs)
+	if err := fs.Parse([]string{"-modbus-workers", "2", "-rate", "50"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := flags.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.OPCWorkers != 4 || cfg.ModbusWorkers != 2 || cfg.RequestRate != 50 {
+		t.Errorf("Expected 4 OPC UA workers, 2 Modbus workers and 50 requests/s, got: %d, %d, %g", cfg.OPCWorkers, cfg.ModbusWorkers, cfg.RequestRate)
+	}
+
+	for name, mutate := range map[string]func(*Config){
+		"opc workers":    func(c *Config) { c.OPCWorkers = 0 },
+		"modbus workers": func(c *Config) { c.ModbusWorkers = 0 },
+		"rate":           func(c *Config) { c.RequestRate = -1 },
+	} {
+		bad := Default()
+		mutate(&bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestValidate_Serial(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs)
+	if err := fs.Parse([]string{"-modbus-endpoint", "rtu:///dev/ttyUSB0", "-baud", "9600", "-parity", "even", "-stop-bits", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := flags.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.ModbusEndpoint != "rtu:///dev/ttyUSB0" || cfg.ModbusBaudRate != 9600 || cfg.ModbusParity != "even" || cfg.ModbusStopBits != 1 {
+		t.Errorf("Expected an RTU endpoint at 9600 8E1, got: %s, %d, %s, %d", cfg.ModbusEndpoint, cfg.ModbusBaudRate, cfg.ModbusParity, cfg.ModbusStopBits)
+	}
+
+	for name, mutate := range map[string]func(*Config){
+		"baud":      func(c *Config) { c.ModbusBaudRate = -1 },
+		"parity":    func(c *Config) { c.ModbusParity = "mark" },
+		"stop bits": func(c *Config) { c.ModbusStopBits = 3 },
+	} {
+		bad := Default()
+		mutate(&bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
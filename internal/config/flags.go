@@ -0,0 +1,174 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// Flags binds command line flags for every setting. Flags take precedence
+// over both the config file and the environment.
+type Flags struct {
+	ConfigFile string
+	Profile    string
+
+	fs     *flag.FlagSet
+	values Config
+}
+
+// BindFlags registers the configuration flags on fs
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	def := Default()
+
+	fs.StringVar(&f.ConfigFile, "config", os.Getenv(EnvConfigFile), "path to config file, JSON, YAML (.yaml, .yml) or TOML (.toml)")
+	fs.StringVar(&f.Profile, "profile", os.Getenv(EnvProfile), "named PLC profile from the config file")
+	fs.StringVar(&f.values.OPCEndpoint, "opc-endpoint", def.OPCEndpoint, "OPC UA endpoint URL")
+	fs.Func("opc-namespace", "OPC UA namespace index (default 4)", func(s string) error {
+		return parseUint16(s, &f.values.OPCNamespaceIndex)
+	})
+	fs.StringVar(&f.values.OPCNodePrefix, "opc-prefix", def.OPCNodePrefix, "prefix prepended to tag names to build NodeIDs")
+	fs.StringVar(&f.values.OPCSecurityPolicy, "opc-security-policy", def.OPCSecurityPolicy, "OPC UA security policy: None, Basic256Sha256, Aes128 or Aes256 (default: best available)")
+	fs.StringVar(&f.values.OPCSecurityMode, "opc-security-mode", def.OPCSecurityMode, "OPC UA security mode: None, Sign or SignAndEncrypt (default: any)")
+	fs.Func("opc-prefer", "pick the OPC UA endpoint by these security policies, most preferred first (comma separated)", func(s string) error {
+		f.values.OPCPreferPolicies = splitList(s)
+		return nil
+	})
+	fs.Func("opc-prefer-mode", "pick the OPC UA endpoint by these security modes, most preferred first (comma separated)", func(s string) error {
+		f.values.OPCPreferModes = splitList(s)
+		return nil
+	})
+	fs.StringVar(&f.values.OPCCertificate, "opc-cert", def.OPCCertificate, "OPC UA client certificate PEM file (default: generated in the PKI directory)")
+	fs.StringVar(&f.values.OPCPrivateKey, "opc-key", def.OPCPrivateKey, "OPC UA client private key PEM file")
+	fs.StringVar(&f.values.OPCPKIDir, "opc-pki", def.OPCPKIDir, "directory for generated and rejected OPC UA certificates")
+	fs.StringVar(&f.values.OPCTrustedCerts, "opc-trusted", def.OPCTrustedCerts, "trusted OPC UA server certificates: file, list or directory (default: no verification)")
+	fs.StringVar(&f.values.OPCUserName, "opc-user", def.OPCUserName, "OPC UA user name, the password is read from $"+EnvOPCPassword)
+	fs.StringVar(&f.values.OPCUserCertificate, "opc-user-cert", def.OPCUserCertificate, "OPC UA X.509 user certificate PEM file")
+	fs.StringVar(&f.values.OPCUserKey, "opc-user-key", def.OPCUserKey, "OPC UA X.509 user private key PEM file")
+	fs.StringVar(&f.values.ModbusEndpoint, "modbus-endpoint", def.ModbusEndpoint, "Modbus endpoint: host:port, udp://host:port, rtuovertcp://host:port for a serial gateway or rtu:///dev/ttyUSB0")
+	fs.IntVar(&f.values.ModbusBaudRate, "baud", def.ModbusBaudRate, "serial speed of an rtu:// Modbus endpoint (default 19200)")
+	fs.StringVar(&f.values.ModbusParity, "parity", def.ModbusParity, "serial parity of an rtu:// Modbus endpoint: none, even or odd (default none)")
+	fs.IntVar(&f.values.ModbusStopBits, "stop-bits", def.ModbusStopBits, "serial stop bits of an rtu:// Modbus endpoint (default 2 without parity, 1 with)")
+	fs.StringVar(&f.values.ModbusByteOrder, "byte-order", def.ModbusByteOrder, "byte order of 32/64-bit Modbus values: ABCD, CDAB, BADC or DCBA")
+	fs.StringVar(&f.values.TagsFile, "tags", def.TagsFile, "Modbus tags TSV file")
+	fs.IntVar(&f.values.TagsToCompare, "count", def.TagsToCompare, "number of tags to compare")
+	fs.Func("max-gap", "unused Modbus addresses allowed inside one block read (default 0)", func(s string) error {
+		return parseUint16(s, &f.values.ModbusMaxGap)
+	})
+	fs.Func("max-registers", "largest Modbus register block read (default 125)", func(s string) error {
+		return parseUint16(s, &f.values.ModbusMaxRegisters)
+	})
+	fs.Func("max-coils", "largest Modbus coil block read (default 2000)", func(s string) error {
+		return parseUint16(s, &f.values.ModbusMaxCoils)
+	})
+	fs.StringVar(&f.values.OPCTimeout, "opc-timeout", def.OPCTimeout, "how long an OPC UA request may take, retries included, 0s for no limit")
+	fs.StringVar(&f.values.ModbusTimeout, "modbus-timeout", def.ModbusTimeout, "how long the Modbus server has to answer a request")
+	fs.IntVar(&f.values.RequestRetries, "request-retries", def.RequestRetries, "retries of a read or write that failed with a transient error, like a timeout or a lost connection")
+	fs.StringVar(&f.values.RetryBackoff, "retry-backoff", def.RetryBackoff, "wait before the first retry or reconnect, doubling after each")
+	fs.StringVar(&f.values.RetryMaxBackoff, "retry-max-backoff", def.RetryMaxBackoff, "longest wait between retries or reconnects")
+	fs.IntVar(&f.values.OPCWorkers, "opc-workers", def.OPCWorkers, "OPC UA requests sent at the same time")
+	fs.IntVar(&f.values.ModbusWorkers, "modbus-workers", def.ModbusWorkers, "Modbus connections used at the same time")
+	fs.Float64Var(&f.values.RequestRate, "rate", def.RequestRate, "most requests per second to both servers together, 0 for no limit")
+
+	fs.StringVar(&f.values.Tolerance, "tolerance", def.Tolerance, "default tolerance for numeric values: exact, abs:<n>, rel:<n>% or ulp:<n>")
+
+	return f
+}
+
+// Load resolves the configuration from the parsed flags
+func (f *Flags) Load() (Config, error) {
+	cfg, err := Load(f.ConfigFile, f.Profile)
+	if err != nil {
+		return Config{}, err
+	}
+	f.Apply(&cfg)
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Apply copies the flags that were explicitly set onto cfg
+func (f *Flags) Apply(cfg *Config) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "opc-endpoint":
+			cfg.OPCEndpoint = f.values.OPCEndpoint
+		case "opc-namespace":
+			cfg.OPCNamespaceIndex = f.values.OPCNamespaceIndex
+		case "opc-prefix":
+			cfg.OPCNodePrefix = f.values.OPCNodePrefix
+		case "opc-security-policy":
+			cfg.OPCSecurityPolicy = f.values.OPCSecurityPolicy
+		case "opc-security-mode":
+			cfg.OPCSecurityMode = f.values.OPCSecurityMode
+		case "opc-prefer":
+			cfg.OPCPreferPolicies = f.values.OPCPreferPolicies
+		case "opc-prefer-mode":
+			cfg.OPCPreferModes = f.values.OPCPreferModes
+		case "opc-cert":
+			cfg.OPCCertificate = f.values.OPCCertificate
+		case "opc-key":
+			cfg.OPCPrivateKey = f.values.OPCPrivateKey
+		case "opc-pki":
+			cfg.OPCPKIDir = f.values.OPCPKIDir
+		case "opc-trusted":
+			cfg.OPCTrustedCerts = f.values.OPCTrustedCerts
+		case "opc-user":
+			cfg.OPCUserName = f.values.OPCUserName
+		case "opc-user-cert":
+			cfg.OPCUserCertificate = f.values.OPCUserCertificate
+		case "opc-user-key":
+			cfg.OPCUserKey = f.values.OPCUserKey
+		case "modbus-endpoint":
+			cfg.ModbusEndpoint = f.values.ModbusEndpoint
+		case "baud":
+			cfg.ModbusBaudRate = f.values.ModbusBaudRate
+		case "parity":
+			cfg.ModbusParity = f.values.ModbusParity
+		case "stop-bits":
+			cfg.ModbusStopBits = f.values.ModbusStopBits
+		case "byte-order":
+			cfg.ModbusByteOrder = f.values.ModbusByteOrder
+		case "tags":
+			cfg.TagsFile = f.values.TagsFile
+		case "count":
+			cfg.TagsToCompare = f.values.TagsToCompare
+		case "max-gap":
+			cfg.ModbusMaxGap = f.values.ModbusMaxGap
+		case "max-registers":
+			cfg.ModbusMaxRegisters = f.values.ModbusMaxRegisters
+		case "max-coils":
+			cfg.ModbusMaxCoils = f.values.ModbusMaxCoils
+		case "opc-timeout":
+			cfg.OPCTimeout = f.values.OPCTimeout
+		case "modbus-timeout":
+			cfg.ModbusTimeout = f.values.ModbusTimeout
+		case "request-retries":
+			cfg.RequestRetries = f.values.RequestRetries
+		case "retry-backoff":
+			cfg.RetryBackoff = f.values.RetryBackoff
+		case "retry-max-backoff":
+			cfg.RetryMaxBackoff = f.values.RetryMaxBackoff
+		case "opc-workers":
+			cfg.OPCWorkers = f.values.OPCWorkers
+		case "modbus-workers":
+			cfg.ModbusWorkers = f.values.ModbusWorkers
+		case "rate":
+			cfg.RequestRate = f.values.RequestRate
+		case "tolerance":
+			cfg.Tolerance = f.values.Tolerance
+		}
+	})
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,6 +2,8 @@ package converter
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"opcmss/internal/model"
 )
@@ -12,8 +14,11 @@ func ConvertModbusToOPC(modbusTag model.ModbusTag, namespaceIndex uint16, prefix
 	// Format: ns=<namespace>;s=<prefix><tag_name>
 	nodeID := fmt.Sprintf("ns=%d;s=%s%s", namespaceIndex, prefix, modbusTag.Name)
 
-	// Determine data type based on register type and size
-	dataType := determineDataType(modbusTag.RegisterType, modbusTag.Size)
+	// Use the declared data type, or derive one from register type and size
+	dataType := modbusTag.DataType
+	if dataType == "" {
+		dataType = determineDataType(modbusTag.RegisterType, modbusTag.Size)
+	}
 
 	return model.OPCTag{
 		Name:     modbusTag.Name,
@@ -24,20 +29,10 @@ func ConvertModbusToOPC(modbusTag model.ModbusTag, namespaceIndex uint16, prefix
 
 // determineDataType maps Modbus register types to OPC data types
 func determineDataType(registerType string, size uint16) string {
-	switch registerType {
-	case "HoldingRegister", "InputRegister":
-		switch size {
-		case 1:
-			return "INT" // 16-bit integer
-		case 2:
-			return "REAL" // 32-bit float (2 registers)
-		}
-		return "INT"
-	case "Coil", "DiscreteInput":
-		return "BOOL"
-	default:
-		return "INT"
+	if dataType := model.DefaultDataType(registerType, size); dataType != "" {
+		return dataType
 	}
+	return "INT"
 }
 
 // ConvertAllModbusToOPC converts all Modbus tags to OPC tags with configurable prefix
@@ -50,3 +45,98 @@ func ConvertAllModbusToOPC(modbusTags []model.ModbusTag, namespaceIndex uint16,
 
 	return opcTags
 }
+
+// TagName returns the tag name of a NodeID built by ConvertModbusToOPC with
+// the same namespace and prefix, and false for any other NodeID
+func TagName(nodeID string, namespaceIndex uint16, prefix string) (string, bool) {
+	name, ok := strings.CutPrefix(nodeID, fmt.Sprintf("ns=%d;s=%s", namespaceIndex, prefix))
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// ParseValue parses a textual value into the Go type used for the given OPC data type
+func ParseValue(dataType, text string) (any, error) {
+	text = strings.TrimSpace(text)
+
+	parseInt := func(bits int) (int64, error) {
+		v, err := strconv.ParseInt(text, 0, bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: %w", dataType, text, err)
+		}
+		return v, nil
+	}
+	parseUint := func(bits int) (uint64, error) {
+		v, err := strconv.ParseUint(text, 0, bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: %w", dataType, text, err)
+		}
+		return v, nil
+	}
+
+	switch dataType {
+	case model.TypeBOOL:
+		switch strings.ToLower(text) {
+		case "1", "true", "on":
+			return true, nil
+		case "0", "false", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid BOOL value %q", text)
+	case model.TypeINT:
+		v, err := parseInt(16)
+		if err != nil {
+			return nil, err
+		}
+		return int16(v), nil
+	case model.TypeUINT, model.TypeWORD:
+		v, err := parseUint(16)
+		if err != nil {
+			return nil, err
+		}
+		return uint16(v), nil
+	case model.TypeDINT:
+		v, err := parseInt(32)
+		if err != nil {
+			return nil, err
+		}
+		return int32(v), nil
+	case model.TypeUDINT, model.TypeDWORD:
+		v, err := parseUint(32)
+		if err != nil {
+			return nil, err
+		}
+		return uint32(v), nil
+	case model.TypeLINT:
+		v, err := parseInt(64)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case model.TypeREAL:
+		v, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REAL value %q: %w", text, err)
+		}
+		return float32(v), nil
+	case model.TypeLREAL:
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LREAL value %q: %w", text, err)
+		}
+		return v, nil
+	}
+
+	if model.IsString(dataType) {
+		length, err := model.StringLength(dataType)
+		if err != nil {
+			return nil, err
+		}
+		if len(text) > length {
+			return nil, fmt.Errorf("value %q is longer than %s", text, dataType)
+		}
+		return text, nil
+	}
+	return nil, fmt.Errorf("unsupported data type: %s", dataType)
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func TestConvertModbusToOPC_DataType(t *testing.T) {
+	testCases := []struct {
+		tag      model.ModbusTag
+		expected string
+	}{
+		{model.ModbusTag{Name: "A", RegisterType: "HoldingRegister", Size: 2, DataType: "DINT"}, "DINT"},
+		{model.ModbusTag{Name: "B", RegisterType: "HoldingRegister", Size: 2}, "REAL"},
+		{model.ModbusTag{Name: "C", RegisterType: "Coil", Size: 1}, "BOOL"},
+		{model.ModbusTag{Name: "D", RegisterType: "HoldingRegister", Size: 5}, "INT"},
+	}
+
+	for _, tc := range testCases {
+		opcTag := ConvertModbusToOPC(tc.tag, 4, "|var|PLC.")
+		if opcTag.DataType != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.tag.Name, tc.expected, opcTag.DataType)
+		}
+		if opcTag.NodeID != "ns=4;s=|var|PLC."+tc.tag.Name {
+			t.Errorf("%s: unexpected NodeID %s", tc.tag.Name, opcTag.NodeID)
+		}
+	}
+}
+
+func TestTagName(t *testing.T) {
+	testCases := []struct {
+		nodeID   string
+		expected string
+		ok       bool
+	}{
+		{"ns=4;s=|var|PLC.Pump.Running", "Pump.Running", true},
+		{"ns=2;s=|var|PLC.Pump.Running", "", false},
+		{"ns=4;s=|var|Other.Pump", "", false},
+		{"ns=4;s=|var|PLC.", "", false},
+		{"ns=4;i=2258", "", false},
+	}
+
+	for _, tc := range testCases {
+		name, ok := TagName(tc.nodeID, 4, "|var|PLC.")
+		if name != tc.expected || ok != tc.ok {
+			t.Errorf("%s: expected %q %v, got %q %v", tc.nodeID, tc.expected, tc.ok, name, ok)
+		}
+	}
+
+	tag := ConvertModbusToOPC(model.ModbusTag{Name: "O25.GT41", RegisterType: "Coil", Size: 1}, 4, "|var|PLC.")
+	if name, ok := TagName(tag.NodeID, 4, "|var|PLC."); !ok || name != tag.Name {
+		t.Errorf("Expected %s back from %s, got %q", tag.Name, tag.NodeID, name)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	testCases := []struct {
+		dataType string
+		text     string
+		expected any
+	}{
+		{"BOOL", "on", true},
+		{"BOOL", "0", false},
+		{"INT", "-12", int16(-12)},
+		{"UINT", "65535", uint16(65535)},
+		{"WORD", "0x1234", uint16(0x1234)},
+		{"DINT", "-70000", int32(-70000)},
+		{"DWORD", "0xDEADBEEF", uint32(0xDEADBEEF)},
+		{"REAL", "23.5", float32(23.5)},
+		{"LINT", "-5000000000", int64(-5000000000)},
+		{"LREAL", "0.1", float64(0.1)},
+		{"STRING[4]", "abcd", "abcd"},
+	}
+
+	for _, tc := range testCases {
+		got, err := ParseValue(tc.dataType, tc.text)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tc.dataType, tc.text, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("%s %q: expected %v (%T), got %v (%T)", tc.dataType, tc.text, tc.expected, tc.expected, got, got)
+		}
+	}
+}
+
+func TestParseValue_Invalid(t *testing.T) {
+	for _, tc := range [][2]string{
+		{"BOOL", "maybe"},
+		{"INT", "40000"},
+		{"UINT", "-1"},
+		{"REAL", "abc"},
+		{"STRING[2]", "abc"},
+		{"TIME", "1s"},
+	} {
+		if _, err := ParseValue(tc[0], tc[1]); err == nil {
+			t.Errorf("%s %q: expected error, got none", tc[0], tc[1])
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package coverage cross-references the tag map with the variables browsed
+// from the OPC UA server, to find tags without a node, nodes without a tag
+// and tags whose declared type differs from the node's.
+package coverage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"opcmss/internal/converter"
+	"opcmss/internal/model"
+	"opcmss/internal/opcua"
+)
+
+// Status classifies a symbol of the tag map or the address space
+type Status string
+
+const (
+	StatusBoth         Status = "both"          // in the tag map and on the server with the same type
+	StatusModbusOnly   Status = "modbus_only"   // in the tag map without a node
+	StatusOPCOnly      Status = "opc_only"      // on the server but not in the tag map
+	StatusTypeMismatch Status = "type_mismatch" // in both, with a different type
+)
+
+// Entry is one symbol and where it was found
+type Entry struct {
+	Name          string `json:"name"`
+	Status        Status `json:"status"`
+	NodeID        string `json:"node_id"`
+	DataType      string `json:"data_type,omitempty"` // declared or derived IEC type of the tag
+	RegisterType  string `json:"register_type,omitempty"`
+	ModbusAddress uint32 `json:"modbus_address,omitempty"`
+	OPCDataType   string `json:"opc_data_type,omitempty"` // OPC UA type of the node, like "Float"
+	OPCIECType    string `json:"opc_iec_type,omitempty"`  // IEC type the node is read as
+	Path          string `json:"path,omitempty"`          // browse path of the node
+}
+
+// Summary counts the entries by status
+type Summary struct {
+	Total         int `json:"total"`
+	Both          int `json:"both"`
+	ModbusOnly    int `json:"modbus_only"`
+	OPCOnly       int `json:"opc_only"`
+	TypeMismatch  int `json:"type_mismatch"`
+	TagsInMap     int `json:"tags_in_map"`
+	VariablesSeen int `json:"variables_seen"`
+}
+
+// Complete reports whether every tag of the map has a node of its type.
+// Variables that are only on the server do not count against it.
+func (s Summary) Complete() bool {
+	return s.ModbusOnly == 0 && s.TypeMismatch == 0
+}
+
+// Report is the coverage of the tag map by the address space
+type Report struct {
+	Name    string  `json:"name"` // typically the config profile
+	Summary Summary `json:"summary"`
+	Entries []Entry `json:"entries"`
+}
+
+// Build classifies every tag of the map and every browsed variable. Tags are
+// matched to variables by NodeID, so opcTags must be the converted modbusTags.
+// The tags come first in tag map order, followed by the variables that are not
+// in the map, named as the tag map would name them for namespaceIndex and
+// prefix.
+func Build(modbusTags []model.ModbusTag, opcTags []model.OPCTag, variables []opcua.Variable, namespaceIndex uint16, prefix string) Report {
+	byNodeID := make(map[string]opcua.Variable, len(variables))
+	for _, v := range variables {
+		byNodeID[v.NodeID] = v
+	}
+
+	var r Report
+	mapped := make(map[string]bool, len(opcTags))
+	for i, tag := range opcTags {
+		entry := Entry{
+			Name:          tag.Name,
+			NodeID:        tag.NodeID,
+			DataType:      tag.DataType,
+			RegisterType:  modbusTags[i].RegisterType,
+			ModbusAddress: modbusTags[i].ModbusAddress,
+		}
+		v, ok := byNodeID[tag.NodeID]
+		switch {
+		case !ok:
+			entry.Status = StatusModbusOnly
+		case !TypesMatch(tag.DataType, v.IECType):
+			entry.Status = StatusTypeMismatch
+		default:
+			entry.Status = StatusBoth
+		}
+		if ok {
+			entry.OPCDataType, entry.OPCIECType, entry.Path = v.DataType, v.IECType, v.Path
+		}
+		mapped[tag.NodeID] = true
+		r.add(entry)
+	}
+
+	for _, v := range variables {
+		if mapped[v.NodeID] {
+			continue
+		}
+		r.add(Entry{
+			Name:        Name(v, namespaceIndex, prefix),
+			Status:      StatusOPCOnly,
+			NodeID:      v.NodeID,
+			OPCDataType: v.DataType,
+			OPCIECType:  v.IECType,
+			Path:        v.Path,
+		})
+	}
+
+	r.Summary.TagsInMap = len(opcTags)
+	r.Summary.VariablesSeen = len(variables)
+	return r
+}
+
+func (r *Report) add(entry Entry) {
+	r.Entries = append(r.Entries, entry)
+	r.Summary.Total++
+	switch entry.Status {
+	case StatusBoth:
+		r.Summary.Both++
+	case StatusModbusOnly:
+		r.Summary.ModbusOnly++
+	case StatusOPCOnly:
+		r.Summary.OPCOnly++
+	case StatusTypeMismatch:
+		r.Summary.TypeMismatch++
+	}
+}
+
+// Name returns the tag name of a variable for the configured namespace and
+// prefix, or its browse path when its NodeID does not follow them
+func Name(v opcua.Variable, namespaceIndex uint16, prefix string) string {
+	if name, ok := converter.TagName(v.NodeID, namespaceIndex, prefix); ok {
+		return name
+	}
+	return v.Path
+}
+
+// TypesMatch reports whether a tag of the IEC data type can be read from a
+// node served as opcIECType, see opcua.Variable. WORD and DWORD are served as
+// UINT and UDINT, and strings of any length as STRING.
+func TypesMatch(dataType, opcIECType string) bool {
+	switch {
+	case dataType == model.TypeWORD:
+		dataType = model.TypeUINT
+	case dataType == model.TypeDWORD:
+		dataType = model.TypeUDINT
+	case model.IsString(dataType):
+		dataType = model.TypeSTRING
+	}
+	return opcIECType != "" && dataType == opcIECType
+}
+
+// WriteJSON writes the report as indented JSON
+func WriteJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per entry, separated by comma
+func WriteCSV(w io.Writer, r Report, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	cw.Write([]string{
+		"name", "status", "node_id", "data_type", "register_type", "modbus_address",
+		"opc_data_type", "opc_iec_type", "path",
+	})
+	for _, e := range r.Entries {
+		address := ""
+		if e.RegisterType != "" {
+			address = strconv.FormatUint(uint64(e.ModbusAddress), 10)
+		}
+		cw.Write([]string{
+			e.Name,
+			string(e.Status),
+			e.NodeID,
+			e.DataType,
+			e.RegisterType,
+			address,
+			e.OPCDataType,
+			e.OPCIECType,
+			e.Path,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
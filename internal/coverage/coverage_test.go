@@ -0,0 +1,134 @@
+package coverage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"opcmss/internal/converter"
+	"opcmss/internal/model"
+	"opcmss/internal/opcua"
+)
+
+func testReport() Report {
+	modbusTags := []model.ModbusTag{
+		{Name: "Pump.Run", RegisterType: "Coil", Size: 1, ModbusAddress: 1},
+		{Name: "Tank.Level", RegisterType: "HoldingRegister", Size: 2, ModbusAddress: 400010},
+		{Name: "Tank.Mask", RegisterType: "HoldingRegister", Size: 1, ModbusAddress: 400012, DataType: "WORD"},
+		{Name: "Valve.Pos", RegisterType: "HoldingRegister", Size: 2, ModbusAddress: 400014, DataType: "DINT"},
+		{Name: "Tank.Label", RegisterType: "HoldingRegister", Size: 5, ModbusAddress: 400020, DataType: "STRING[10]"},
+	}
+	opcTags := converter.ConvertAllModbusToOPC(modbusTags, 4, "PLC.")
+	variables := []opcua.Variable{
+		{NodeID: "ns=4;s=PLC.Pump.Run", Path: "PLC/Pump/Run", DataType: "Boolean", IECType: "BOOL"},
+		{NodeID: "ns=4;s=PLC.Tank.Level", Path: "PLC/Tank/Level", DataType: "Double", IECType: "LREAL"},
+		{NodeID: "ns=4;s=PLC.Tank.Mask", Path: "PLC/Tank/Mask", DataType: "UInt16", IECType: "UINT"},
+		{NodeID: "ns=4;s=PLC.Tank.Label", Path: "PLC/Tank/Label", DataType: "String", IECType: "STRING"},
+		{NodeID: "ns=4;s=PLC.Tank.Alarm", Path: "PLC/Tank/Alarm", DataType: "Boolean", IECType: "BOOL"},
+		{NodeID: "ns=3;s=Diagnostics.Cycle", Path: "Diagnostics/Cycle", DataType: "DateTime"},
+	}
+	return Build(modbusTags, opcTags, variables, 4, "PLC.")
+}
+
+func TestBuild(t *testing.T) {
+	r := testReport()
+
+	expected := []struct {
+		name   string
+		status Status
+	}{
+		{"Pump.Run", StatusBoth},
+		{"Tank.Level", StatusTypeMismatch},
+		{"Tank.Mask", StatusBoth},
+		{"Valve.Pos", StatusModbusOnly},
+		{"Tank.Label", StatusBoth},
+		{"Tank.Alarm", StatusOPCOnly},
+		{"Diagnostics/Cycle", StatusOPCOnly},
+	}
+	if len(r.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), len(r.Entries), r.Entries)
+	}
+	for i, e := range expected {
+		if r.Entries[i].Name != e.name || r.Entries[i].Status != e.status {
+			t.Errorf("Entry %d: expected %s %s, got %s %s", i, e.name, e.status, r.Entries[i].Name, r.Entries[i].Status)
+		}
+	}
+
+	level := r.Entries[1]
+	if level.DataType != "REAL" || level.OPCDataType != "Double" || level.RegisterType != "HoldingRegister" {
+		t.Errorf("Expected both sides of the mismatched type, got %+v", level)
+	}
+
+	s := r.Summary
+	if s.Total != 7 || s.Both != 3 || s.ModbusOnly != 1 || s.OPCOnly != 2 || s.TypeMismatch != 1 || s.TagsInMap != 5 || s.VariablesSeen != 6 {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	if s.Complete() {
+		t.Error("Expected an incomplete coverage")
+	}
+	if !(Summary{Both: 2, OPCOnly: 3}).Complete() {
+		t.Error("Expected variables only on the server not to count against coverage")
+	}
+}
+
+func TestTypesMatch(t *testing.T) {
+	testCases := []struct {
+		dataType, opcIECType string
+		expected             bool
+	}{
+		{"REAL", "REAL", true},
+		{"REAL", "LREAL", false},
+		{"WORD", "UINT", true},
+		{"DWORD", "UDINT", true},
+		{"UDINT", "DINT", false},
+		{"STRING[20]", "STRING", true},
+		{"INT", "", false},
+	}
+	for _, tc := range testCases {
+		if got := TypesMatch(tc.dataType, tc.opcIECType); got != tc.expected {
+			t.Errorf("TypesMatch(%s, %s): expected %v, got %v", tc.dataType, tc.opcIECType, tc.expected, got)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testReport(), ','); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 8 || records[0][1] != "status" {
+		t.Fatalf("Expected a header and 7 rows, got %v", records)
+	}
+	if records[4][1] != "modbus_only" || records[4][5] != "400014" || records[4][6] != "" {
+		t.Errorf("Unexpected Modbus only row: %v", records[4])
+	}
+	if records[6][5] != "" || records[6][7] != "BOOL" {
+		t.Errorf("Expected no Modbus address for an OPC only row, got %v", records[6])
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	r := testReport()
+	r.Name = "plant"
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if decoded.Name != "plant" || decoded.Summary != r.Summary || len(decoded.Entries) != len(r.Entries) {
+		t.Errorf("Expected the report back, got %+v", decoded)
+	}
+	if decoded.Entries[1].Status != StatusTypeMismatch {
+		t.Errorf("Expected the type mismatch back, got %+v", decoded.Entries[1])
+	}
+}
@@ -0,0 +1,222 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"opcmss/internal/model"
+	"opcmss/internal/throttle"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Modbus PDU limits for a single read request
+const (
+	MaxReadRegisters = 125
+	MaxReadCoils     = 2000
+)
+
+// BatchOptions control how tags are grouped into block reads
+type BatchOptions struct {
+	MaxGap       uint16 // unused addresses allowed between two tags in one block
+	MaxRegisters uint16 // largest register block, capped at MaxReadRegisters
+	MaxCoils     uint16 // largest coil block, capped at MaxReadCoils
+}
+
+// DefaultBatchOptions reads strictly contiguous blocks of the maximum size
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		MaxRegisters: MaxReadRegisters,
+		MaxCoils:     MaxReadCoils,
+	}
+}
+
+// Block is a contiguous address range read with a single request
+type Block struct {
+	RegisterType string
+	Address      uint16 // 1-based start address
+	Quantity     uint16
+	Tags         []int // indexes of the tags covered by the block
+}
+
+// TagValue is the outcome of reading one tag as part of a batch
+type TagValue struct {
+	Value   any
+	Raw     any // the tag's slice of the block, []bool or []uint16
+	Err     error
+	ReadAt  time.Time
+	Latency time.Duration
+}
+
+// blockLimit returns the maximum block size for a register type
+func (o BatchOptions) blockLimit(registerType string) uint16 {
+	limit, ceiling := o.MaxRegisters, uint16(MaxReadRegisters)
+	if registerType == "Coil" || registerType == "DiscreteInput" {
+		limit, ceiling = o.MaxCoils, MaxReadCoils
+	}
+	if limit == 0 || limit > ceiling {
+		return ceiling
+	}
+	return limit
+}
+
+// PlanBlocks groups tags by register type into blocks of nearby addresses.
+// Tags are merged while the hole between them is at most MaxGap addresses and
+// the block stays within the size limit for its register type. A tag larger
+// than the limit, like a long string, gets a block of its own that is read
+// in several requests.
+func PlanBlocks(tags []model.ModbusTag, opts BatchOptions) []Block {
+	byType := make(map[string][]int)
+	var types []string
+	for i, tag := range tags {
+		if _, ok := byType[tag.RegisterType]; !ok {
+			types = append(types, tag.RegisterType)
+		}
+		byType[tag.RegisterType] = append(byType[tag.RegisterType], i)
+	}
+
+	var blocks []Block
+	for _, regType := range types {
+		indexes := byType[regType]
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return tags[indexes[a]].Address < tags[indexes[b]].Address
+		})
+
+		limit := uint32(opts.blockLimit(regType))
+		var current *Block
+		var end uint32 // exclusive end address of the current block
+
+		for _, i := range indexes {
+			tag := tags[i]
+			start := uint32(tag.Address)
+			tagEnd := start + uint32(tag.Size)
+
+			if current != nil && start <= end+uint32(opts.MaxGap) && max(end, tagEnd)-uint32(current.Address) <= limit {
+				current.Tags = append(current.Tags, i)
+				end = max(end, tagEnd)
+				current.Quantity = uint16(end - uint32(current.Address))
+				continue
+			}
+
+			blocks = append(blocks, Block{
+				RegisterType: regType,
+				Address:      tag.Address,
+				Quantity:     tag.Size,
+				Tags:         []int{i},
+			})
+			current = &blocks[len(blocks)-1]
+			end = tagEnd
+		}
+	}
+	return blocks
+}
+
+// ReadTags reads many tags with as few requests as possible, one block per
+// connection at a time. The results are in the same order as tags. Once ctx
+// is done, the blocks not read yet fail with its error.
+func (c *Client) ReadTags(ctx context.Context, tags []model.ModbusTag, opts BatchOptions) []TagValue {
+	results := make([]TagValue, len(tags))
+	blocks := PlanBlocks(tags, opts)
+	throttle.Each(len(blocks), c.client.size(), func(i int) {
+		c.readBlock(ctx, tags, blocks[i], opts.blockLimit(blocks[i].RegisterType), results)
+	})
+	return results
+}
+
+// readBlock reads a block in requests of at most limit addresses
+func (c *Client) readBlock(ctx context.Context, tags []model.ModbusTag, block Block, limit uint16, results []TagValue) {
+	readAt := time.Now()
+
+	var coils []bool
+	var registers []uint16
+	var err error
+
+	switch block.RegisterType {
+	case "Coil", "DiscreteInput":
+		coils, err = c.readBits(ctx, block.RegisterType, block.Address-1, block.Quantity, limit)
+	case "HoldingRegister":
+		registers, err = c.readWords(ctx, modbus.HOLDING_REGISTER, block.Address-1, block.Quantity, limit)
+	case "InputRegister":
+		registers, err = c.readWords(ctx, modbus.INPUT_REGISTER, block.Address-1, block.Quantity, limit)
+	default:
+		c.readEach(ctx, tags, block, results)
+		return
+	}
+	latency := time.Since(readAt)
+
+	// A gap may cover addresses the device does not map, so retry tag by tag
+	if errors.Is(err, modbus.ErrIllegalDataAddress) && len(block.Tags) > 1 {
+		c.readEach(ctx, tags, block, results)
+		return
+	}
+
+	for _, i := range block.Tags {
+		tag := tags[i]
+		result := TagValue{Err: err, ReadAt: readAt, Latency: latency}
+
+		if err == nil {
+			start := int(tag.Address - block.Address)
+			end := start + int(tag.Size)
+			switch {
+			case coils != nil && end <= len(coils):
+				result.Raw = coils[start:end:end]
+				result.Value, result.Err = decodeCoils(tag, coils[start:end])
+			case registers != nil && end <= len(registers):
+				result.Raw = registers[start:end:end]
+				result.Value, result.Err = decodeRegisters(c.withByteOrder(tag), registers[start:end])
+			default:
+				result.Err = fmt.Errorf("short response for block at %d: tag needs %d..%d", block.Address, tag.Address, tag.Address+tag.Size-1)
+			}
+		}
+		results[i] = result
+	}
+}
+
+// readBits reads quantity coils or discrete inputs from the 0-based address,
+// in requests of at most limit bits
+func (c *Client) readBits(ctx context.Context, registerType string, address, quantity, limit uint16) ([]bool, error) {
+	return readChunks(address, quantity, limit, func(address, quantity uint16) ([]bool, error) {
+		if registerType == "DiscreteInput" {
+			return c.client.ReadDiscreteInputs(ctx, address, quantity)
+		}
+		return c.client.ReadCoils(ctx, address, quantity)
+	})
+}
+
+// readWords reads quantity registers from the 0-based address, in requests
+// of at most limit registers
+func (c *Client) readWords(ctx context.Context, regType modbus.RegType, address, quantity, limit uint16) ([]uint16, error) {
+	return readChunks(address, quantity, limit, func(address, quantity uint16) ([]uint16, error) {
+		return c.client.ReadRegisters(ctx, address, quantity, regType)
+	})
+}
+
+// readChunks splits a read of quantity addresses into requests of at most
+// limit addresses and joins the results. The chunks are separate requests,
+// so a value that changes in between may be read torn.
+func readChunks[T any](address, quantity, limit uint16, read func(address, quantity uint16) ([]T, error)) ([]T, error) {
+	if limit == 0 || quantity <= limit {
+		return read(address, quantity)
+	}
+	values := make([]T, 0, quantity)
+	for offset := 0; offset < int(quantity); offset += int(limit) {
+		chunk, err := read(address+uint16(offset), uint16(min(int(limit), int(quantity)-offset)))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, chunk...)
+	}
+	return values, nil
+}
+
+// readEach reads the tags of a block one request at a time
+func (c *Client) readEach(ctx context.Context, tags []model.ModbusTag, block Block, results []TagValue) {
+	for _, i := range block.Tags {
+		readAt := time.Now()
+		value, err := c.ReadTag(ctx, tags[i])
+		results[i] = TagValue{Value: value, Err: err, ReadAt: readAt, Latency: time.Since(readAt)}
+	}
+}
@@ -0,0 +1,352 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+
+	"github.com/simonvetter/modbus"
+)
+
+// memoryModbusClient serves reads from address-indexed memory and records
+// every request, so batching can be verified
+type memoryModbusClient struct {
+	coils     map[uint16]bool
+	registers map[uint16]uint16
+	holes     map[uint16]bool // addresses that answer with an illegal data address
+	requests  [][2]uint16
+	writes    []string // function code and address of every write, like "FC06@4"
+	ignore    bool     // acknowledge writes without storing them
+}
+
+func (m *memoryModbusClient) ReadCoils(address, quantity uint16) ([]bool, error) {
+	m.requests = append(m.requests, [2]uint16{address, quantity})
+	values := make([]bool, quantity)
+	for i := range values {
+		if m.holes[address+uint16(i)] {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		values[i] = m.coils[address+uint16(i)]
+	}
+	return values, nil
+}
+
+func (m *memoryModbusClient) ReadDiscreteInputs(address, quantity uint16) ([]bool, error) {
+	return m.ReadCoils(address, quantity)
+}
+
+func (m *memoryModbusClient) ReadRegisters(address, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	m.requests = append(m.requests, [2]uint16{address, quantity})
+	values := make([]uint16, quantity)
+	for i := range values {
+		if m.holes[address+uint16(i)] {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		values[i] = m.registers[address+uint16(i)]
+	}
+	return values, nil
+}
+
+func (m *memoryModbusClient) WriteCoil(address uint16, value bool) error {
+	m.writes = append(m.writes, fmt.Sprintf("FC05@%d", address))
+	return m.writeCoils(address, []bool{value})
+}
+
+func (m *memoryModbusClient) WriteCoils(address uint16, values []bool) error {
+	m.writes = append(m.writes, fmt.Sprintf("FC15@%d", address))
+	return m.writeCoils(address, values)
+}
+
+func (m *memoryModbusClient) writeCoils(address uint16, values []bool) error {
+	if m.ignore {
+		return nil
+	}
+	if m.coils == nil {
+		m.coils = make(map[uint16]bool)
+	}
+	for i, v := range values {
+		if m.holes[address+uint16(i)] {
+			return modbus.ErrIllegalDataAddress
+		}
+		m.coils[address+uint16(i)] = v
+	}
+	return nil
+}
+
+func (m *memoryModbusClient) WriteRegister(address uint16, value uint16) error {
+	m.writes = append(m.writes, fmt.Sprintf("FC06@%d", address))
+	return m.writeRegisters(address, []uint16{value})
+}
+
+func (m *memoryModbusClient) WriteRegisters(address uint16, values []uint16) error {
+	m.writes = append(m.writes, fmt.Sprintf("FC16@%d", address))
+	return m.writeRegisters(address, values)
+}
+
+func (m *memoryModbusClient) writeRegisters(address uint16, values []uint16) error {
+	if m.ignore {
+		return nil
+	}
+	if m.registers == nil {
+		m.registers = make(map[uint16]uint16)
+	}
+	for i, v := range values {
+		if m.holes[address+uint16(i)] {
+			return modbus.ErrIllegalDataAddress
+		}
+		m.registers[address+uint16(i)] = v
+	}
+	return nil
+}
+
+func (m *memoryModbusClient) Open() error  { return nil }
+func (m *memoryModbusClient) Close() error { return nil }
+
+func newTag(name, regType string, address, size uint16) model.ModbusTag {
+	return model.ModbusTag{Name: name, RegisterType: regType, Address: address, Size: size}
+}
+
+func TestPlanBlocks_Contiguous(t *testing.T) {
+	tags := []model.ModbusTag{
+		newTag("HR3", "HoldingRegister", 3, 2),
+		newTag("C1", "Coil", 1, 1),
+		newTag("HR1", "HoldingRegister", 1, 2),
+		newTag("C2", "Coil", 2, 1),
+		newTag("HR10", "HoldingRegister", 10, 1),
+	}
+
+	blocks := PlanBlocks(tags, DefaultBatchOptions())
+
+	expected := []Block{
+		{RegisterType: "HoldingRegister", Address: 1, Quantity: 4, Tags: []int{2, 0}},
+		{RegisterType: "HoldingRegister", Address: 10, Quantity: 1, Tags: []int{4}},
+		{RegisterType: "Coil", Address: 1, Quantity: 2, Tags: []int{1, 3}},
+	}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, blocks)
+	}
+}
+
+func TestPlanBlocks_MaxGap(t *testing.T) {
+	tags := []model.ModbusTag{
+		newTag("A", "HoldingRegister", 1, 1),
+		newTag("B", "HoldingRegister", 4, 1),  // gap of 2
+		newTag("C", "HoldingRegister", 10, 2), // gap of 5
+	}
+
+	blocks := PlanBlocks(tags, BatchOptions{MaxGap: 2})
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %+v", blocks)
+	}
+	if blocks[0].Address != 1 || blocks[0].Quantity != 4 || len(blocks[0].Tags) != 2 {
+		t.Errorf("Unexpected first block: %+v", blocks[0])
+	}
+
+	blocks = PlanBlocks(tags, BatchOptions{MaxGap: 5})
+	if len(blocks) != 1 || blocks[0].Quantity != 11 {
+		t.Errorf("Expected a single 11 register block, got %+v", blocks)
+	}
+}
+
+func TestPlanBlocks_SizeLimits(t *testing.T) {
+	var tags []model.ModbusTag
+	for i := uint16(0); i < 200; i++ {
+		tags = append(tags, newTag("HR", "HoldingRegister", 1+i*2, 2))
+	}
+
+	blocks := PlanBlocks(tags, DefaultBatchOptions())
+	for _, b := range blocks {
+		if b.Quantity > MaxReadRegisters {
+			t.Errorf("Block exceeds register limit: %d", b.Quantity)
+		}
+	}
+	// 400 registers in blocks of at most 124 (62 whole tags)
+	if len(blocks) != 4 {
+		t.Errorf("Expected 4 blocks, got %d", len(blocks))
+	}
+
+	blocks = PlanBlocks(tags, BatchOptions{MaxRegisters: 10})
+	if len(blocks) != 40 {
+		t.Errorf("Expected 40 blocks of 10 registers, got %d", len(blocks))
+	}
+}
+
+func TestReadTags_SplitsOversizedTags(t *testing.T) {
+	// A STRING[300] needs 150 registers, more than one request may read
+	text := strings.Repeat("0123456789", 30)
+	mem := &memoryModbusClient{registers: map[uint16]uint16{}, coils: map[uint16]bool{2499: true}}
+	for i := 0; i < len(text); i += 2 {
+		mem.registers[uint16(i/2)] = uint16(text[i])<<8 | uint16(text[i+1])
+	}
+	client := NewClientWithModbus(mem)
+
+	tags := []model.ModbusTag{
+		{Name: "Before", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "WORD"},
+		{Name: "Text", RegisterType: "HoldingRegister", Address: 1, Size: 150, DataType: "STRING[300]"},
+		{Name: "Bits", RegisterType: "Coil", Address: 1, Size: 2500},
+	}
+	blocks := PlanBlocks(tags, DefaultBatchOptions())
+	if len(blocks) != 3 || blocks[1].Quantity != 150 || len(blocks[1].Tags) != 1 {
+		t.Fatalf("Expected the string in a block of its own, got %+v", blocks)
+	}
+
+	results := client.ReadTags(context.Background(), tags, DefaultBatchOptions())
+	if results[1].Err != nil || results[1].Value != text {
+		t.Errorf("Expected the whole string, got %v (err %v)", results[1].Value, results[1].Err)
+	}
+	if bits, ok := results[2].Value.([]bool); !ok || len(bits) != 2500 || !bits[2499] {
+		t.Errorf("Expected 2500 coils with the last one set, got %v (err %v)", len(bits), results[2].Err)
+	}
+	// At most 125 registers and 2000 coils per request
+	if fmt.Sprint(mem.requests) != "[[0 1] [0 125] [125 25] [0 2000] [2000 500]]" {
+		t.Errorf("Unexpected requests %v", mem.requests)
+	}
+
+	// Single reads are split the same way
+	mem.requests = nil
+	if value, err := client.ReadTag(context.Background(), tags[1]); err != nil || value != text {
+		t.Errorf("Expected the whole string, got %v (err %v)", value, err)
+	}
+	if len(mem.requests) != 2 {
+		t.Errorf("Expected 2 requests, got %v", mem.requests)
+	}
+}
+
+func TestReadTags_SlicesBlockValues(t *testing.T) {
+	mem := &memoryModbusClient{
+		coils:     map[uint16]bool{0: true, 2: true},
+		registers: map[uint16]uint16{0: 7, 1: 0x4270, 2: 0x0000, 5: 42},
+	}
+	client := NewClientWithModbus(mem)
+
+	tags := []model.ModbusTag{
+		newTag("C1", "Coil", 1, 1),
+		newTag("C2", "Coil", 2, 1),
+		newTag("C3", "Coil", 3, 1),
+		newTag("HR1", "HoldingRegister", 1, 1),
+		newTag("HR2", "HoldingRegister", 2, 2),
+		newTag("HR6", "HoldingRegister", 6, 1),
+	}
+
+	results := client.ReadTags(context.Background(), tags, BatchOptions{MaxGap: 2})
+
+	if len(mem.requests) != 2 {
+		t.Errorf("Expected 2 requests, got %d: %v", len(mem.requests), mem.requests)
+	}
+
+	for i, expected := range []any{true, false, true, int16(7)} {
+		if results[i].Err != nil || results[i].Value != expected {
+			t.Errorf("Tag %s: expected %v, got %v (err %v)", tags[i].Name, expected, results[i].Value, results[i].Err)
+		}
+	}
+
+	if results[4].Value != float32(60) {
+		t.Errorf("Expected float32 60 for HR2, got %v", results[4].Value)
+	}
+	if results[5].Value != int16(42) {
+		t.Errorf("Expected 42 for HR6, got %v", results[5].Value)
+	}
+}
+
+func TestReadTags_FallsBackOnIllegalAddress(t *testing.T) {
+	mem := &memoryModbusClient{
+		registers: map[uint16]uint16{0: 1, 4: 5},
+		holes:     map[uint16]bool{2: true},
+	}
+	client := NewClientWithModbus(mem)
+
+	tags := []model.ModbusTag{
+		newTag("A", "HoldingRegister", 1, 1),
+		newTag("B", "HoldingRegister", 5, 1),
+	}
+
+	results := client.ReadTags(context.Background(), tags, BatchOptions{MaxGap: 5})
+
+	if results[0].Value != int16(1) || results[1].Value != int16(5) {
+		t.Errorf("Expected values 1 and 5, got %v and %v", results[0].Value, results[1].Value)
+	}
+	// One failed block read, then one read per tag
+	if len(mem.requests) != 3 {
+		t.Errorf("Expected 3 requests, got %d: %v", len(mem.requests), mem.requests)
+	}
+}
+
+func TestReadTags_UnsupportedTypeReportedPerTag(t *testing.T) {
+	client := NewClientWithModbus(&memoryModbusClient{})
+
+	results := client.ReadTags(context.Background(), []model.ModbusTag{newTag("X", "Bogus", 1, 1)}, DefaultBatchOptions())
+	if results[0].Err == nil {
+		t.Error("Expected error for unsupported register type, got none")
+	}
+}
+
+func TestReadTags_Cancelled(t *testing.T) {
+	mem := &memoryModbusClient{registers: map[uint16]uint16{0: 1}}
+	client := NewClientWithModbus(mem)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tags := []model.ModbusTag{newTag("A", "HoldingRegister", 1, 1), newTag("B", "Coil", 1, 1)}
+	for i, result := range client.ReadTags(ctx, tags, DefaultBatchOptions()) {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("Tag %d: expected context.Canceled, got %v", i, result.Err)
+		}
+	}
+	if len(mem.requests) != 0 {
+		t.Errorf("Expected no requests after the cancel, got %v", mem.requests)
+	}
+}
+
+// busyModbusClient fails a read that arrives while another is still running,
+// like a connection that answers one request at a time
+type busyModbusClient struct {
+	memoryModbusClient
+	busy atomic.Bool
+}
+
+func (b *busyModbusClient) ReadRegisters(address, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	if !b.busy.CompareAndSwap(false, true) {
+		return nil, errors.New("request sent while another was pending")
+	}
+	defer b.busy.Store(false)
+	time.Sleep(5 * time.Millisecond)
+	return b.memoryModbusClient.ReadRegisters(address, quantity, regType)
+}
+
+func TestReadTags_SpreadsBlocksOverConnections(t *testing.T) {
+	registers := map[uint16]uint16{0: 1, 10: 2, 20: 3, 30: 4, 40: 5, 50: 6}
+	conns := make([]*busyModbusClient, 3)
+	clients := make([]ModbusClient, len(conns))
+	for i := range conns {
+		conns[i] = &busyModbusClient{memoryModbusClient: memoryModbusClient{registers: registers}}
+		clients[i] = conns[i]
+	}
+	client := NewClientWithConnections(clients)
+
+	var tags []model.ModbusTag
+	for i := range 6 {
+		tags = append(tags, newTag(fmt.Sprintf("HR%d", i), "HoldingRegister", uint16(i*10+1), 1))
+	}
+
+	for i, result := range client.ReadTags(context.Background(), tags, DefaultBatchOptions()) {
+		if result.Err != nil || result.Value != int16(i+1) {
+			t.Errorf("Tag %s: expected %d, got %v (err %v)", tags[i].Name, i+1, result.Value, result.Err)
+		}
+	}
+	used := 0
+	for _, conn := range conns {
+		if len(conn.requests) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("Expected the blocks to be read on several connections, %d used", used)
+	}
+}
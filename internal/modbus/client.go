@@ -1,60 +1,175 @@
 package modbus
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
-	"math"
 	"time"
 
 	"opcmss/internal/model"
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
 
 	"github.com/simonvetter/modbus"
 )
 
 type ModbusClient interface {
 	ReadCoils(address, quantity uint16) ([]bool, error)
+	ReadDiscreteInputs(address, quantity uint16) ([]bool, error)
 	ReadRegisters(address, quantity uint16, regType modbus.RegType) ([]uint16, error)
+	WriteCoil(address uint16, value bool) error
+	WriteCoils(address uint16, values []bool) error
+	WriteRegister(address uint16, value uint16) error
+	WriteRegisters(address uint16, values []uint16) error
 	Open() error
 	Close() error
 }
 
+// DefaultTimeout is how long NewClient waits for the answer to a request
+const DefaultTimeout = time.Second
+
 type Client struct {
-	client ModbusClient
+	client    *connPool
+	byteOrder string // default for tags without their own byte order
+}
+
+// ClientOption configures a Client
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	recovery    retry.Recovery
+	timeout     time.Duration
+	connections int
+	limiter     *throttle.Limiter
+	serial      Serial
 }
 
-func NewClient(address string) (*Client, error) {
-	c, err := modbus.NewClient(&modbus.ClientConfiguration{
-		URL:     "tcp://" + address,
-		Timeout: 1 * time.Second,
-	})
+// WithRecovery sets how failed calls are retried and how the connection is
+// reopened after it was lost
+func WithRecovery(r retry.Recovery) ClientOption {
+	return func(c *clientConfig) {
+		c.recovery = r
+	}
+}
+
+// WithTimeout sets how long the server may take to answer a request before
+// it fails with a timeout, 1 second by default. Only NewClient uses it.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.timeout = d
+	}
+}
+
+// WithConnections sets how many connections NewClient opens, 1 by default.
+// Calls made from several goroutines run on separate connections, as far as
+// there are any. Many devices accept only a few connections.
+func WithConnections(n int) ClientOption {
+	return func(c *clientConfig) {
+		c.connections = n
+	}
+}
+
+// WithRateLimit makes every request, retries included, wait for its turn
+// with l. A nil Limiter does not limit.
+func WithRateLimit(l *throttle.Limiter) ClientOption {
+	return func(c *clientConfig) {
+		c.limiter = l
+	}
+}
+
+// WithSerial sets the line settings of an rtu:// endpoint. Only NewClient
+// uses it.
+func WithSerial(s Serial) ClientOption {
+	return func(c *clientConfig) {
+		c.serial = s
+	}
+}
+
+// NewClient connects to the Modbus server at endpoint, see ParseEndpoint.
+// Calls that fail with a transient error are retried and a lost connection
+// is reopened, with retry.DefaultRecovery unless WithRecovery says otherwise.
+// A serial line carries one request at a time, so an rtu:// endpoint is
+// opened once whatever WithConnections says.
+func NewClient(endpoint string, opts ...ClientOption) (*Client, error) {
+	cfg := clientConfig{recovery: retry.DefaultRecovery(), timeout: DefaultTimeout, connections: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	scheme, address, err := ParseEndpoint(endpoint)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := c.Open(); err != nil {
+	parity, err := cfg.serial.parity()
+	if err != nil {
 		return nil, err
 	}
-	return &Client{client: c}, nil
+	if scheme == SchemeRTU {
+		cfg.connections = 1
+	}
+
+	var conns []*reconnectingClient
+	for i := range max(1, cfg.connections) {
+		c, err := modbus.NewClient(&modbus.ClientConfiguration{
+			URL:      scheme + "://" + address,
+			Timeout:  cfg.timeout,
+			Speed:    cfg.serial.BaudRate,
+			Parity:   parity,
+			StopBits: cfg.serial.StopBits,
+		})
+		if err == nil {
+			err = c.Open()
+		}
+		if err != nil {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			if i > 0 {
+				return nil, fmt.Errorf("failed to open connection %d of %d: %w", i+1, cfg.connections, err)
+			}
+			return nil, err
+		}
+		conns = append(conns, newReconnectingClient(c, cfg.recovery, cfg.limiter))
+	}
+	return &Client{client: newConnPool(conns)}, nil
 }
 
-func (c *Client) ReadTag(tag model.ModbusTag) (any, error) {
+// ReadTag reads a tag and decodes it for its data type. Cancelling ctx stops
+// the retries, a request already sent ends within the client's timeout.
+func (c *Client) ReadTag(ctx context.Context, tag model.ModbusTag) (any, error) {
 	switch tag.RegisterType {
 	case "Coil":
-		return c.readCoil(tag)
+		return c.readCoil(ctx, tag)
+	case "DiscreteInput":
+		return c.readDiscreteInput(ctx, tag)
 	case "HoldingRegister":
-		return c.readHoldingRegister(tag)
+		return c.readHoldingRegister(ctx, tag)
+	case "InputRegister":
+		return c.readInputRegister(ctx, tag)
 	default:
 		return nil, fmt.Errorf("unsupported register type: %s", tag.RegisterType)
 	}
 }
 
-func (c *Client) readCoil(tag model.ModbusTag) (any, error) {
+func (c *Client) readCoil(ctx context.Context, tag model.ModbusTag) (any, error) {
 	// For coils, we read individual bits
-	data, err := c.client.ReadCoils(tag.Address-1, tag.Size) // Modbus addresses are typically 1-based
+	data, err := c.readBits(ctx, tag.RegisterType, tag.Address-1, tag.Size, MaxReadCoils) // Modbus addresses are typically 1-based
+	if err != nil {
+		return nil, err
+	}
+	return decodeCoils(tag, data)
+}
+
+func (c *Client) readDiscreteInput(ctx context.Context, tag model.ModbusTag) (any, error) {
+	// Discrete inputs are read-only bits (function code 02)
+	data, err := c.readBits(ctx, tag.RegisterType, tag.Address-1, tag.Size, MaxReadCoils)
 	if err != nil {
 		return nil, err
 	}
+	return decodeCoils(tag, data)
+}
 
+// decodeCoils converts coil or discrete input states into a bool or a slice of bools
+func decodeCoils(tag model.ModbusTag, data []bool) (any, error) {
 	if tag.Size == 1 {
 		return len(data) > 0 && data[0], nil
 	}
@@ -65,148 +180,87 @@ func (c *Client) readCoil(tag model.ModbusTag) (any, error) {
 	return result, nil
 }
 
-func (c *Client) readHoldingRegister(tag model.ModbusTag) (any, error) {
-	data, err := c.client.ReadRegisters(tag.Address-1, tag.Size, modbus.HOLDING_REGISTER)
+func (c *Client) readHoldingRegister(ctx context.Context, tag model.ModbusTag) (any, error) {
+	return c.readRegisters(ctx, tag, modbus.HOLDING_REGISTER)
+}
+
+func (c *Client) readInputRegister(ctx context.Context, tag model.ModbusTag) (any, error) {
+	// Input registers are read-only words (function code 04)
+	return c.readRegisters(ctx, tag, modbus.INPUT_REGISTER)
+}
+
+func (c *Client) readRegisters(ctx context.Context, tag model.ModbusTag, regType modbus.RegType) (any, error) {
+	data, err := c.readWords(ctx, regType, tag.Address-1, tag.Size, MaxReadRegisters)
 	if err != nil {
 		return nil, err
 	}
+	return decodeRegisters(c.withByteOrder(tag), data)
+}
 
-	switch tag.Size {
-	case 1:
-		// Single 16-bit register - could be INT or BOOL
-		if len(data) > 0 {
-			return int16(data[0]), nil
-		}
-		return nil, fmt.Errorf("no data received")
-
-	case 2:
-		// Two 16-bit registers - could be REAL (float32) or DINT (int32)
-		if len(data) < 2 {
-			return nil, fmt.Errorf("insufficient data for 32-bit value")
-		}
-
-		// Convert to 32-bit value
-		bytes := make([]byte, 4)
-		binary.BigEndian.PutUint16(bytes[0:2], data[0])
-		binary.BigEndian.PutUint16(bytes[2:4], data[1])
-		uint32Value := binary.BigEndian.Uint32(bytes)
-
-		// Try to determine if it's float or int based on the value
-		// For now, return both interpretations in a map
-		return map[string]any{
-			"int32":   int32(uint32Value),
-			"float32": float32FromBits(uint32Value),
-			"raw":     data,
-		}, nil
-
+// ReadRawRegisters reads the registers of a holding or input register tag
+// without decoding them
+func (c *Client) ReadRawRegisters(ctx context.Context, tag model.ModbusTag) ([]uint16, error) {
+	switch tag.RegisterType {
+	case "HoldingRegister":
+		return c.readWords(ctx, modbus.HOLDING_REGISTER, tag.Address-1, tag.Size, MaxReadRegisters)
+	case "InputRegister":
+		return c.readWords(ctx, modbus.INPUT_REGISTER, tag.Address-1, tag.Size, MaxReadRegisters)
 	default:
-		// Multiple registers, return as slice
-		result := make([]int16, len(data))
-		for i, val := range data {
-			result[i] = int16(val)
-		}
-		return result, nil
+		return nil, fmt.Errorf("unsupported register type: %s", tag.RegisterType)
 	}
 }
 
-func (c *Client) Close() {
-	c.client.Close()
+// SetByteOrder sets the byte order used for tags that do not declare their own
+func (c *Client) SetByteOrder(order string) error {
+	order, err := model.ParseByteOrder(order)
+	if err != nil {
+		return err
+	}
+	c.byteOrder = order
+	return nil
 }
 
-func NewClientWithModbus(client ModbusClient) *Client {
-	return &Client{client: client}
+// withByteOrder applies the connection's byte order to a tag without one
+func (c *Client) withByteOrder(tag model.ModbusTag) model.ModbusTag {
+	if tag.ByteOrder == "" {
+		tag.ByteOrder = c.byteOrder
+	}
+	return tag
 }
 
-func float32FromBits(bits uint32) float32 {
-	return float32FromUint32(bits)
+func (c *Client) Close() {
+	c.client.Close()
 }
 
-func float32FromUint32(bits uint32) float32 {
-	return math.Float32frombits(bits)
+// NewClientWithModbus wraps an open ModbusClient. Without WithRecovery,
+// calls are not retried, but a lost connection is still reopened.
+func NewClientWithModbus(client ModbusClient, opts ...ClientOption) *Client {
+	return NewClientWithConnections([]ModbusClient{client}, opts...)
 }
 
-// ExtractBestValue returns the single most reasonable numeric value for comparison
-func (c *Client) ExtractBestValue(val any) any {
-	switch v := val.(type) {
-	case map[string]any:
-		var f float32
-		var i int32
-		var hasFloat, hasInt bool
-
-		if fVal, ok := v["float32"].(float32); ok {
-			f = fVal
-			hasFloat = true
-		}
-		if iVal, ok := v["int32"].(int32); ok {
-			i = iVal
-			hasInt = true
-		}
-
-		if !hasFloat || !hasInt {
-			return val // Return original if we can't extract both
-		}
-
-		// Use same heuristic as FormatTagValue to pick the most reasonable value
-		if f >= 0 && f < 100000 && f == float32(int(f)) {
-			// Whole number that makes sense as float - return the float
-			return f
-		}
-		if f > 0.001 && f < 1000000 {
-			// Reasonable float range - return float
-			return f
-		}
-
-		// Prefer int32 if it's a reasonable integer and float looks unrealistic
-		if i >= 0 && i < 100000 {
-			return i
-		}
-
-		// Default: return float32 (most common for industrial sensors)
-		return f
-	default:
-		return val // Return as-is for non-map values
+// NewClientWithConnections wraps open ModbusClients, one per connection, and
+// shares them between concurrent calls like NewClient with WithConnections
+func NewClientWithConnections(clients []ModbusClient, opts ...ClientOption) *Client {
+	var cfg clientConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	conns := make([]*reconnectingClient, len(clients))
+	for i, client := range clients {
+		conns[i] = newReconnectingClient(client, cfg.recovery, cfg.limiter)
 	}
+	return &Client{client: newConnPool(conns)}
 }
 
-// FormatTagValue returns a string with the most reasonable interpretation first
+// FormatTagValue formats a decoded value together with the tag's data type
 func (c *Client) FormatTagValue(tag model.ModbusTag, val any) string {
-	switch v := val.(type) {
-	case map[string]any:
-		var f float32
-		var i int32
-		var hasFloat, hasInt bool
-
-		if fVal, ok := v["float32"].(float32); ok {
-			f = fVal
-			hasFloat = true
-		}
-		if iVal, ok := v["int32"].(int32); ok {
-			i = iVal
-			hasInt = true
-		}
-
-		if !hasFloat || !hasInt {
-			return fmt.Sprintf("float32: %v int32: %v", f, i)
-		}
-
-		// Heuristic: prefer float32 if it's in a reasonable range (like your example of 60)
-		if f >= 0 && f < 100000 && f == float32(int(f)) {
-			// Whole number that makes sense as float
-			return fmt.Sprintf("float32: %v (int32: %v)", f, i)
-		}
-		if f > 0.001 && f < 1000000 {
-			// Reasonable float range
-			return fmt.Sprintf("float32: %v (int32: %v)", f, i)
-		}
-
-		// Prefer int32 if it's a reasonable integer and float looks like garbage
-		if i >= 0 && i < 100000 {
-			return fmt.Sprintf("int32: %v (float32: %v)", i, f)
-		}
-
-		// Default: show both with float32 first
-		return fmt.Sprintf("float32: %v int32: %v", f, i)
-	default:
+	dataType := tag.ResolvedDataType()
+	if dataType == "" {
 		return fmt.Sprintf("%v", val)
 	}
+	tag = c.withByteOrder(tag)
+	if model.HasByteOrder(dataType) && tag.ByteOrder != "" && tag.ByteOrder != model.ByteOrderABCD {
+		return fmt.Sprintf("%v (%s, %s)", val, dataType, tag.ByteOrder)
+	}
+	return fmt.Sprintf("%v (%s)", val, dataType)
 }
@@ -1,6 +1,7 @@
 package modbus
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -11,11 +12,15 @@ import (
 
 type MockModbusClient struct {
 	coilsData      []bool
+	discreteData   []bool
 	registersData  []uint16
 	coilsError     error
+	discreteError  error
 	registersError error
 	closeError     error
 	openError      error
+
+	lastRegType modbus.RegType
 }
 
 func (m *MockModbusClient) ReadCoils(address, quantity uint16) ([]bool, error) {
@@ -28,7 +33,18 @@ func (m *MockModbusClient) ReadCoils(address, quantity uint16) ([]bool, error) {
 	return m.coilsData[:quantity], nil
 }
 
+func (m *MockModbusClient) ReadDiscreteInputs(address, quantity uint16) ([]bool, error) {
+	if m.discreteError != nil {
+		return nil, m.discreteError
+	}
+	if int(quantity) > len(m.discreteData) {
+		return m.discreteData, nil
+	}
+	return m.discreteData[:quantity], nil
+}
+
 func (m *MockModbusClient) ReadRegisters(address, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	m.lastRegType = regType
 	if m.registersError != nil {
 		return nil, m.registersError
 	}
@@ -38,6 +54,22 @@ func (m *MockModbusClient) ReadRegisters(address, quantity uint16, regType modbu
 	return m.registersData[:quantity], nil
 }
 
+func (m *MockModbusClient) WriteCoil(address uint16, value bool) error {
+	return errors.New("mock is read-only")
+}
+
+func (m *MockModbusClient) WriteCoils(address uint16, values []bool) error {
+	return errors.New("mock is read-only")
+}
+
+func (m *MockModbusClient) WriteRegister(address uint16, value uint16) error {
+	return errors.New("mock is read-only")
+}
+
+func (m *MockModbusClient) WriteRegisters(address uint16, values []uint16) error {
+	return errors.New("mock is read-only")
+}
+
 func (m *MockModbusClient) Open() error {
 	return m.openError
 }
@@ -59,7 +91,7 @@ func TestReadCoil_SingleCoilTrue(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.readCoil(tag)
+	result, err := client.readCoil(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -82,7 +114,7 @@ func TestReadCoil_SingleCoilFalse(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.readCoil(tag)
+	result, err := client.readCoil(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -105,7 +137,7 @@ func TestReadCoil_MultipleCoils(t *testing.T) {
 		Size:         4,
 	}
 
-	result, err := client.readCoil(tag)
+	result, err := client.readCoil(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -140,7 +172,7 @@ func TestReadCoil_EmptyData(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.readCoil(tag)
+	result, err := client.readCoil(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -163,7 +195,7 @@ func TestReadCoil_Error(t *testing.T) {
 		Size:         1,
 	}
 
-	_, err := client.readCoil(tag)
+	_, err := client.readCoil(context.Background(), tag)
 	if err == nil {
 		t.Fatal("Expected error, got none")
 	}
@@ -186,7 +218,7 @@ func TestReadHoldingRegister_SingleRegister(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.readHoldingRegister(tag)
+	result, err := client.readHoldingRegister(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -214,7 +246,7 @@ func TestReadHoldingRegister_SingleRegister_NoData(t *testing.T) {
 		Size:         1,
 	}
 
-	_, err := client.readHoldingRegister(tag)
+	_, err := client.readHoldingRegister(context.Background(), tag)
 	if err == nil {
 		t.Fatal("Expected error for no data, got none")
 	}
@@ -237,7 +269,7 @@ func TestReadHoldingRegister_Error(t *testing.T) {
 		Size:         1,
 	}
 
-	_, err := client.readHoldingRegister(tag)
+	_, err := client.readHoldingRegister(context.Background(), tag)
 	if err == nil {
 		t.Fatal("Expected error, got none")
 	}
@@ -255,6 +287,7 @@ func TestReadHoldingRegister_TwoRegisters(t *testing.T) {
 	}
 	client := NewClientWithModbus(mock)
 
+	// Without an explicit data type two registers default to REAL
 	tag := model.ModbusTag{
 		Name:         "TestHR2",
 		RegisterType: "HoldingRegister",
@@ -262,49 +295,45 @@ func TestReadHoldingRegister_TwoRegisters(t *testing.T) {
 		Size:         2,
 	}
 
-	result, err := client.readHoldingRegister(tag)
+	result, err := client.readHoldingRegister(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	mapResult, ok := result.(map[string]any)
+	floatVal, ok := result.(float32)
 	if !ok {
-		t.Fatalf("Expected map[string]any, got type: %T", result)
+		t.Fatalf("Expected float32, got type: %T", result)
 	}
-
-	// Check that all expected keys are present
-	if _, ok := mapResult["int32"]; !ok {
-		t.Error("Expected 'int32' key in result")
+	if floatVal != 60.0 {
+		t.Errorf("Expected float32 value 60.0, got: %v", floatVal)
 	}
-	if _, ok := mapResult["float32"]; !ok {
-		t.Error("Expected 'float32' key in result")
+}
+
+func TestReadHoldingRegister_TwoRegisters_DINT(t *testing.T) {
+	mock := &MockModbusClient{
+		registersData: []uint16{0x4270, 0x0000},
 	}
-	if _, ok := mapResult["raw"]; !ok {
-		t.Error("Expected 'raw' key in result")
+	client := NewClientWithModbus(mock)
+
+	tag := model.ModbusTag{
+		Name:         "TestHR2",
+		RegisterType: "HoldingRegister",
+		Address:      1,
+		Size:         2,
+		DataType:     "DINT",
 	}
 
-	// Check float32 value
-	if floatVal, ok := mapResult["float32"].(float32); ok {
-		if floatVal != 60.0 {
-			t.Errorf("Expected float32 value 60.0, got: %v", floatVal)
-		}
-	} else {
-		t.Error("float32 value is not of correct type")
+	result, err := client.readHoldingRegister(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
 	}
 
-	// Check raw data
-	if rawVal, ok := mapResult["raw"].([]uint16); ok {
-		expected := []uint16{0x4270, 0x0000}
-		if len(rawVal) != len(expected) {
-			t.Errorf("Expected raw length %d, got %d", len(expected), len(rawVal))
-		}
-		for i, exp := range expected {
-			if i < len(rawVal) && rawVal[i] != exp {
-				t.Errorf("Raw[%d]: expected 0x%04x, got 0x%04x", i, exp, rawVal[i])
-			}
-		}
-	} else {
-		t.Error("raw value is not of correct type")
+	intVal, ok := result.(int32)
+	if !ok {
+		t.Fatalf("Expected int32, got type: %T", result)
+	}
+	if intVal != 1114636288 {
+		t.Errorf("Expected int32 value 1114636288, got: %v", intVal)
 	}
 }
 
@@ -321,7 +350,7 @@ func TestReadHoldingRegister_TwoRegisters_InsufficientData(t *testing.T) {
 		Size:         2,
 	}
 
-	_, err := client.readHoldingRegister(tag)
+	_, err := client.readHoldingRegister(context.Background(), tag)
 	if err == nil {
 		t.Fatal("Expected error for insufficient data, got none")
 	}
@@ -344,7 +373,7 @@ func TestReadHoldingRegister_MultipleRegisters(t *testing.T) {
 		Size:         5,
 	}
 
-	result, err := client.readHoldingRegister(tag)
+	result, err := client.readHoldingRegister(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -379,7 +408,7 @@ func TestReadTag_CoilRouting(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.ReadTag(tag)
+	result, err := client.ReadTag(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -402,7 +431,7 @@ func TestReadTag_HoldingRegisterRouting(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.ReadTag(tag)
+	result, err := client.ReadTag(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -423,22 +452,146 @@ func TestReadTag_UnsupportedRegisterType(t *testing.T) {
 
 	tag := model.ModbusTag{
 		Name:         "TestUnsupported",
-		RegisterType: "InputRegister", // Unsupported type
+		RegisterType: "FileRecord", // Unsupported type
 		Address:      1,
 		Size:         1,
 	}
 
-	_, err := client.ReadTag(tag)
+	_, err := client.ReadTag(context.Background(), tag)
 	if err == nil {
 		t.Fatal("Expected error for unsupported register type, got none")
 	}
 
-	expected := "unsupported register type: InputRegister"
+	expected := "unsupported register type: FileRecord"
 	if err.Error() != expected {
 		t.Errorf("Expected '%s', got: %v", expected, err)
 	}
 }
 
+func TestReadTag_DiscreteInputRouting(t *testing.T) {
+	mock := &MockModbusClient{
+		coilsData:    []bool{false},
+		discreteData: []bool{true},
+	}
+	client := NewClientWithModbus(mock)
+
+	tag := model.ModbusTag{
+		Name:         "TestDITag",
+		RegisterType: "DiscreteInput",
+		Address:      1,
+		Size:         1,
+	}
+
+	result, err := client.ReadTag(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result != true {
+		t.Errorf("Expected true from discrete inputs, got: %v", result)
+	}
+}
+
+func TestReadDiscreteInput_MultipleInputs(t *testing.T) {
+	mock := &MockModbusClient{
+		discreteData: []bool{true, true, false},
+	}
+	client := NewClientWithModbus(mock)
+
+	tag := model.ModbusTag{
+		Name:         "TestDIs",
+		RegisterType: "DiscreteInput",
+		Address:      1,
+		Size:         3,
+	}
+
+	result, err := client.readDiscreteInput(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	inputs, ok := result.([]bool)
+	if !ok {
+		t.Fatalf("Expected []bool, got type: %T", result)
+	}
+	if len(inputs) != 3 || !inputs[0] || !inputs[1] || inputs[2] {
+		t.Errorf("Expected [true true false], got: %v", inputs)
+	}
+}
+
+func TestReadDiscreteInput_Error(t *testing.T) {
+	mock := &MockModbusClient{
+		discreteError: errors.New("discrete input read error"),
+	}
+	client := NewClientWithModbus(mock)
+
+	tag := model.ModbusTag{
+		Name:         "TestDI",
+		RegisterType: "DiscreteInput",
+		Address:      1,
+		Size:         1,
+	}
+
+	_, err := client.readDiscreteInput(context.Background(), tag)
+	if err == nil {
+		t.Fatal("Expected error, got none")
+	}
+
+	if err.Error() != "discrete input read error" {
+		t.Errorf("Expected 'discrete input read error', got: %v", err)
+	}
+}
+
+func TestReadTag_InputRegisterRouting(t *testing.T) {
+	mock := &MockModbusClient{
+		registersData: []uint16{0x4270, 0x0000},
+	}
+	client := NewClientWithModbus(mock)
+
+	tag := model.ModbusTag{
+		Name:         "TestIRTag",
+		RegisterType: "InputRegister",
+		Address:      1,
+		Size:         2,
+	}
+
+	result, err := client.ReadTag(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if mock.lastRegType != modbus.INPUT_REGISTER {
+		t.Errorf("Expected INPUT_REGISTER, got: %v", mock.lastRegType)
+	}
+
+	if result != float32(60.0) {
+		t.Errorf("Expected float32 value 60.0, got: %v", result)
+	}
+}
+
+func TestReadHoldingRegister_UsesHoldingRegisterType(t *testing.T) {
+	mock := &MockModbusClient{
+		registersData: []uint16{1},
+		lastRegType:   modbus.INPUT_REGISTER,
+	}
+	client := NewClientWithModbus(mock)
+
+	tag := model.ModbusTag{
+		Name:         "TestHR",
+		RegisterType: "HoldingRegister",
+		Address:      1,
+		Size:         1,
+	}
+
+	if _, err := client.ReadTag(context.Background(), tag); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if mock.lastRegType != modbus.HOLDING_REGISTER {
+		t.Errorf("Expected HOLDING_REGISTER, got: %v", mock.lastRegType)
+	}
+}
+
 func TestReadCoil_AddressConversion(t *testing.T) {
 	mock := &MockModbusClient{
 		coilsData: []bool{true},
@@ -454,7 +607,7 @@ func TestReadCoil_AddressConversion(t *testing.T) {
 		Size:         1,
 	}
 
-	result, err := client.readCoil(tag)
+	result, err := client.readCoil(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
@@ -464,36 +617,32 @@ func TestReadCoil_AddressConversion(t *testing.T) {
 	}
 }
 
-func TestFormatTagValue_MapValue(t *testing.T) {
+func TestFormatTagValue_WithDataType(t *testing.T) {
 	client := &Client{}
 
 	testCases := []struct {
 		name     string
-		value    map[string]any
+		tag      model.ModbusTag
+		value    any
 		expected string
 	}{
 		{
-			name: "reasonable float",
-			value: map[string]any{
-				"float32": float32(60.0),
-				"int32":   int32(1114636288),
-			},
-			expected: "float32: 60 (int32: 1114636288)",
+			name:     "explicit type",
+			tag:      model.ModbusTag{Name: "test", RegisterType: "HoldingRegister", Size: 2, DataType: "DINT"},
+			value:    int32(100),
+			expected: "100 (DINT)",
 		},
 		{
-			name: "reasonable int",
-			value: map[string]any{
-				"float32": float32(1.234e-10), // Very small float
-				"int32":   int32(100),
-			},
-			expected: "int32: 100 (float32: 1.234e-10)",
+			name:     "derived type",
+			tag:      model.ModbusTag{Name: "test", RegisterType: "HoldingRegister", Size: 2},
+			value:    float32(60),
+			expected: "60 (REAL)",
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			tag := model.ModbusTag{Name: "test"}
-			result := client.FormatTagValue(tag, tc.value)
+			result := client.FormatTagValue(tc.tag, tc.value)
 			if result != tc.expected {
 				t.Errorf("Expected '%s', got '%s'", tc.expected, result)
 			}
@@ -529,7 +678,7 @@ func TestModbusConnection(t *testing.T) {
 		Range:         "1..1",
 	}
 
-	val, err := client.ReadTag(tag)
+	val, err := client.ReadTag(context.Background(), tag)
 	if err != nil {
 		t.Errorf("Failed to read tag: %v", err)
 	}
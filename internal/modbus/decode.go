@@ -0,0 +1,128 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"opcmss/internal/model"
+)
+
+// decodeRegisters converts raw register words into the Go type of the tag's
+// data type. 32- and 64-bit values are reordered according to the tag's byte
+// order, which defaults to big-endian with the high word first.
+func decodeRegisters(tag model.ModbusTag, data []uint16) (any, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data received")
+	}
+
+	dataType := tag.ResolvedDataType()
+	if dataType == "" {
+		// No data type for this size, return the registers as a slice
+		result := make([]int16, len(data))
+		for i, val := range data {
+			result[i] = int16(val)
+		}
+		return result, nil
+	}
+
+	count, err := model.RegisterCount(dataType)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < int(count) {
+		if model.IsString(dataType) {
+			return nil, fmt.Errorf("insufficient data for %s", dataType)
+		}
+		return nil, fmt.Errorf("insufficient data for %d-bit value", count*16)
+	}
+
+	buf := make([]byte, 2*count)
+	for i := range int(count) {
+		binary.BigEndian.PutUint16(buf[2*i:], data[i])
+	}
+	if model.HasByteOrder(dataType) {
+		if err := normaliseByteOrder(buf, tag.ByteOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	switch dataType {
+	case model.TypeBOOL:
+		return data[0] != 0, nil
+	case model.TypeINT:
+		return int16(data[0]), nil
+	case model.TypeUINT, model.TypeWORD:
+		return data[0], nil
+	case model.TypeDINT:
+		return int32(binary.BigEndian.Uint32(buf)), nil
+	case model.TypeUDINT, model.TypeDWORD:
+		return binary.BigEndian.Uint32(buf), nil
+	case model.TypeREAL:
+		return math.Float32frombits(binary.BigEndian.Uint32(buf)), nil
+	case model.TypeLINT:
+		return int64(binary.BigEndian.Uint64(buf)), nil
+	case model.TypeLREAL:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+	default:
+		// STRING[n]: one byte per character, terminated early by a NUL
+		length, _ := model.StringLength(dataType)
+		text := buf[:min(length, len(buf))]
+		if i := bytes.IndexByte(text, 0); i >= 0 {
+			text = text[:i]
+		}
+		return string(text), nil
+	}
+}
+
+// normaliseByteOrder rewrites a multi-register value in place so it reads as
+// big-endian ABCD
+func normaliseByteOrder(buf []byte, order string) error {
+	var wordSwap, byteSwap bool
+	switch order {
+	case model.ByteOrderABCD, "":
+	case model.ByteOrderCDAB:
+		wordSwap = true
+	case model.ByteOrderBADC:
+		byteSwap = true
+	case model.ByteOrderDCBA:
+		wordSwap, byteSwap = true, true
+	default:
+		return fmt.Errorf("unsupported byte order %q", order)
+	}
+
+	if wordSwap {
+		words := len(buf) / 2
+		for i := 0; i < words/2; i++ {
+			j := words - 1 - i
+			buf[2*i], buf[2*j] = buf[2*j], buf[2*i]
+			buf[2*i+1], buf[2*j+1] = buf[2*j+1], buf[2*i+1]
+		}
+	}
+	if byteSwap {
+		for i := 0; i+1 < len(buf); i += 2 {
+			buf[i], buf[i+1] = buf[i+1], buf[i]
+		}
+	}
+	return nil
+}
+
+// OrderedValue is a register tag decoded with one particular byte order
+type OrderedValue struct {
+	ByteOrder string
+	Value     any
+	Err       error
+}
+
+// DecodeAllByteOrders decodes the same registers with every byte order, to
+// find out which layout a device uses
+func DecodeAllByteOrders(tag model.ModbusTag, data []uint16) []OrderedValue {
+	values := make([]OrderedValue, len(model.ByteOrders))
+	for i, order := range model.ByteOrders {
+		tag.ByteOrder = order
+		value, err := decodeRegisters(tag, data)
+		values[i] = OrderedValue{ByteOrder: order, Value: value, Err: err}
+	}
+	return values
+}
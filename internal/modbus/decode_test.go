@@ -0,0 +1,181 @@
+package modbus
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func TestDecodeRegisters_DataTypes(t *testing.T) {
+	lrealBits := math.Float64bits(-2.5)
+
+	testCases := []struct {
+		dataType string
+		data     []uint16
+		expected any
+	}{
+		{"BOOL", []uint16{0x0001}, true},
+		{"BOOL", []uint16{0x0000}, false},
+		{"INT", []uint16{0xFFFF}, int16(-1)},
+		{"UINT", []uint16{0xFFFF}, uint16(65535)},
+		{"WORD", []uint16{0x1234}, uint16(0x1234)},
+		{"DINT", []uint16{0xFFFF, 0xFFFE}, int32(-2)},
+		{"UDINT", []uint16{0x0001, 0x0000}, uint32(65536)},
+		{"DWORD", []uint16{0xDEAD, 0xBEEF}, uint32(0xDEADBEEF)},
+		{"REAL", []uint16{0x4270, 0x0000}, float32(60)},
+		{"LINT", []uint16{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFD}, int64(-3)},
+		{"LREAL", []uint16{uint16(lrealBits >> 48), uint16(lrealBits >> 32), uint16(lrealBits >> 16), uint16(lrealBits)}, float64(-2.5)},
+		{"STRING[5]", []uint16{0x4845, 0x4C4C, 0x4F21}, "HELLO"},
+		{"STRING[8]", []uint16{0x4F4B, 0x0000, 0x5858, 0x5858}, "OK"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.dataType, func(t *testing.T) {
+			tag := model.ModbusTag{RegisterType: "HoldingRegister", Size: uint16(len(tc.data)), DataType: tc.dataType}
+			result, err := decodeRegisters(tag, tc.data)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %v (%T), got %v (%T)", tc.expected, tc.expected, result, result)
+			}
+		})
+	}
+}
+
+func TestDecodeRegisters_InsufficientData(t *testing.T) {
+	testCases := []struct {
+		dataType string
+		data     []uint16
+		expected string
+	}{
+		{"LREAL", []uint16{1, 2}, "insufficient data for 64-bit value"},
+		{"STRING[10]", []uint16{1, 2}, "insufficient data for STRING[10]"},
+	}
+
+	for _, tc := range testCases {
+		tag := model.ModbusTag{RegisterType: "HoldingRegister", DataType: tc.dataType}
+		_, err := decodeRegisters(tag, tc.data)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("%s: expected %q, got %v", tc.dataType, tc.expected, err)
+		}
+	}
+}
+
+func TestDecodeRegisters_ByteOrders(t *testing.T) {
+	// 60.0 as REAL is 0x42700000, bytes A=0x42 B=0x70 C=0x00 D=0x00
+	testCases := []struct {
+		order string
+		data  []uint16
+	}{
+		{"ABCD", []uint16{0x4270, 0x0000}},
+		{"CDAB", []uint16{0x0000, 0x4270}},
+		{"BADC", []uint16{0x7042, 0x0000}},
+		{"DCBA", []uint16{0x0000, 0x7042}},
+		{"", []uint16{0x4270, 0x0000}},
+	}
+
+	for _, tc := range testCases {
+		tag := model.ModbusTag{RegisterType: "HoldingRegister", Size: 2, DataType: "REAL", ByteOrder: tc.order}
+		result, err := decodeRegisters(tag, tc.data)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tc.order, err)
+		}
+		if result != float32(60) {
+			t.Errorf("%s: expected 60, got %v", tc.order, result)
+		}
+	}
+}
+
+func TestDecodeRegisters_ByteOrders64Bit(t *testing.T) {
+	// 0x0102030405060708 as LINT
+	testCases := []struct {
+		order string
+		data  []uint16
+	}{
+		{"ABCD", []uint16{0x0102, 0x0304, 0x0506, 0x0708}},
+		{"CDAB", []uint16{0x0708, 0x0506, 0x0304, 0x0102}},
+		{"BADC", []uint16{0x0201, 0x0403, 0x0605, 0x0807}},
+		{"DCBA", []uint16{0x0807, 0x0605, 0x0403, 0x0201}},
+	}
+
+	for _, tc := range testCases {
+		tag := model.ModbusTag{RegisterType: "HoldingRegister", Size: 4, DataType: "LINT", ByteOrder: tc.order}
+		result, err := decodeRegisters(tag, tc.data)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tc.order, err)
+		}
+		if result != int64(0x0102030405060708) {
+			t.Errorf("%s: expected 0x0102030405060708, got %#x", tc.order, result)
+		}
+	}
+}
+
+func TestDecodeRegisters_ByteOrderLeaves16BitAlone(t *testing.T) {
+	tag := model.ModbusTag{RegisterType: "HoldingRegister", Size: 1, DataType: "WORD", ByteOrder: "DCBA"}
+	result, err := decodeRegisters(tag, []uint16{0x1234})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result != uint16(0x1234) {
+		t.Errorf("Expected 0x1234, got %#x", result)
+	}
+}
+
+func TestDecodeRegisters_ByteOrderLeavesStringsAlone(t *testing.T) {
+	// STRING[4] spans two registers like a 32-bit value, but is never swapped
+	for _, order := range model.ByteOrders {
+		tag := model.ModbusTag{RegisterType: "HoldingRegister", Size: 2, DataType: "STRING[4]", ByteOrder: order}
+		result, err := decodeRegisters(tag, []uint16{0x5055, 0x4d50}) // "PUMP"
+		if err != nil || result != "PUMP" {
+			t.Errorf("%s: expected PUMP, got %q (err %v)", order, result, err)
+		}
+
+		registers, err := encodeRegisters(tag, "PUMP")
+		if err != nil || len(registers) != 2 || registers[0] != 0x5055 || registers[1] != 0x4d50 {
+			t.Errorf("%s: expected registers [5055 4d50], got %04x (err %v)", order, registers, err)
+		}
+	}
+}
+
+func TestDecodeAllByteOrders(t *testing.T) {
+	tag := model.ModbusTag{RegisterType: "HoldingRegister", Size: 2, DataType: "DINT"}
+	values := DecodeAllByteOrders(tag, []uint16{0x0000, 0x0001})
+
+	expected := map[string]int32{"ABCD": 1, "CDAB": 65536, "BADC": 256, "DCBA": 16777216}
+	if len(values) != 4 {
+		t.Fatalf("Expected 4 interpretations, got %d", len(values))
+	}
+	for _, v := range values {
+		if v.Err != nil || v.Value != expected[v.ByteOrder] {
+			t.Errorf("%s: expected %d, got %v (err %v)", v.ByteOrder, expected[v.ByteOrder], v.Value, v.Err)
+		}
+	}
+}
+
+func TestClient_ByteOrderDefaultAndOverride(t *testing.T) {
+	mock := &MockModbusClient{registersData: []uint16{0x0000, 0x4270}}
+	client := NewClientWithModbus(mock)
+
+	if err := client.SetByteOrder("cdab"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := client.SetByteOrder("XYZW"); err == nil {
+		t.Error("Expected error for unknown byte order, got none")
+	}
+
+	tag := model.ModbusTag{RegisterType: "HoldingRegister", Address: 1, Size: 2, DataType: "REAL"}
+	result, err := client.ReadTag(context.Background(), tag)
+	if err != nil || result != float32(60) {
+		t.Errorf("Expected connection byte order to give 60, got %v (err %v)", result, err)
+	}
+
+	// A tag's own byte order wins over the connection default
+	tag.ByteOrder = "ABCD"
+	result, err = client.ReadTag(context.Background(), tag)
+	if err != nil || result == float32(60) {
+		t.Errorf("Expected tag byte order to override, got %v (err %v)", result, err)
+	}
+}
@@ -0,0 +1,163 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"opcmss/internal/model"
+)
+
+// encodeRegisters converts a value into register words for the tag's data
+// type and byte order. It is the inverse of decodeRegisters.
+func encodeRegisters(tag model.ModbusTag, value any) ([]uint16, error) {
+	dataType := tag.ResolvedDataType()
+	if dataType == "" {
+		return nil, fmt.Errorf("no data type for %d registers", tag.Size)
+	}
+
+	count, err := model.RegisterCount(dataType)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2*count)
+
+	switch dataType {
+	case model.TypeBOOL:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %T as BOOL", value)
+		}
+		if b {
+			buf[1] = 1
+		}
+	case model.TypeINT, model.TypeUINT, model.TypeWORD,
+		model.TypeDINT, model.TypeUDINT, model.TypeDWORD, model.TypeLINT:
+		n, err := integerFor(dataType, value)
+		if err != nil {
+			return nil, err
+		}
+		switch count {
+		case 1:
+			binary.BigEndian.PutUint16(buf, uint16(n))
+		case 2:
+			binary.BigEndian.PutUint32(buf, uint32(n))
+		default:
+			binary.BigEndian.PutUint64(buf, uint64(n))
+		}
+	case model.TypeREAL:
+		f, ok := asFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %T as REAL", value)
+		}
+		binary.BigEndian.PutUint32(buf, math.Float32bits(float32(f)))
+	case model.TypeLREAL:
+		f, ok := asFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %T as LREAL", value)
+		}
+		binary.BigEndian.PutUint64(buf, math.Float64bits(f))
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %T as %s", value, dataType)
+		}
+		length, _ := model.StringLength(dataType)
+		if len(s) > length {
+			return nil, fmt.Errorf("value %q is longer than %s", s, dataType)
+		}
+		copy(buf, s)
+	}
+
+	if model.HasByteOrder(dataType) {
+		// Each reordering step is its own inverse
+		if err := normaliseByteOrder(buf, tag.ByteOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	registers := make([]uint16, count)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(buf[2*i:])
+	}
+	return registers, nil
+}
+
+// encodeCoils converts a bool, or a slice of bools for multi-coil tags, into coil states
+func encodeCoils(tag model.ModbusTag, value any) ([]bool, error) {
+	switch v := value.(type) {
+	case bool:
+		if tag.Size > 1 {
+			return nil, fmt.Errorf("tag has %d coils, got a single bool", tag.Size)
+		}
+		return []bool{v}, nil
+	case []bool:
+		if len(v) != int(tag.Size) {
+			return nil, fmt.Errorf("tag has %d coils, got %d values", tag.Size, len(v))
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot encode %T as coils", value)
+	}
+}
+
+// integerRanges holds the valid range of each integer data type
+var integerRanges = map[string][2]int64{
+	model.TypeINT:   {math.MinInt16, math.MaxInt16},
+	model.TypeUINT:  {0, math.MaxUint16},
+	model.TypeWORD:  {0, math.MaxUint16},
+	model.TypeDINT:  {math.MinInt32, math.MaxInt32},
+	model.TypeUDINT: {0, math.MaxUint32},
+	model.TypeDWORD: {0, math.MaxUint32},
+	model.TypeLINT:  {math.MinInt64, math.MaxInt64},
+}
+
+// integerFor converts an integer value and checks it fits the data type
+func integerFor(dataType string, value any) (int64, error) {
+	n, ok := asInt64(value)
+	if !ok {
+		return 0, fmt.Errorf("cannot encode %T as %s", value, dataType)
+	}
+	limits := integerRanges[dataType]
+	if n < limits[0] || n > limits[1] {
+		return 0, fmt.Errorf("value %d out of range for %s", n, dataType)
+	}
+	return n, nil
+}
+
+func asInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func asFloat64(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	if n, ok := asInt64(value); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package modbus
+
+import (
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func TestEncodeRegisters_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		dataType  string
+		byteOrder string
+		value     any
+	}{
+		{"BOOL", "", true},
+		{"INT", "", int16(-1234)},
+		{"UINT", "", uint16(65535)},
+		{"DINT", "CDAB", int32(-2)},
+		{"UDINT", "BADC", uint32(0xDEADBEEF)},
+		{"REAL", "DCBA", float32(60.5)},
+		{"LINT", "CDAB", int64(-3)},
+		{"LREAL", "BADC", float64(-2.5)},
+		{"STRING[5]", "", "HELLO"},
+		{"STRING[8]", "", "OK"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.dataType+"/"+tc.byteOrder, func(t *testing.T) {
+			tag := model.ModbusTag{RegisterType: "HoldingRegister", DataType: tc.dataType, ByteOrder: tc.byteOrder}
+			registers, err := encodeRegisters(tag, tc.value)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			result, err := decodeRegisters(tag, registers)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != tc.value {
+				t.Errorf("Expected %v (%T), got %v (%T)", tc.value, tc.value, result, result)
+			}
+		})
+	}
+}
+
+func TestEncodeRegisters_Errors(t *testing.T) {
+	testCases := []struct {
+		dataType string
+		value    any
+		expected string
+	}{
+		{"INT", 40000, "value 40000 out of range for INT"},
+		{"UINT", -1, "value -1 out of range for UINT"},
+		{"REAL", "x", "cannot encode string as REAL"},
+		{"STRING[2]", "ABC", `value "ABC" is longer than STRING[2]`},
+	}
+
+	for _, tc := range testCases {
+		tag := model.ModbusTag{RegisterType: "HoldingRegister", DataType: tc.dataType}
+		_, err := encodeRegisters(tag, tc.value)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("%s: expected error %q, got %v", tc.dataType, tc.expected, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package modbus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Endpoint schemes. An endpoint without a scheme is Modbus TCP.
+const (
+	SchemeTCP        = "tcp"        // Modbus TCP, host:port
+	SchemeUDP        = "udp"        // Modbus TCP framing over UDP, host:port
+	SchemeRTU        = "rtu"        // Modbus RTU on a serial line, a device like /dev/ttyUSB0
+	SchemeRTUOverTCP = "rtuovertcp" // RTU frames through a TCP serial gateway, host:port
+)
+
+// ParseEndpoint splits an endpoint like rtu:///dev/ttyUSB0 or
+// rtuovertcp://gateway:4001 into its scheme and address. A plain host:port
+// is Modbus TCP.
+func ParseEndpoint(endpoint string) (scheme, address string, err error) {
+	scheme, address, found := strings.Cut(endpoint, "://")
+	if !found {
+		scheme, address = SchemeTCP, endpoint
+	}
+	switch scheme {
+	case SchemeTCP, SchemeUDP, SchemeRTU, SchemeRTUOverTCP:
+	default:
+		return "", "", fmt.Errorf("unsupported Modbus endpoint scheme %q, use tcp, udp, rtu or rtuovertcp", scheme)
+	}
+	if address == "" {
+		return "", "", fmt.Errorf("Modbus endpoint %q has no address", endpoint)
+	}
+	return scheme, address, nil
+}
+
+// Serial holds the line settings of an rtu:// endpoint. Zero values keep the
+// defaults: 19200 baud, 8 data bits, no parity, and 2 stop bits without
+// parity or 1 with.
+type Serial struct {
+	BaudRate uint
+	Parity   string // none, even or odd
+	StopBits uint   // 1 or 2
+}
+
+// parity returns the library's parity mode for s.Parity
+func (s Serial) parity() (uint, error) {
+	switch strings.ToLower(s.Parity) {
+	case "", "none", "n":
+		return modbus.PARITY_NONE, nil
+	case "even", "e":
+		return modbus.PARITY_EVEN, nil
+	case "odd", "o":
+		return modbus.PARITY_ODD, nil
+	default:
+		return 0, fmt.Errorf("unsupported parity %q, use none, even or odd", s.Parity)
+	}
+}
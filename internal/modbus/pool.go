@@ -0,0 +1,90 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+
+	"github.com/simonvetter/modbus"
+)
+
+// connPool shares a Client's connections between concurrent calls. A Modbus
+// TCP connection answers one request at a time, so each call takes an idle
+// connection and waits while all of them are busy.
+type connPool struct {
+	conns []*reconnectingClient
+	idle  chan *reconnectingClient
+}
+
+func newConnPool(conns []*reconnectingClient) *connPool {
+	p := &connPool{conns: conns, idle: make(chan *reconnectingClient, len(conns))}
+	for _, conn := range conns {
+		p.idle <- conn
+	}
+	return p
+}
+
+// size returns the number of connections, the most calls that run at once
+func (p *connPool) size() int {
+	return len(p.conns)
+}
+
+// with runs fn on an idle connection, or returns ctx.Err() when ctx is done
+// before one is free
+func (p *connPool) with(ctx context.Context, fn func(conn *reconnectingClient) error) error {
+	select {
+	case conn := <-p.idle:
+		defer func() { p.idle <- conn }()
+		return fn(conn)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *connPool) ReadCoils(ctx context.Context, address, quantity uint16) (values []bool, err error) {
+	err = p.with(ctx, func(conn *reconnectingClient) error {
+		values, err = conn.ReadCoils(ctx, address, quantity)
+		return err
+	})
+	return values, err
+}
+
+func (p *connPool) ReadDiscreteInputs(ctx context.Context, address, quantity uint16) (values []bool, err error) {
+	err = p.with(ctx, func(conn *reconnectingClient) error {
+		values, err = conn.ReadDiscreteInputs(ctx, address, quantity)
+		return err
+	})
+	return values, err
+}
+
+func (p *connPool) ReadRegisters(ctx context.Context, address, quantity uint16, regType modbus.RegType) (values []uint16, err error) {
+	err = p.with(ctx, func(conn *reconnectingClient) error {
+		values, err = conn.ReadRegisters(ctx, address, quantity, regType)
+		return err
+	})
+	return values, err
+}
+
+func (p *connPool) WriteCoil(ctx context.Context, address uint16, value bool) error {
+	return p.with(ctx, func(conn *reconnectingClient) error { return conn.WriteCoil(ctx, address, value) })
+}
+
+func (p *connPool) WriteCoils(ctx context.Context, address uint16, values []bool) error {
+	return p.with(ctx, func(conn *reconnectingClient) error { return conn.WriteCoils(ctx, address, values) })
+}
+
+func (p *connPool) WriteRegister(ctx context.Context, address uint16, value uint16) error {
+	return p.with(ctx, func(conn *reconnectingClient) error { return conn.WriteRegister(ctx, address, value) })
+}
+
+func (p *connPool) WriteRegisters(ctx context.Context, address uint16, values []uint16) error {
+	return p.with(ctx, func(conn *reconnectingClient) error { return conn.WriteRegisters(ctx, address, values) })
+}
+
+// Close closes every connection, busy or not
+func (p *connPool) Close() error {
+	var errs []error
+	for _, conn := range p.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package modbus
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// pty is a pseudo-terminal. The simulator serves on the master end, and
+// clients open the device of the other end like a serial port.
+type pty struct {
+	master *os.File
+	slave  *os.File // held open, so the master does not fail between clients
+	device string
+}
+
+// openPTY opens a pseudo-terminal in raw mode
+func openPTY() (*pty, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a pseudo-terminal: %w", err)
+	}
+
+	var number uint32
+	var unlock int32
+	err = control(master, func(fd uintptr) error {
+		if err := ioctl(fd, syscall.TIOCGPTN, unsafe.Pointer(&number)); err != nil {
+			return err
+		}
+		return ioctl(fd, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock))
+	})
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to set up the pseudo-terminal: %w", err)
+	}
+
+	device := fmt.Sprintf("/dev/pts/%d", number)
+	slave, err := os.OpenFile(device, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err == nil {
+		err = control(slave, makeRaw)
+	}
+	if err != nil {
+		if slave != nil {
+			slave.Close()
+		}
+		master.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", device, err)
+	}
+	return &pty{master: master, slave: slave, device: device}, nil
+}
+
+// makeRaw turns off the line editing, echo and character translation of a
+// terminal, so bytes pass through unchanged
+func makeRaw(fd uintptr) error {
+	var t syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 1, 0
+	return ioctl(fd, syscall.TCSETS, unsafe.Pointer(&t))
+}
+
+// control runs fn on the file's descriptor without switching it to blocking
+// mode, so closing the file still ends a pending read
+func control(f *os.File, fn func(fd uintptr) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) { fnErr = fn(fd) }); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Close closes both ends
+func (p *pty) Close() error {
+	p.slave.Close()
+	return p.master.Close()
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package modbus
+
+import (
+	"errors"
+	"os"
+)
+
+// pty is a pseudo-terminal, only available on Linux
+type pty struct {
+	master *os.File
+	device string
+}
+
+func openPTY() (*pty, error) {
+	return nil, errors.New("serving Modbus RTU on a pseudo-terminal is only supported on Linux")
+}
+
+func (p *pty) Close() error {
+	return p.master.Close()
+}
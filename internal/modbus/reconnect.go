@@ -0,0 +1,177 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
+
+	"github.com/simonvetter/modbus"
+)
+
+// ErrDisconnected is returned by calls made while the connection is down and
+// the next reconnect attempt is not due yet
+var ErrDisconnected = errors.New("not connected to the Modbus server")
+
+// IsTransient reports whether a call that failed with err may succeed when
+// repeated: a lost connection, a timeout, a busy server or a gateway that
+// could not reach the device
+func IsTransient(err error) bool {
+	return connectionLost(err) ||
+		errors.Is(err, ErrDisconnected) ||
+		errors.Is(err, modbus.ErrGWPathUnavailable) ||
+		errors.Is(err, modbus.ErrGWTargetFailedToRespond) ||
+		errors.Is(err, modbus.ErrServerDeviceBusy) ||
+		errors.Is(err, modbus.ErrBadCRC) ||
+		errors.Is(err, modbus.ErrShortFrame)
+}
+
+// connectionLost reports whether the connection has to be reopened after a
+// call failed with err. That includes timeouts and garbled responses, as a
+// late answer would otherwise be taken for the answer to the next request.
+func connectionLost(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, modbus.ErrRequestTimedOut) ||
+		errors.Is(err, modbus.ErrBadTransactionId) ||
+		errors.Is(err, modbus.ErrProtocolError) ||
+		errors.As(err, &netErr)
+}
+
+// reconnectingClient reopens the connection of a ModbusClient after it was
+// lost and retries the calls that failed with a transient error. The context
+// of a call stops its retries, but a request that was sent runs until it is
+// answered or the ModbusClient's timeout passes. Every request, retries
+// included, waits for its turn with the limiter.
+type reconnectingClient struct {
+	client   ModbusClient
+	recovery retry.Recovery
+	limiter  *throttle.Limiter // nil for no limit
+
+	mu       sync.Mutex
+	down     bool      // the connection was lost and is not reopened yet
+	failures int       // failed reconnect attempts in a row
+	nextDial time.Time // when the next reconnect attempt is due
+}
+
+func newReconnectingClient(client ModbusClient, recovery retry.Recovery, limiter *throttle.Limiter) *reconnectingClient {
+	return &reconnectingClient{client: client, recovery: recovery, limiter: limiter}
+}
+
+func (r *reconnectingClient) read(ctx context.Context, fn func() error) error {
+	return r.recovery.Read.Do(ctx, IsTransient, func() error { return r.call(ctx, fn) })
+}
+
+func (r *reconnectingClient) write(ctx context.Context, fn func() error) error {
+	return r.recovery.Write.Do(ctx, IsTransient, func() error { return r.call(ctx, fn) })
+}
+
+// call runs fn on an open connection and marks the connection as lost when
+// fn fails because of it
+func (r *reconnectingClient) call(ctx context.Context, fn func() error) error {
+	if err := r.reconnect(); err != nil {
+		return err
+	}
+	if err := r.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	err := fn()
+	if err != nil && connectionLost(err) {
+		r.lost(err)
+	}
+	return err
+}
+
+// reconnect reopens a lost connection once the backoff after the previous
+// attempt has passed
+func (r *reconnectingClient) reconnect() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.down {
+		return nil
+	}
+	if wait := time.Until(r.nextDial); wait > 0 {
+		return fmt.Errorf("%w, reconnecting in %s", ErrDisconnected, wait.Round(time.Millisecond))
+	}
+
+	r.recovery.Notify(retry.StateReconnecting, nil)
+	r.client.Close()
+	if err := r.client.Open(); err != nil {
+		r.nextDial = time.Now().Add(r.recovery.Reconnect.Delay(r.failures))
+		r.failures++
+		r.recovery.Notify(retry.StateDisconnected, err)
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+
+	r.down, r.failures = false, 0
+	r.recovery.Notify(retry.StateConnected, nil)
+	return nil
+}
+
+func (r *reconnectingClient) lost(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.down {
+		return
+	}
+	r.down, r.nextDial = true, time.Time{}
+	r.recovery.Notify(retry.StateDisconnected, err)
+}
+
+func (r *reconnectingClient) ReadCoils(ctx context.Context, address, quantity uint16) (values []bool, err error) {
+	err = r.read(ctx, func() error {
+		values, err = r.client.ReadCoils(address, quantity)
+		return err
+	})
+	return values, err
+}
+
+func (r *reconnectingClient) ReadDiscreteInputs(ctx context.Context, address, quantity uint16) (values []bool, err error) {
+	err = r.read(ctx, func() error {
+		values, err = r.client.ReadDiscreteInputs(address, quantity)
+		return err
+	})
+	return values, err
+}
+
+func (r *reconnectingClient) ReadRegisters(ctx context.Context, address, quantity uint16, regType modbus.RegType) (values []uint16, err error) {
+	err = r.read(ctx, func() error {
+		values, err = r.client.ReadRegisters(address, quantity, regType)
+		return err
+	})
+	return values, err
+}
+
+func (r *reconnectingClient) WriteCoil(ctx context.Context, address uint16, value bool) error {
+	return r.write(ctx, func() error { return r.client.WriteCoil(address, value) })
+}
+
+func (r *reconnectingClient) WriteCoils(ctx context.Context, address uint16, values []bool) error {
+	return r.write(ctx, func() error { return r.client.WriteCoils(address, values) })
+}
+
+func (r *reconnectingClient) WriteRegister(ctx context.Context, address uint16, value uint16) error {
+	return r.write(ctx, func() error { return r.client.WriteRegister(address, value) })
+}
+
+func (r *reconnectingClient) WriteRegisters(ctx context.Context, address uint16, values []uint16) error {
+	return r.write(ctx, func() error { return r.client.WriteRegisters(address, values) })
+}
+
+func (r *reconnectingClient) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,208 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestIsTransient(t *testing.T) {
+	testCases := []struct {
+		err       error
+		transient bool
+		lost      bool
+	}{
+		{io.EOF, true, true},
+		{fmt.Errorf("read: %w", modbus.ErrRequestTimedOut), true, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true, true},
+		{modbus.ErrGWTargetFailedToRespond, true, false},
+		{modbus.ErrServerDeviceBusy, true, false},
+		{ErrDisconnected, true, false},
+		{modbus.ErrIllegalDataAddress, false, false},
+		{errors.New("unsupported register type"), false, false},
+	}
+	for _, tc := range testCases {
+		if got := IsTransient(tc.err); got != tc.transient {
+			t.Errorf("IsTransient(%v): expected %v, got %v", tc.err, tc.transient, got)
+		}
+		if got := connectionLost(tc.err); got != tc.lost {
+			t.Errorf("connectionLost(%v): expected %v, got %v", tc.err, tc.lost, got)
+		}
+	}
+}
+
+// flakyModbusClient fails the next reads with the queued errors and counts
+// how often the connection was reopened
+type flakyModbusClient struct {
+	memoryModbusClient
+	failures []error
+	openErr  error
+	opens    int
+}
+
+func (f *flakyModbusClient) ReadRegisters(address, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return nil, err
+	}
+	return f.memoryModbusClient.ReadRegisters(address, quantity, regType)
+}
+
+func (f *flakyModbusClient) Open() error {
+	f.opens++
+	return f.openErr
+}
+
+// recordStates returns a recovery that retries quickly and records the
+// connection states it reports
+func recordStates(attempts int, states *[]string) retry.Recovery {
+	policy := retry.Policy{Attempts: attempts, Backoff: retry.Backoff{Initial: time.Millisecond}}
+	return retry.Recovery{
+		Read:      policy,
+		Write:     policy,
+		Reconnect: retry.Backoff{Initial: time.Hour},
+		OnState: func(state retry.State, err error) {
+			*states = append(*states, string(state))
+		},
+	}
+}
+
+func TestReconnectingClient_RetriesTransientErrors(t *testing.T) {
+	tag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"}
+
+	testCases := []struct {
+		name     string
+		failures []error
+		attempts int
+		err      error
+		opens    int
+		states   string
+	}{
+		{"lost connection", []error{io.EOF}, 2, nil, 1, "[disconnected reconnecting connected]"},
+		{"gateway", []error{modbus.ErrGWPathUnavailable, modbus.ErrGWPathUnavailable}, 3, nil, 0, "[]"},
+		{"gives up", []error{modbus.ErrServerDeviceBusy, modbus.ErrServerDeviceBusy}, 2, modbus.ErrServerDeviceBusy, 0, "[]"},
+		{"permanent", []error{modbus.ErrIllegalDataAddress}, 3, modbus.ErrIllegalDataAddress, 0, "[]"},
+		{"no retries", []error{io.EOF}, 1, io.EOF, 0, "[disconnected]"},
+	}
+
+	for _, tc := range testCases {
+		mock := &flakyModbusClient{
+			memoryModbusClient: memoryModbusClient{registers: map[uint16]uint16{0: 7}},
+			failures:           tc.failures,
+		}
+		var states []string
+		client := NewClientWithModbus(mock, WithRecovery(recordStates(tc.attempts, &states)))
+
+		value, err := client.ReadTag(context.Background(), tag)
+		if !errors.Is(err, tc.err) || (tc.err == nil && value != int16(7)) {
+			t.Errorf("%s: expected 7 and %v, got %v and %v", tc.name, tc.err, value, err)
+		}
+		if mock.opens != tc.opens || fmt.Sprint(states) != tc.states {
+			t.Errorf("%s: expected %d reconnects and states %s, got %d and %v", tc.name, tc.opens, tc.states, mock.opens, states)
+		}
+	}
+}
+
+func TestReconnectingClient_RateLimitsRetries(t *testing.T) {
+	tag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"}
+	mock := &flakyModbusClient{
+		memoryModbusClient: memoryModbusClient{registers: map[uint16]uint16{0: 7}},
+		failures:           []error{modbus.ErrServerDeviceBusy, modbus.ErrServerDeviceBusy},
+	}
+	var states []string
+	recovery := recordStates(3, &states)
+	recovery.Read.Backoff = retry.Backoff{}
+	client := NewClientWithModbus(mock, WithRecovery(recovery), WithRateLimit(throttle.NewLimiter(50)))
+
+	start := time.Now()
+	if value, err := client.ReadTag(context.Background(), tag); err != nil || value != int16(7) {
+		t.Fatalf("Expected 7, got %v and %v", value, err)
+	}
+	// Three requests at 50 per second take at least 40ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the retries to be rate limited, took %s", elapsed)
+	}
+}
+
+func TestReconnectingClient_BacksOffFailedReconnects(t *testing.T) {
+	tag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"}
+	mock := &flakyModbusClient{
+		failures: []error{io.EOF},
+		openErr:  &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+	}
+	var states []string
+	client := NewClientWithModbus(mock, WithRecovery(recordStates(2, &states)))
+
+	if _, err := client.ReadTag(context.Background(), tag); err == nil {
+		t.Fatal("Expected an error while the server is down")
+	}
+	if mock.opens != 1 {
+		t.Errorf("Expected one reconnect attempt, got %d", mock.opens)
+	}
+
+	_, err := client.ReadTag(context.Background(), tag)
+	if !errors.Is(err, ErrDisconnected) {
+		t.Errorf("Expected ErrDisconnected before the next attempt is due, got %v", err)
+	}
+	if mock.opens != 1 {
+		t.Errorf("Expected no reconnect attempt during the backoff, got %d", mock.opens)
+	}
+	if fmt.Sprint(states) != "[disconnected reconnecting disconnected]" {
+		t.Errorf("Unexpected states: %v", states)
+	}
+}
+
+func TestClient_ReconnectsToRestartedServer(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start simulator: %v", err)
+	}
+	defer sim.Stop()
+
+	tag := model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"}
+	if err := sim.SetValue(tag, int16(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	var states []string
+	recovery := recordStates(3, &states)
+	recovery.Reconnect = retry.Backoff{Initial: 10 * time.Millisecond}
+	client, err := NewClient(sim.Address(), WithRecovery(recovery))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ReadTag(context.Background(), tag); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if err := sim.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Start(sim.Address()); err != nil {
+		t.Fatalf("Failed to restart simulator: %v", err)
+	}
+
+	value, err := client.ReadTag(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("Expected the read to reconnect, got: %v", err)
+	}
+	if value != int16(42) {
+		t.Errorf("Expected 42, got %v", value)
+	}
+	if len(states) < 3 || states[len(states)-1] != string(retry.StateConnected) {
+		t.Errorf("Expected the client to report the reconnect, got %v", states)
+	}
+}
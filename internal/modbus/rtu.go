@@ -0,0 +1,233 @@
+package modbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Modbus function codes the simulator answers over RTU
+const (
+	fcReadCoils              = 0x01
+	fcReadDiscreteInputs     = 0x02
+	fcReadHoldingRegisters   = 0x03
+	fcReadInputRegisters     = 0x04
+	fcWriteSingleCoil        = 0x05
+	fcWriteSingleRegister    = 0x06
+	fcWriteMultipleCoils     = 0x0f
+	fcWriteMultipleRegisters = 0x10
+)
+
+// Modbus exception codes
+const (
+	exIllegalFunction     = 0x01
+	exIllegalDataAddress  = 0x02
+	exIllegalDataValue    = 0x03
+	exServerDeviceFailure = 0x04
+)
+
+// maxQuantity is the largest quantity the specification allows per request,
+// so the byte count of the response fits in one byte
+var maxQuantity = map[byte]uint16{
+	fcReadCoils:              2000,
+	fcReadDiscreteInputs:     2000,
+	fcReadHoldingRegisters:   125,
+	fcReadInputRegisters:     125,
+	fcWriteMultipleCoils:     1968,
+	fcWriteMultipleRegisters: 123,
+}
+
+// crc16 returns the Modbus RTU checksum of data
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// appendCRC appends the checksum of frame, low byte first
+func appendCRC(frame []byte) []byte {
+	return binary.LittleEndian.AppendUint16(frame, crc16(frame))
+}
+
+// errBadCRC marks a request frame whose checksum does not match. A device
+// does not answer it.
+var errBadCRC = errors.New("bad CRC")
+
+// errUnknownFunction marks a request whose length cannot be told from its
+// function code
+var errUnknownFunction = errors.New("unsupported function code")
+
+// readRTURequest reads one request frame: the unit ID, the PDU and the CRC.
+// The PDU length follows from the function code.
+func readRTURequest(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, 2, 16)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+
+	var rest int
+	switch frame[1] {
+	case fcReadCoils, fcReadDiscreteInputs, fcReadHoldingRegisters, fcReadInputRegisters,
+		fcWriteSingleCoil, fcWriteSingleRegister:
+		rest = 4 + 2
+	case fcWriteMultipleCoils, fcWriteMultipleRegisters:
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		frame = append(frame, header...)
+		rest = int(header[4]) + 2
+	default:
+		return frame, errUnknownFunction
+	}
+
+	tail := make([]byte, rest)
+	if _, err := io.ReadFull(r, tail); err != nil {
+		return nil, err
+	}
+	frame = append(frame, tail...)
+
+	body := frame[:len(frame)-2]
+	if binary.LittleEndian.Uint16(frame[len(frame)-2:]) != crc16(body) {
+		return nil, errBadCRC
+	}
+	return body, nil
+}
+
+// serveRTU answers the RTU requests read from conn until reading fails.
+// Frames with a bad CRC are dropped like a device would.
+func (s *Simulator) serveRTU(conn io.ReadWriter) error {
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readRTURequest(r)
+		switch {
+		case errors.Is(err, errBadCRC):
+			continue
+		case errors.Is(err, errUnknownFunction):
+			// The rest of the frame cannot be told apart from the next one
+			r.Discard(r.Buffered())
+			req = req[:2]
+		case err != nil:
+			return err
+		}
+
+		if _, err := conn.Write(appendCRC(s.handlePDU(req))); err != nil {
+			return err
+		}
+	}
+}
+
+// handlePDU answers a request frame without its CRC and returns the response
+// frame, the unit ID first
+func (s *Simulator) handlePDU(req []byte) []byte {
+	unitID, fc := req[0], req[1]
+	res := []byte{unitID, fc}
+	if len(req) < 6 {
+		return exception(unitID, fc, exIllegalFunction)
+	}
+	addr := binary.BigEndian.Uint16(req[2:4])
+	quantity := binary.BigEndian.Uint16(req[4:6])
+	if limit, ok := maxQuantity[fc]; ok && (quantity == 0 || quantity > limit) {
+		return exception(unitID, fc, exIllegalDataValue)
+	}
+
+	var bits []bool
+	var registers []uint16
+	var err error
+
+	switch fc {
+	case fcReadCoils:
+		bits, err = s.HandleCoils(&modbus.CoilsRequest{UnitId: unitID, Addr: addr, Quantity: quantity})
+	case fcReadDiscreteInputs:
+		bits, err = s.HandleDiscreteInputs(&modbus.DiscreteInputsRequest{UnitId: unitID, Addr: addr, Quantity: quantity})
+	case fcReadHoldingRegisters:
+		registers, err = s.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: unitID, Addr: addr, Quantity: quantity})
+	case fcReadInputRegisters:
+		registers, err = s.HandleInputRegisters(&modbus.InputRegistersRequest{UnitId: unitID, Addr: addr, Quantity: quantity})
+	case fcWriteSingleCoil:
+		// The value is 0xFF00 for on and 0x0000 for off
+		if quantity != 0xff00 && quantity != 0 {
+			return exception(unitID, fc, exIllegalDataValue)
+		}
+		_, err = s.HandleCoils(&modbus.CoilsRequest{UnitId: unitID, Addr: addr, Quantity: 1, IsWrite: true, Args: []bool{quantity == 0xff00}})
+	case fcWriteSingleRegister:
+		_, err = s.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: unitID, Addr: addr, Quantity: 1, IsWrite: true, Args: []uint16{quantity}})
+	case fcWriteMultipleCoils:
+		values := req[7:]
+		if len(values) < (int(quantity)+7)/8 {
+			return exception(unitID, fc, exIllegalDataValue)
+		}
+		args := make([]bool, quantity)
+		for i := range args {
+			args[i] = values[i/8]&(1<<(i%8)) != 0
+		}
+		_, err = s.HandleCoils(&modbus.CoilsRequest{UnitId: unitID, Addr: addr, Quantity: quantity, IsWrite: true, Args: args})
+	case fcWriteMultipleRegisters:
+		values := req[7:]
+		if len(values) < 2*int(quantity) {
+			return exception(unitID, fc, exIllegalDataValue)
+		}
+		args := make([]uint16, quantity)
+		for i := range args {
+			args[i] = binary.BigEndian.Uint16(values[2*i:])
+		}
+		_, err = s.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{UnitId: unitID, Addr: addr, Quantity: quantity, IsWrite: true, Args: args})
+	default:
+		return exception(unitID, fc, exIllegalFunction)
+	}
+	if err != nil {
+		return exception(unitID, fc, exceptionCode(err))
+	}
+
+	switch fc {
+	case fcReadCoils, fcReadDiscreteInputs:
+		packed := make([]byte, (len(bits)+7)/8)
+		for i, bit := range bits {
+			if bit {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		res = append(res, byte(len(packed)))
+		return append(res, packed...)
+	case fcReadHoldingRegisters, fcReadInputRegisters:
+		res = append(res, byte(2*len(registers)))
+		for _, register := range registers {
+			res = binary.BigEndian.AppendUint16(res, register)
+		}
+		return res
+	default:
+		// Writes echo the address and the value or quantity
+		return append(res, req[2:6]...)
+	}
+}
+
+// exception returns an exception response for the function code
+func exception(unitID, fc, code byte) []byte {
+	return []byte{unitID, fc | 0x80, code}
+}
+
+// exceptionCode maps a handler error to its Modbus exception code
+func exceptionCode(err error) byte {
+	switch {
+	case errors.Is(err, modbus.ErrIllegalDataAddress):
+		return exIllegalDataAddress
+	case errors.Is(err, modbus.ErrIllegalDataValue):
+		return exIllegalDataValue
+	case errors.Is(err, modbus.ErrIllegalFunction):
+		return exIllegalFunction
+	default:
+		return exServerDeviceFailure
+	}
+}
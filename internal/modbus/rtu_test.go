@@ -0,0 +1,209 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	testCases := []struct {
+		endpoint, scheme, address string
+	}{
+		{"plc:502", "tcp", "plc:502"},
+		{"tcp://plc:502", "tcp", "plc:502"},
+		{"udp://plc:502", "udp", "plc:502"},
+		{"rtu:///dev/ttyUSB0", "rtu", "/dev/ttyUSB0"},
+		{"rtuovertcp://gateway:4001", "rtuovertcp", "gateway:4001"},
+	}
+	for _, tc := range testCases {
+		scheme, address, err := ParseEndpoint(tc.endpoint)
+		if err != nil || scheme != tc.scheme || address != tc.address {
+			t.Errorf("ParseEndpoint(%q): expected %s and %s, got %s, %s and %v", tc.endpoint, tc.scheme, tc.address, scheme, address, err)
+		}
+	}
+
+	for _, endpoint := range []string{"http://plc:502", "rtu://", "tcp+tls://plc:802"} {
+		if _, _, err := ParseEndpoint(endpoint); err == nil {
+			t.Errorf("ParseEndpoint(%q): expected an error", endpoint)
+		}
+	}
+	if _, err := NewClient("rtu:///dev/null", WithSerial(Serial{Parity: "mark"})); err == nil {
+		t.Error("Expected an error for an unsupported parity")
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	// Read 10 holding registers from unit 1, a frame from the specification
+	frame := appendCRC([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0a})
+	if frame[6] != 0xc5 || frame[7] != 0xcd {
+		t.Errorf("Expected CRC bytes c5 cd, got % x", frame[6:])
+	}
+}
+
+// rtuTags covers every register type and write function
+var rtuTags = []model.ModbusTag{
+	{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1, DataType: "BOOL"},
+	{Name: "Valves", RegisterType: "Coil", Address: 2, Size: 10},
+	{Name: "Alarm", RegisterType: "DiscreteInput", Address: 3, Size: 1, DataType: "BOOL"},
+	{Name: "Setpoint", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"},
+	{Name: "Flow", RegisterType: "HoldingRegister", Address: 2, Size: 2, DataType: "REAL"},
+	{Name: "Counter", RegisterType: "InputRegister", Address: 5, Size: 2, DataType: "UDINT"},
+}
+
+// checkRTU reads and writes every kind of tag through a simulator serving
+// RTU at endpoint
+func checkRTU(t *testing.T, endpoint string) {
+	t.Helper()
+
+	values := map[string]any{
+		"Pump":     true,
+		"Valves":   []bool{true, false, true, true, false, false, false, false, true, false},
+		"Alarm":    true,
+		"Setpoint": int16(-42),
+		"Flow":     float32(12.5),
+		"Counter":  uint32(123456),
+	}
+	sim := NewSimulator()
+	sim.Strict = true
+	if err := sim.Seed(rtuTags, values); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if err := sim.Start(endpoint); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { sim.Stop() })
+
+	client, err := NewClient(sim.Address(), WithSerial(Serial{BaudRate: 9600, Parity: "even", StopBits: 1}))
+	if err != nil {
+		t.Fatalf("NewClient(%s) failed: %v", sim.Address(), err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	for i, result := range client.ReadTags(ctx, rtuTags, DefaultBatchOptions()) {
+		tag := rtuTags[i]
+		if result.Err != nil || !reflect.DeepEqual(result.Value, values[tag.Name]) {
+			t.Errorf("%s: expected %v, got %v (err %v)", tag.Name, values[tag.Name], result.Value, result.Err)
+		}
+	}
+
+	writes := map[string]any{
+		"Pump":     false,
+		"Valves":   []bool{false, true, false, false, true, true, true, true, false, true},
+		"Setpoint": int16(7),
+		"Flow":     float32(-3.25),
+	}
+	for _, tag := range rtuTags {
+		value, ok := writes[tag.Name]
+		if !ok {
+			continue
+		}
+		readBack, err := client.WriteTagVerified(ctx, tag, value)
+		if err != nil || !reflect.DeepEqual(readBack, value) {
+			t.Errorf("%s: expected %v written, got %v (err %v)", tag.Name, value, readBack, err)
+		}
+	}
+
+	// Exceptions make it back through the RTU framing
+	unmapped := model.ModbusTag{Name: "Unmapped", RegisterType: "HoldingRegister", Address: 100, Size: 1, DataType: "INT"}
+	if _, err := client.ReadTag(ctx, unmapped); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("Expected an illegal data address, got %v", err)
+	}
+}
+
+func TestHandlePDU_Quantity(t *testing.T) {
+	sim := NewSimulator()
+	testCases := []struct {
+		fc       byte
+		quantity uint16
+		valid    bool
+	}{
+		{fcReadCoils, 2000, true},
+		{fcReadCoils, 2001, false},
+		{fcReadDiscreteInputs, 0, false},
+		{fcReadHoldingRegisters, 125, true},
+		{fcReadHoldingRegisters, 126, false},
+		{fcReadInputRegisters, 0xffff, false},
+	}
+	for _, tc := range testCases {
+		req := binary.BigEndian.AppendUint16([]byte{0x01, tc.fc, 0x00, 0x00}, tc.quantity)
+		res := sim.handlePDU(req)
+		if !tc.valid {
+			if !bytes.Equal(res, exception(0x01, tc.fc, exIllegalDataValue)) {
+				t.Errorf("Function %d, quantity %d: expected an illegal data value, got % x", tc.fc, tc.quantity, res)
+			}
+			continue
+		}
+		// The byte count matches the data that follows it
+		if len(res) < 3 || int(res[2]) != len(res)-3 {
+			t.Errorf("Function %d, quantity %d: malformed response % x", tc.fc, tc.quantity, res)
+		}
+	}
+
+	// Writes are bounded too
+	req := binary.BigEndian.AppendUint16([]byte{0x01, fcWriteMultipleRegisters, 0x00, 0x00}, 124)
+	req = append(req, 248)
+	req = append(req, make([]byte, 248)...)
+	if res := sim.handlePDU(req); !bytes.Equal(res, exception(0x01, fcWriteMultipleRegisters, exIllegalDataValue)) {
+		t.Errorf("Expected an illegal data value for 124 registers, got % x", res)
+	}
+}
+
+func TestSimulator_RTUOverTCP(t *testing.T) {
+	checkRTU(t, "rtuovertcp://127.0.0.1:0")
+}
+
+func TestSimulator_RTUOnPTY(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("pseudo-terminals are only supported on Linux")
+	}
+	checkRTU(t, "rtu://")
+}
+
+func TestSimulator_RTUDropsBadCRC(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.Start("rtuovertcp://127.0.0.1:0"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer sim.Stop()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(sim.Address(), "rtuovertcp://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	request := appendCRC([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01})
+	garbled := append([]byte(nil), request...)
+	garbled[5] ^= 0xff
+	if _, err := conn.Write(append(garbled, request...)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Only the intact request is answered: unit, function, 2 bytes, 0, CRC
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	response := make([]byte, 7)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		t.Fatalf("Expected a response, got %v", err)
+	}
+	if string(response) != string(appendCRC([]byte{0x01, 0x03, 0x02, 0x00, 0x00})) {
+		t.Errorf("Unexpected response % x", response)
+	}
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _ := conn.Read(make([]byte, 1)); n != 0 {
+		t.Error("Expected no answer to the garbled request")
+	}
+}
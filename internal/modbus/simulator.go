@@ -0,0 +1,397 @@
+package modbus
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"opcmss/internal/model"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Simulator is an in-process Modbus server backed by an in-memory register
+// map. It answers every unit ID over Modbus TCP, RTU over TCP or RTU on a
+// pseudo-terminal, and stands in for a PLC in tests and demos.
+type Simulator struct {
+	// Strict makes reads of addresses that were never set fail with an
+	// illegal data address exception, like a PLC with holes in its map.
+	// Otherwise unset addresses read as zero.
+	Strict bool
+
+	mu               sync.RWMutex
+	coils            map[uint16]bool
+	discreteInputs   map[uint16]bool
+	holdingRegisters map[uint16]uint16
+	inputRegisters   map[uint16]uint16
+	requests         atomic.Int64
+	byteOrder        string // default for tags without their own byte order
+
+	address string       // the endpoint clients connect to
+	stop    func() error // shuts the transport down, nil when not started
+}
+
+// NewSimulator creates a simulator with an empty register map
+func NewSimulator() *Simulator {
+	return &Simulator{
+		coils:            make(map[uint16]bool),
+		discreteInputs:   make(map[uint16]bool),
+		holdingRegisters: make(map[uint16]uint16),
+		inputRegisters:   make(map[uint16]uint16),
+	}
+}
+
+// Start serves the register map at endpoint: host:port or tcp://host:port
+// for Modbus TCP, rtuovertcp://host:port for RTU frames over TCP like a
+// serial gateway, or rtu:// for RTU on a new pseudo-terminal. Port 0 picks a
+// free port. Address returns the endpoint clients connect to.
+func (s *Simulator) Start(endpoint string) error {
+	if s.stop != nil {
+		return fmt.Errorf("simulator already started on %s", s.address)
+	}
+
+	scheme, address, found := strings.Cut(endpoint, "://")
+	if !found {
+		scheme, address = SchemeTCP, endpoint
+	}
+	switch scheme {
+	case SchemeTCP:
+		return s.startTCP(address)
+	case SchemeRTUOverTCP:
+		return s.startRTUOverTCP(address)
+	case SchemeRTU:
+		if address != "" {
+			return fmt.Errorf("the simulator serves RTU on a pseudo-terminal of its own, use rtu:// without %s", address)
+		}
+		return s.startPTY()
+	default:
+		return fmt.Errorf("the simulator serves tcp, rtuovertcp and rtu endpoints, not %s", scheme)
+	}
+}
+
+func (s *Simulator) startTCP(address string) error {
+	address, err := resolvePort(address)
+	if err != nil {
+		return err
+	}
+
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{
+		URL:    "tcp://" + address,
+		Logger: log.New(io.Discard, "", 0),
+	}, s)
+	if err != nil {
+		return err
+	}
+	if err := server.Start(); err != nil {
+		return err
+	}
+
+	s.stop = server.Stop
+	s.address = address
+	return nil
+}
+
+// startRTUOverTCP answers RTU frames on every TCP connection
+func (s *Simulator) startRTUOverTCP(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns[conn] = true
+			mu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serveRTU(conn)
+				conn.Close()
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	s.stop = func() error {
+		err := listener.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+		return err
+	}
+	s.address = SchemeRTUOverTCP + "://" + listener.Addr().String()
+	return nil
+}
+
+// startPTY answers RTU frames on the master end of a new pseudo-terminal
+func (s *Simulator) startPTY() error {
+	p, err := openPTY()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.serveRTU(p.master)
+	}()
+
+	s.stop = func() error {
+		err := p.Close()
+		<-done
+		return err
+	}
+	s.address = SchemeRTU + "://" + p.device
+	return nil
+}
+
+// resolvePort replaces port 0 with a free port, as the server does not expose
+// the address it listens on
+func resolvePort(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if port != "0" {
+		return address, nil
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// Address returns the endpoint clients connect to, a plain host:port for
+// Modbus TCP
+func (s *Simulator) Address() string {
+	return s.address
+}
+
+// Stop shuts the server down
+func (s *Simulator) Stop() error {
+	if s.stop == nil {
+		return nil
+	}
+	err := s.stop()
+	s.stop = nil
+	return err
+}
+
+// Requests returns the number of requests served so far
+func (s *Simulator) Requests() int {
+	return int(s.requests.Load())
+}
+
+// SetByteOrder sets the byte order used to encode tags that do not declare
+// their own, to match the clients' connection setting
+func (s *Simulator) SetByteOrder(order string) error {
+	order, err := model.ParseByteOrder(order)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.byteOrder = order
+	s.mu.Unlock()
+	return nil
+}
+
+// SetValue stores a value at the tag's address, encoded for its data type and
+// byte order. Coils and discrete inputs take a bool, or a []bool for tags
+// spanning several bits.
+func (s *Simulator) SetValue(tag model.ModbusTag, value any) error {
+	if tag.Address == 0 {
+		return fmt.Errorf("tag %s: address 0 is not valid, addresses are 1-based", tag.Name)
+	}
+	start := tag.Address - 1 // Modbus addresses are typically 1-based
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch tag.RegisterType {
+	case "Coil", "DiscreteInput":
+		bits, err := encodeCoils(tag, value)
+		if err != nil {
+			return fmt.Errorf("tag %s: %w", tag.Name, err)
+		}
+		table := s.coils
+		if tag.RegisterType == "DiscreteInput" {
+			table = s.discreteInputs
+		}
+		for i, bit := range bits {
+			table[start+uint16(i)] = bit
+		}
+	case "HoldingRegister", "InputRegister":
+		if tag.ByteOrder == "" {
+			tag.ByteOrder = s.byteOrder
+		}
+		registers, err := encodeRegisters(tag, value)
+		if err != nil {
+			return fmt.Errorf("tag %s: %w", tag.Name, err)
+		}
+		table := s.holdingRegisters
+		if tag.RegisterType == "InputRegister" {
+			table = s.inputRegisters
+		}
+		for i, register := range registers {
+			table[start+uint16(i)] = register
+		}
+	default:
+		return fmt.Errorf("tag %s: unsupported register type: %s", tag.Name, tag.RegisterType)
+	}
+	return nil
+}
+
+// Seed stores the value of every tag found in values, keyed by tag name.
+// Tags without a value are still mapped with their zero value, so a strict
+// simulator answers for every tag in the list.
+func (s *Simulator) Seed(tags []model.ModbusTag, values map[string]any) error {
+	for _, tag := range tags {
+		value, ok := values[tag.Name]
+		if !ok {
+			value = zeroValue(tag)
+		}
+		if value == nil {
+			continue
+		}
+		if err := s.SetValue(tag, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zeroValue returns the zero value SetValue accepts for the tag
+func zeroValue(tag model.ModbusTag) any {
+	switch tag.RegisterType {
+	case "Coil", "DiscreteInput":
+		if tag.Size > 1 {
+			return make([]bool, tag.Size)
+		}
+		return false
+	}
+
+	dataType := tag.ResolvedDataType()
+	switch {
+	case dataType == model.TypeBOOL:
+		return false
+	case model.IsString(dataType):
+		return ""
+	case dataType == "":
+		// Untyped blocks are left unset
+		return nil
+	default:
+		return 0
+	}
+}
+
+// HandleCoils implements modbus.RequestHandler
+func (s *Simulator) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	return s.handleBits(s.coils, req.Addr, req.Quantity, req.IsWrite, req.Args)
+}
+
+// HandleDiscreteInputs implements modbus.RequestHandler
+func (s *Simulator) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	return s.handleBits(s.discreteInputs, req.Addr, req.Quantity, false, nil)
+}
+
+// HandleHoldingRegisters implements modbus.RequestHandler
+func (s *Simulator) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	return s.handleRegisters(s.holdingRegisters, req.Addr, req.Quantity, req.IsWrite, req.Args)
+}
+
+// HandleInputRegisters implements modbus.RequestHandler
+func (s *Simulator) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	return s.handleRegisters(s.inputRegisters, req.Addr, req.Quantity, false, nil)
+}
+
+func (s *Simulator) handleBits(table map[uint16]bool, addr, quantity uint16, write bool, args []bool) ([]bool, error) {
+	s.requests.Add(1)
+	if write {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, v := range args {
+			table[addr+uint16(i)] = v
+		}
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]bool, quantity)
+	for i := range res {
+		v, ok := table[addr+uint16(i)]
+		if !ok && s.Strict {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (s *Simulator) handleRegisters(table map[uint16]uint16, addr, quantity uint16, write bool, args []uint16) ([]uint16, error) {
+	s.requests.Add(1)
+	if write {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, v := range args {
+			table[addr+uint16(i)] = v
+		}
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]uint16, quantity)
+	for i := range res {
+		v, ok := table[addr+uint16(i)]
+		if !ok && s.Strict {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// String describes the size of the register map, for logging
+func (s *Simulator) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var parts []string
+	for _, t := range []struct {
+		name  string
+		count int
+	}{
+		{"coils", len(s.coils)},
+		{"discrete inputs", len(s.discreteInputs)},
+		{"holding registers", len(s.holdingRegisters)},
+		{"input registers", len(s.inputRegisters)},
+	} {
+		parts = append(parts, fmt.Sprintf("%d %s", t.count, t.name))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,234 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+// startSimulator starts a seeded simulator on a free local port
+func startSimulator(t *testing.T, tags []model.ModbusTag, values map[string]any) *Simulator {
+	t.Helper()
+
+	sim := NewSimulator()
+	if err := sim.Seed(tags, values); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { sim.Stop() })
+	return sim
+}
+
+func TestSimulator_ReadTag(t *testing.T) {
+	tags := []model.ModbusTag{
+		{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1, DataType: "BOOL"},
+		{Name: "Alarm", RegisterType: "DiscreteInput", Address: 3, Size: 1, DataType: "BOOL"},
+		{Name: "Setpoint", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"},
+		{Name: "Flow", RegisterType: "HoldingRegister", Address: 2, Size: 2, DataType: "REAL", ByteOrder: "CDAB"},
+		{Name: "Label", RegisterType: "HoldingRegister", Address: 10, Size: 3, DataType: "STRING[6]"},
+		{Name: "Counter", RegisterType: "InputRegister", Address: 5, Size: 2, DataType: "UDINT"},
+	}
+	values := map[string]any{
+		"Pump":     true,
+		"Alarm":    true,
+		"Setpoint": int16(-42),
+		"Flow":     float32(12.5),
+		"Label":    "PUMP-1",
+		"Counter":  uint32(123456),
+	}
+	sim := startSimulator(t, tags, values)
+
+	client, err := NewClient(sim.Address())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for _, tag := range tags {
+		value, err := client.ReadTag(context.Background(), tag)
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %v", tag.Name, err)
+			continue
+		}
+		if value != values[tag.Name] {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", tag.Name, values[tag.Name], values[tag.Name], value, value)
+		}
+	}
+}
+
+func TestSimulator_UnseededTagsReadZero(t *testing.T) {
+	tags := []model.ModbusTag{
+		{Name: "Level", RegisterType: "HoldingRegister", Address: 7, Size: 2, DataType: "REAL"},
+	}
+	sim := startSimulator(t, tags, nil)
+
+	client, err := NewClient(sim.Address())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	value, err := client.ReadTag(context.Background(), tags[0])
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if value != float32(0) {
+		t.Errorf("Expected 0, got %v (%T)", value, value)
+	}
+}
+
+func TestSimulator_StrictBlockReadFallsBack(t *testing.T) {
+	tags := []model.ModbusTag{
+		newTag("A", "HoldingRegister", 1, 1),
+		newTag("B", "HoldingRegister", 3, 1),
+	}
+	sim := NewSimulator()
+	sim.Strict = true
+	if err := sim.Seed(tags, map[string]any{"A": int16(1), "B": int16(2)}); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer sim.Stop()
+
+	client, err := NewClient(sim.Address())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	// The hole at address 2 makes the block read fail, so each tag is read on its own
+	values := client.ReadTags(context.Background(), tags, BatchOptions{MaxGap: 1})
+	for i, expected := range []int16{1, 2} {
+		if values[i].Err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tags[i].Name, values[i].Err)
+		}
+		if values[i].Value != expected {
+			t.Errorf("%s: expected %d, got %v", tags[i].Name, expected, values[i].Value)
+		}
+	}
+	if sim.Requests() != 3 {
+		t.Errorf("Expected 3 requests (one block, two single reads), got %d", sim.Requests())
+	}
+}
+
+func TestSimulator_SetValueErrors(t *testing.T) {
+	sim := NewSimulator()
+
+	tag := model.ModbusTag{Name: "Zero", RegisterType: "HoldingRegister", Address: 0, Size: 1, DataType: "INT"}
+	if err := sim.SetValue(tag, int16(1)); err == nil {
+		t.Error("Expected an error for address 0")
+	}
+
+	tag = model.ModbusTag{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1, DataType: "BOOL"}
+	if err := sim.SetValue(tag, int16(1)); err == nil {
+		t.Error("Expected an error for a non-bool coil value")
+	}
+}
+
+func TestSimulator_WriteTag(t *testing.T) {
+	tags := []model.ModbusTag{
+		{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1, DataType: "BOOL"},
+		{Name: "Flow", RegisterType: "HoldingRegister", Address: 2, Size: 2, DataType: "REAL", ByteOrder: "CDAB"},
+		{Name: "Total", RegisterType: "HoldingRegister", Address: 4, Size: 4, DataType: "LINT"},
+		{Name: "Setpoint", RegisterType: "HoldingRegister", Address: 8, Size: 1, DataType: "UINT"},
+	}
+	sim := startSimulator(t, tags, nil)
+
+	client, err := NewClient(sim.Address())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	values := []any{true, float32(-3.25), int64(-1) << 40, uint16(65535)}
+	for i, tag := range tags {
+		readBack, err := client.WriteTagVerified(context.Background(), tag, values[i])
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %v", tag.Name, err)
+			continue
+		}
+		if readBack != values[i] {
+			t.Errorf("%s: expected %v, got %v", tag.Name, values[i], readBack)
+		}
+	}
+}
+
+func TestSimulator_ByteOrder(t *testing.T) {
+	// Tags without their own byte order follow the connection setting on
+	// both ends
+	tags := []model.ModbusTag{
+		{Name: "Flow", RegisterType: "HoldingRegister", Address: 1, Size: 2, DataType: "REAL"},
+		{Name: "Counter", RegisterType: "InputRegister", Address: 1, Size: 2, DataType: "UDINT"},
+		{Name: "Total", RegisterType: "HoldingRegister", Address: 3, Size: 4, DataType: "LINT"},
+	}
+	values := map[string]any{"Flow": float32(12.5), "Counter": uint32(0x00010002), "Total": int64(-1) << 40}
+
+	sim := NewSimulator()
+	if err := sim.SetByteOrder("cdab"); err != nil {
+		t.Fatalf("SetByteOrder failed: %v", err)
+	}
+	if err := sim.SetByteOrder("ACBD"); err == nil {
+		t.Error("Expected an error for an unsupported byte order")
+	}
+	if err := sim.Seed(tags, values); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer sim.Stop()
+
+	client, err := NewClient(sim.Address())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	// The words are stored swapped
+	registers, err := client.ReadRawRegisters(ctx, tags[1])
+	if err != nil || len(registers) != 2 || registers[0] != 0x0002 || registers[1] != 0x0001 {
+		t.Errorf("Expected registers [2 1], got %v (err %v)", registers, err)
+	}
+
+	if err := client.SetByteOrder("CDAB"); err != nil {
+		t.Fatalf("SetByteOrder failed: %v", err)
+	}
+	for i, result := range client.ReadTags(ctx, tags, DefaultBatchOptions()) {
+		if result.Err != nil || result.Value != values[tags[i].Name] {
+			t.Errorf("%s: expected %v, got %v (err %v)", tags[i].Name, values[tags[i].Name], result.Value, result.Err)
+		}
+	}
+	if readBack, err := client.WriteTagVerified(ctx, tags[0], float32(-3.25)); err != nil || readBack != float32(-3.25) {
+		t.Errorf("Flow: expected -3.25 written, got %v (err %v)", readBack, err)
+	}
+}
+
+func TestSimulator_Connections(t *testing.T) {
+	var tags []model.ModbusTag
+	values := map[string]any{}
+	for i := range 20 {
+		tag := model.ModbusTag{Name: fmt.Sprintf("HR%d", i), RegisterType: "HoldingRegister", Address: uint16(i*4 + 1), Size: 1, DataType: "INT"}
+		tags = append(tags, tag)
+		values[tag.Name] = int16(i)
+	}
+	sim := startSimulator(t, tags, values)
+
+	client, err := NewClient(sim.Address(), WithConnections(3))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i, result := range client.ReadTags(context.Background(), tags, DefaultBatchOptions()) {
+		if result.Err != nil || result.Value != int16(i) {
+			t.Errorf("%s: expected %d, got %v (err %v)", tags[i].Name, i, result.Value, result.Err)
+		}
+	}
+}
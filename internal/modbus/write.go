@@ -0,0 +1,88 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"opcmss/internal/model"
+)
+
+// ErrVerifyFailed is returned when a written tag reads back something else
+var ErrVerifyFailed = errors.New("read-back does not match the written value")
+
+// WriteTag writes a value to a coil or holding register tag, encoded for
+// the tag's data type and byte order. Single coils and registers are written
+// with function codes 05 and 06, anything larger with 15 and 16.
+func (c *Client) WriteTag(ctx context.Context, tag model.ModbusTag, value any) error {
+	switch tag.RegisterType {
+	case "Coil":
+		coils, err := encodeCoils(tag, value)
+		if err != nil {
+			return err
+		}
+		return c.writeCoils(ctx, tag, coils)
+	case "HoldingRegister":
+		registers, err := encodeRegisters(c.withByteOrder(tag), value)
+		if err != nil {
+			return err
+		}
+		return c.writeRegisters(ctx, tag, registers)
+	case "DiscreteInput", "InputRegister":
+		return fmt.Errorf("%s tags are read-only", tag.RegisterType)
+	default:
+		return fmt.Errorf("unsupported register type: %s", tag.RegisterType)
+	}
+}
+
+// WriteTagVerified writes a value like WriteTag, then reads the tag back and
+// returns the decoded value. The raw coils or registers must be identical to
+// what was written, otherwise the error wraps ErrVerifyFailed.
+func (c *Client) WriteTagVerified(ctx context.Context, tag model.ModbusTag, value any) (any, error) {
+	if err := c.WriteTag(ctx, tag, value); err != nil {
+		return nil, err
+	}
+
+	switch tag.RegisterType {
+	case "Coil":
+		written, _ := encodeCoils(tag, value)
+		data, err := c.readBits(ctx, tag.RegisterType, tag.Address-1, tag.Size, MaxReadCoils)
+		if err != nil {
+			return nil, fmt.Errorf("read-back failed: %w", err)
+		}
+		readBack, _ := decodeCoils(tag, data)
+		if !slices.Equal(written, data) {
+			return readBack, fmt.Errorf("%w: wrote %v, read %v", ErrVerifyFailed, value, readBack)
+		}
+		return readBack, nil
+	default:
+		written, _ := encodeRegisters(c.withByteOrder(tag), value)
+		data, err := c.ReadRawRegisters(ctx, tag)
+		if err != nil {
+			return nil, fmt.Errorf("read-back failed: %w", err)
+		}
+		readBack, err := decodeRegisters(c.withByteOrder(tag), data)
+		if err != nil {
+			return nil, fmt.Errorf("read-back failed: %w", err)
+		}
+		if !slices.Equal(written, data) {
+			return readBack, fmt.Errorf("%w: wrote %04X, read %04X", ErrVerifyFailed, written, data)
+		}
+		return readBack, nil
+	}
+}
+
+func (c *Client) writeCoils(ctx context.Context, tag model.ModbusTag, coils []bool) error {
+	if len(coils) == 1 {
+		return c.client.WriteCoil(ctx, tag.Address-1, coils[0])
+	}
+	return c.client.WriteCoils(ctx, tag.Address-1, coils)
+}
+
+func (c *Client) writeRegisters(ctx context.Context, tag model.ModbusTag, registers []uint16) error {
+	if len(registers) == 1 {
+		return c.client.WriteRegister(ctx, tag.Address-1, registers[0])
+	}
+	return c.client.WriteRegisters(ctx, tag.Address-1, registers)
+}
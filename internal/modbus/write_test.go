@@ -0,0 +1,100 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func TestWriteTag_FunctionCodes(t *testing.T) {
+	mem := &memoryModbusClient{}
+	client := NewClientWithModbus(mem)
+	client.SetByteOrder("CDAB")
+
+	writes := []struct {
+		tag   model.ModbusTag
+		value any
+	}{
+		{model.ModbusTag{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1}, true},
+		{model.ModbusTag{Name: "Valves", RegisterType: "Coil", Address: 5, Size: 3}, []bool{true, false, true}},
+		{model.ModbusTag{Name: "Setpoint", RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"}, int16(-2)},
+		{model.ModbusTag{Name: "Flow", RegisterType: "HoldingRegister", Address: 2, Size: 2, DataType: "REAL"}, float32(1.5)},
+	}
+	for _, w := range writes {
+		if err := client.WriteTag(context.Background(), w.tag, w.value); err != nil {
+			t.Fatalf("%s: expected no error, got: %v", w.tag.Name, err)
+		}
+	}
+
+	expected := []string{"FC05@0", "FC15@4", "FC06@0", "FC16@1"}
+	if !reflect.DeepEqual(mem.writes, expected) {
+		t.Errorf("Expected writes %v, got %v", expected, mem.writes)
+	}
+	if !mem.coils[0] || !mem.coils[4] || mem.coils[5] || !mem.coils[6] {
+		t.Errorf("Unexpected coils after write: %v", mem.coils)
+	}
+	// 1.5 is 0x3FC00000, swapped to CDAB by the connection's byte order
+	if mem.registers[0] != 0xFFFE || mem.registers[1] != 0x0000 || mem.registers[2] != 0x3FC0 {
+		t.Errorf("Unexpected registers after write: %04X", mem.registers)
+	}
+
+	for _, w := range writes {
+		value, err := client.ReadTag(context.Background(), w.tag)
+		if err != nil || !reflect.DeepEqual(value, w.value) {
+			t.Errorf("%s: expected to read back %v, got %v (%v)", w.tag.Name, w.value, value, err)
+		}
+	}
+}
+
+func TestWriteTag_Errors(t *testing.T) {
+	client := NewClientWithModbus(&memoryModbusClient{holes: map[uint16]bool{9: true}})
+
+	testCases := []struct {
+		name  string
+		tag   model.ModbusTag
+		value any
+	}{
+		{"input register", model.ModbusTag{RegisterType: "InputRegister", Address: 1, Size: 1, DataType: "INT"}, int16(1)},
+		{"discrete input", model.ModbusTag{RegisterType: "DiscreteInput", Address: 1, Size: 1}, true},
+		{"out of range", model.ModbusTag{RegisterType: "HoldingRegister", Address: 1, Size: 1, DataType: "INT"}, int32(70000)},
+		{"wrong type", model.ModbusTag{RegisterType: "Coil", Address: 1, Size: 1}, int16(1)},
+		{"device error", model.ModbusTag{RegisterType: "HoldingRegister", Address: 10, Size: 1, DataType: "INT"}, int16(1)},
+	}
+	for _, tc := range testCases {
+		if err := client.WriteTag(context.Background(), tc.tag, tc.value); err == nil {
+			t.Errorf("%s: expected an error, got none", tc.name)
+		}
+	}
+}
+
+func TestWriteTagVerified(t *testing.T) {
+	tag := model.ModbusTag{Name: "Total", RegisterType: "HoldingRegister", Address: 3, Size: 2, DataType: "DINT"}
+
+	client := NewClientWithModbus(&memoryModbusClient{})
+	value, err := client.WriteTagVerified(context.Background(), tag, int32(-100000))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if value != int32(-100000) {
+		t.Errorf("Expected read-back -100000, got %v", value)
+	}
+
+	// A device that acknowledges the write but keeps its old value
+	client = NewClientWithModbus(&memoryModbusClient{ignore: true, registers: map[uint16]uint16{2: 0, 3: 7}})
+	value, err = client.WriteTagVerified(context.Background(), tag, int32(-100000))
+	if !errors.Is(err, ErrVerifyFailed) {
+		t.Fatalf("Expected ErrVerifyFailed, got: %v", err)
+	}
+	if value != int32(7) {
+		t.Errorf("Expected the stale value 7 alongside the error, got %v", value)
+	}
+
+	coil := model.ModbusTag{Name: "Pump", RegisterType: "Coil", Address: 1, Size: 1}
+	client = NewClientWithModbus(&memoryModbusClient{ignore: true})
+	if _, err := client.WriteTagVerified(context.Background(), coil, true); !errors.Is(err, ErrVerifyFailed) {
+		t.Errorf("Expected ErrVerifyFailed for a coil, got: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Byte orders of multi-register values, named by where the bytes of the big-endian
+// value ABCD end up in the registers
+const (
+	ByteOrderABCD = "ABCD" // big-endian, high word first
+	ByteOrderCDAB = "CDAB" // big-endian words, low word first
+	ByteOrderBADC = "BADC" // bytes swapped within each word, high word first
+	ByteOrderDCBA = "DCBA" // little-endian
+)
+
+// ByteOrders lists every supported byte order
+var ByteOrders = []string{ByteOrderABCD, ByteOrderCDAB, ByteOrderBADC, ByteOrderDCBA}
+
+// ParseByteOrder normalises a byte order name and checks that it is supported
+func ParseByteOrder(s string) (string, error) {
+	order := strings.ToUpper(strings.TrimSpace(s))
+	for _, known := range ByteOrders {
+		if order == known {
+			return order, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported byte order %q (expected one of %v)", s, ByteOrders)
+}
+
+// HasByteOrder reports whether values of the data type follow a byte order:
+// only the 32- and 64-bit numbers do. 16-bit values and strings are always
+// read as stored.
+func HasByteOrder(dataType string) bool {
+	switch dataType {
+	case TypeDINT, TypeUDINT, TypeDWORD, TypeREAL, TypeLINT, TypeLREAL:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IEC 61131-3 data types supported in the tag file
+const (
+	TypeBOOL   = "BOOL"
+	TypeINT    = "INT"
+	TypeUINT   = "UINT"
+	TypeWORD   = "WORD"
+	TypeDINT   = "DINT"
+	TypeUDINT  = "UDINT"
+	TypeDWORD  = "DWORD"
+	TypeREAL   = "REAL"
+	TypeLINT   = "LINT"
+	TypeLREAL  = "LREAL"
+	TypeSTRING = "STRING"
+)
+
+// registerCounts is the number of 16-bit registers used by each fixed size type
+var registerCounts = map[string]uint16{
+	TypeBOOL:  1,
+	TypeINT:   1,
+	TypeUINT:  1,
+	TypeWORD:  1,
+	TypeDINT:  2,
+	TypeUDINT: 2,
+	TypeDWORD: 2,
+	TypeREAL:  2,
+	TypeLINT:  4,
+	TypeLREAL: 4,
+}
+
+// ParseDataType normalises a data type name and checks that it is supported.
+// Strings are written with their length, e.g. STRING[20].
+func ParseDataType(s string) (string, error) {
+	dt := strings.ToUpper(strings.TrimSpace(s))
+	if _, ok := registerCounts[dt]; ok {
+		return dt, nil
+	}
+	if _, err := StringLength(dt); err != nil {
+		return "", err
+	}
+	return dt, nil
+}
+
+// StringLength returns n for a STRING[n] data type
+func StringLength(dataType string) (int, error) {
+	inner, ok := strings.CutPrefix(dataType, TypeSTRING+"[")
+	if !ok || !strings.HasSuffix(inner, "]") {
+		return 0, fmt.Errorf("unsupported data type %q", dataType)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(inner, "]"))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid string length in %q", dataType)
+	}
+	return n, nil
+}
+
+// IsString reports whether the data type is a STRING[n]
+func IsString(dataType string) bool {
+	return strings.HasPrefix(dataType, TypeSTRING+"[")
+}
+
+// RegisterCount returns how many 16-bit registers hold a value of the data type
+func RegisterCount(dataType string) (uint16, error) {
+	if n, ok := registerCounts[dataType]; ok {
+		return n, nil
+	}
+	length, err := StringLength(dataType)
+	if err != nil {
+		return 0, err
+	}
+	return uint16((length + 1) / 2), nil
+}
+
+// DefaultDataType derives the data type of a tag without an explicit one from
+// its register type and size. It returns "" when there is no sensible default.
+func DefaultDataType(registerType string, size uint16) string {
+	switch registerType {
+	case "Coil", "DiscreteInput":
+		return TypeBOOL
+	case "HoldingRegister", "InputRegister":
+		switch size {
+		case 1:
+			return TypeINT // 16-bit integer
+		case 2:
+			return TypeREAL // 32-bit float (2 registers)
+		}
+	}
+	return ""
+}
+
+// ResolvedDataType returns the tag's explicit data type, or the default for its
+// register type and size
+func (t ModbusTag) ResolvedDataType() string {
+	if t.DataType != "" {
+		return t.DataType
+	}
+	return DefaultDataType(t.RegisterType, t.Size)
+}
@@ -2,11 +2,14 @@ package model
 
 type ModbusTag struct {
 	Name          string `json:"name"`
-	RegisterType  string `json:"register_type"`  // "Coil" or "HoldingRegister"
+	RegisterType  string `json:"register_type"`  // "Coil", "DiscreteInput", "HoldingRegister" or "InputRegister"
 	Address       uint16 `json:"address"`        // The modbus address
 	ModbusAddress uint32 `json:"modbus_address"` // The full modbus address (e.g., 400002)
 	Size          uint16 `json:"size"`           // Number of registers/coils
 	Range         string `json:"range"`          // Range like "2..2" or "2210..2211"
+	DataType      string `json:"data_type"`      // IEC type like "REAL" or "STRING[20]"
+	ByteOrder     string `json:"byte_order"`     // "ABCD", "CDAB", "BADC" or "DCBA", empty for the connection default
+	Tolerance     string `json:"tolerance"`      // like "abs:0.01", empty for the configured rules
 }
 
 type OPCTag struct {
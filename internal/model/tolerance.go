@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kinds of tolerance allowed between an OPC UA and a Modbus value
+const (
+	ToleranceExact    = "exact" // values must be identical
+	ToleranceAbsolute = "abs"   // abs:0.01, the values may differ by up to 0.01
+	ToleranceRelative = "rel"   // rel:0.5%, the values may differ by 0.5% of the larger one
+	ToleranceULP      = "ulp"   // ulp:4, floats may be up to 4 representable values apart
+)
+
+// DefaultTolerance absorbs the rounding of REAL values
+var DefaultTolerance = Tolerance{Kind: ToleranceAbsolute, Value: 0.001}
+
+// Tolerance is how far apart two numeric values may be and still match.
+// Booleans and strings always have to be identical.
+type Tolerance struct {
+	Kind  string
+	Value float64
+}
+
+// ParseTolerance reads a tolerance written as exact, abs:<n>, rel:<n>% or ulp:<n>
+func ParseTolerance(s string) (Tolerance, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	if text == ToleranceExact {
+		return Tolerance{Kind: ToleranceExact}, nil
+	}
+
+	kind, amount, ok := strings.Cut(text, ":")
+	if !ok {
+		return Tolerance{}, fmt.Errorf("invalid tolerance %q (expected exact, abs:<n>, rel:<n>%% or ulp:<n>)", s)
+	}
+
+	switch kind {
+	case ToleranceAbsolute:
+	case ToleranceRelative:
+		amount = strings.TrimSuffix(amount, "%")
+	case ToleranceULP:
+		n, err := strconv.ParseUint(amount, 10, 32)
+		if err != nil {
+			return Tolerance{}, fmt.Errorf("invalid tolerance %q: ULPs must be a whole number", s)
+		}
+		return Tolerance{Kind: ToleranceULP, Value: float64(n)}, nil
+	default:
+		return Tolerance{}, fmt.Errorf("unknown tolerance kind %q in %q", kind, s)
+	}
+
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Tolerance{}, fmt.Errorf("invalid tolerance %q: expected a finite non-negative number", s)
+	}
+	return Tolerance{Kind: kind, Value: value}, nil
+}
+
+// String formats the tolerance the way ParseTolerance reads it
+func (t Tolerance) String() string {
+	value := strconv.FormatFloat(t.Value, 'g', -1, 64)
+	switch t.Kind {
+	case ToleranceExact:
+		return ToleranceExact
+	case ToleranceRelative:
+		return ToleranceRelative + ":" + value + "%"
+	case "":
+		return ""
+	default:
+		return t.Kind + ":" + value
+	}
+}
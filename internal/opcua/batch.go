@@ -0,0 +1,142 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"opcmss/internal/model"
+	"opcmss/internal/throttle"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// DefaultMaxNodesPerRead bounds a ReadRequest when the server reports no limit
+const DefaultMaxNodesPerRead = 1000
+
+// TagValue is the outcome of reading one node as part of a batch
+type TagValue struct {
+	Value           any
+	StatusCode      ua.StatusCode
+	Err             error
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+	ReadAt          time.Time
+	Latency         time.Duration
+}
+
+// MaxNodesPerRead returns the server's MaxNodesPerRead operational limit, or
+// DefaultMaxNodesPerRead when the server does not set one. The value is
+// discovered once and cached; a failed read returns the default without
+// caching it, so the next call asks again. Concurrent first calls may each
+// ask the server, the first answer is kept.
+func (c *Client) MaxNodesPerRead(ctx context.Context) uint32 {
+	c.limitMu.Lock()
+	limit := c.maxNodesPerRead
+	c.limitMu.Unlock()
+	if limit != 0 {
+		return limit
+	}
+
+	req := &ua.ReadRequest{
+		NodesToRead: []ua.ReadValueID{
+			{
+				NodeID:      ua.VariableIDServerServerCapabilitiesOperationLimitsMaxNodesPerRead,
+				AttributeID: ua.AttributeIDValue,
+			},
+		},
+	}
+	ctx, cancel := c.request(ctx)
+	defer cancel()
+	res, err := c.client.Read(ctx, req)
+	if err != nil {
+		return DefaultMaxNodesPerRead
+	}
+
+	limit = DefaultMaxNodesPerRead
+	if len(res.Results) > 0 && res.Results[0].StatusCode.IsGood() {
+		if value, ok := res.Results[0].Value.(uint32); ok && value > 0 {
+			limit = value
+		}
+	}
+
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+	if c.maxNodesPerRead == 0 {
+		c.maxNodesPerRead = limit
+	}
+	return c.maxNodesPerRead
+}
+
+// ReadTags reads many nodes, packing as many into each ReadRequest as the
+// server allows and sending up to the client's concurrency of them at once.
+// The results are in the same order as tags. Once ctx is done, the requests
+// not sent yet fail with its error.
+func (c *Client) ReadTags(ctx context.Context, tags []model.OPCTag) []TagValue {
+	results := make([]TagValue, len(tags))
+	chunk := int(c.MaxNodesPerRead(ctx))
+
+	chunks := (len(tags) + chunk - 1) / chunk
+	throttle.Each(chunks, c.concurrency, func(i int) {
+		start := i * chunk
+		end := min(start+chunk, len(tags))
+		c.readChunk(ctx, tags[start:end], results[start:end])
+	})
+	return results
+}
+
+func (c *Client) readChunk(ctx context.Context, tags []model.OPCTag, results []TagValue) {
+	nodes := make([]ua.ReadValueID, len(tags))
+	for i, tag := range tags {
+		nodes[i] = ua.ReadValueID{
+			NodeID:      ua.ParseNodeID(tag.NodeID),
+			AttributeID: ua.AttributeIDValue,
+		}
+	}
+
+	req := &ua.ReadRequest{
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+		NodesToRead:        nodes,
+	}
+
+	ctx, cancel := c.request(ctx)
+	defer cancel()
+
+	readAt := time.Now()
+	res, err := c.client.Read(ctx, req)
+	err = requestError(ctx, err)
+	latency := time.Since(readAt)
+
+	if err == nil && len(res.Results) != len(tags) {
+		err = fmt.Errorf("expected %d results, got %d", len(tags), len(res.Results))
+	}
+	if err != nil {
+		for i := range results {
+			results[i] = TagValue{Err: fmt.Errorf("read error: %w", err), ReadAt: readAt, Latency: latency}
+		}
+		return
+	}
+
+	for i, dv := range res.Results {
+		result := TagValue{
+			StatusCode:      dv.StatusCode,
+			SourceTimestamp: dv.SourceTimestamp,
+			ServerTimestamp: dv.ServerTimestamp,
+			ReadAt:          readAt,
+			Latency:         latency,
+		}
+		if !dv.StatusCode.IsGood() {
+			result.Err = fmt.Errorf("read failed with status: %v", dv.StatusCode)
+		} else {
+			result.Value, result.Err = convertValue(tags[i], dv.Value)
+		}
+		results[i] = result
+	}
+}
+
+// ReadTagValue reads a single tag along with the server's timestamps
+func (c *Client) ReadTagValue(ctx context.Context, tag model.OPCTag) TagValue {
+	results := make([]TagValue, 1)
+	c.readChunk(ctx, []model.OPCTag{tag}, results)
+	return results[0]
+}
@@ -0,0 +1,302 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+
+	"opcmss/internal/model"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// Browser is implemented by OPC clients that can browse the address space
+type Browser interface {
+	Browse(ctx context.Context, request *ua.BrowseRequest) (*ua.BrowseResponse, error)
+	BrowseNext(ctx context.Context, request *ua.BrowseNextRequest) (*ua.BrowseNextResponse, error)
+}
+
+// DefaultBrowseRoot is where BrowseVariables starts without a root: the
+// Objects folder
+const DefaultBrowseRoot = "i=85"
+
+// Variable is a variable node found while browsing the address space
+type Variable struct {
+	NodeID      string `json:"node_id"`
+	BrowseName  string `json:"browse_name"`
+	Path        string `json:"path"`      // browse names from the root, separated by "/"
+	DataType    string `json:"data_type"` // OPC UA type like "Float", or the type's NodeID
+	IECType     string `json:"iec_type"`  // matching IEC type like "REAL", empty if there is none
+	AccessLevel byte   `json:"access_level"`
+	Description string `json:"description"`
+}
+
+// Readable reports whether the variable's current value can be read
+func (v Variable) Readable() bool {
+	return v.AccessLevel&ua.AccessLevelsCurrentRead != 0
+}
+
+// Writable reports whether the variable's current value can be written
+func (v Variable) Writable() bool {
+	return v.AccessLevel&ua.AccessLevelsCurrentWrite != 0
+}
+
+// Access describes the access level as "RW", "R", "W" or "-"
+func (v Variable) Access() string {
+	var access string
+	if v.Readable() {
+		access += "R"
+	}
+	if v.Writable() {
+		access += "W"
+	}
+	if access == "" {
+		return "-"
+	}
+	return access
+}
+
+// Tag returns the variable as a tag, with the name given
+func (v Variable) Tag(name string) model.OPCTag {
+	return model.OPCTag{Name: name, NodeID: v.NodeID, DataType: v.IECType, Description: v.Description}
+}
+
+// BrowseOptions selects the part of the address space to walk
+type BrowseOptions struct {
+	Root     string // NodeID to start from, DefaultBrowseRoot when empty
+	MaxDepth int    // references to follow from the root, 1 lists only its own variables, 0 for no limit
+	Standard bool   // also walk the nodes of namespace 0, like the Server object
+}
+
+// BrowseVariables walks the address space from the root along hierarchical
+// references and returns every variable below it, breadth first, along with
+// its data type, access level and description. Variables are not descended
+// into, so the properties of a variable are not listed. The server's own
+// nodes in namespace 0 are skipped unless opts.Standard is set.
+func (c *Client) BrowseVariables(ctx context.Context, opts BrowseOptions) ([]Variable, error) {
+	browser, ok := c.client.(Browser)
+	if !ok {
+		return nil, fmt.Errorf("OPC client does not support browsing")
+	}
+
+	root := opts.Root
+	if root == "" {
+		root = DefaultBrowseRoot
+	}
+	rootID := ua.ParseNodeID(root)
+	if rootID == nil {
+		return nil, fmt.Errorf("invalid root NodeID %q", root)
+	}
+
+	type folder struct {
+		id    ua.NodeID
+		path  string
+		depth int
+	}
+
+	var variables []Variable
+	visited := map[string]bool{fmt.Sprint(rootID): true}
+	queue := []folder{{id: rootID}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		refs, err := c.browseChildren(ctx, browser, current.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to browse %s: %w", current.id, err)
+		}
+
+		for _, ref := range refs {
+			if ref.NodeID.ServerIndex != 0 || ref.NodeID.NodeID == nil {
+				continue // on another server
+			}
+			id := ref.NodeID.NodeID
+			if namespaceIndex(id) == 0 && !opts.Standard {
+				continue
+			}
+			key := fmt.Sprint(id)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+
+			path := ref.BrowseName.Name
+			if current.path != "" {
+				path = current.path + "/" + path
+			}
+			switch ref.NodeClass {
+			case ua.NodeClassVariable:
+				variables = append(variables, Variable{NodeID: key, BrowseName: ref.BrowseName.Name, Path: path})
+			case ua.NodeClassObject:
+				if opts.MaxDepth == 0 || current.depth+1 < opts.MaxDepth {
+					queue = append(queue, folder{id: id, path: path, depth: current.depth + 1})
+				}
+			}
+		}
+	}
+
+	if err := c.readVariableAttributes(ctx, variables); err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+// namespaceIndex returns the namespace of a NodeID
+func namespaceIndex(id ua.NodeID) uint16 {
+	switch id := id.(type) {
+	case ua.NodeIDNumeric:
+		return id.NamespaceIndex
+	case ua.NodeIDString:
+		return id.NamespaceIndex
+	case ua.NodeIDGUID:
+		return id.NamespaceIndex
+	case ua.NodeIDOpaque:
+		return id.NamespaceIndex
+	}
+	return 0
+}
+
+// browseChildren returns the objects and variables a node references
+// hierarchically, following continuation points until all are returned
+func (c *Client) browseChildren(ctx context.Context, browser Browser, id ua.NodeID) ([]ua.ReferenceDescription, error) {
+	ctx, cancel := c.request(ctx)
+	defer cancel()
+
+	req := &ua.BrowseRequest{
+		NodesToBrowse: []ua.BrowseDescription{
+			{
+				NodeID:          id,
+				BrowseDirection: ua.BrowseDirectionForward,
+				ReferenceTypeID: ua.ReferenceTypeIDHierarchicalReferences,
+				IncludeSubtypes: true,
+				NodeClassMask:   uint32(ua.NodeClassObject | ua.NodeClassVariable),
+				ResultMask:      uint32(ua.BrowseResultMaskAll),
+			},
+		},
+	}
+	res, err := browser.Browse(ctx, req)
+	if err = requestError(ctx, err); err != nil {
+		return nil, err
+	}
+	if len(res.Results) == 0 {
+		return nil, fmt.Errorf("no results returned")
+	}
+
+	result := res.Results[0]
+	refs := result.References
+	for {
+		if !result.StatusCode.IsGood() {
+			return nil, fmt.Errorf("browse failed with status: %v", result.StatusCode)
+		}
+		if len(result.ContinuationPoint) == 0 {
+			return refs, nil
+		}
+
+		next, err := browser.BrowseNext(ctx, &ua.BrowseNextRequest{
+			ContinuationPoints: []ua.ByteString{result.ContinuationPoint},
+		})
+		if err = requestError(ctx, err); err != nil {
+			return nil, err
+		}
+		if len(next.Results) == 0 {
+			return nil, fmt.Errorf("no results returned")
+		}
+		result = next.Results[0]
+		refs = append(refs, result.References...)
+	}
+}
+
+// variableAttributes are read for every variable found, in this order
+var variableAttributes = []uint32{ua.AttributeIDDataType, ua.AttributeIDAccessLevel, ua.AttributeIDDescription}
+
+// readVariableAttributes fills in the data type, access level and description
+// of the variables, reading as many attributes per request as the server allows
+func (c *Client) readVariableAttributes(ctx context.Context, variables []Variable) error {
+	perVariable := len(variableAttributes)
+	chunk := max(int(c.MaxNodesPerRead(ctx))/perVariable, 1)
+
+	for start := 0; start < len(variables); start += chunk {
+		end := min(start+chunk, len(variables))
+
+		nodes := make([]ua.ReadValueID, 0, (end-start)*perVariable)
+		for _, v := range variables[start:end] {
+			id := ua.ParseNodeID(v.NodeID)
+			for _, attribute := range variableAttributes {
+				nodes = append(nodes, ua.ReadValueID{NodeID: id, AttributeID: attribute})
+			}
+		}
+
+		res, err := c.readAttributes(ctx, nodes)
+		if err != nil {
+			return fmt.Errorf("failed to read variable attributes: %w", err)
+		}
+		if len(res.Results) != len(nodes) {
+			return fmt.Errorf("expected %d results, got %d", len(nodes), len(res.Results))
+		}
+
+		for i := range variables[start:end] {
+			v := &variables[start+i]
+			results := res.Results[i*perVariable : (i+1)*perVariable]
+			if id, ok := results[0].Value.(ua.NodeID); ok && results[0].StatusCode.IsGood() {
+				v.DataType, v.IECType = DataTypeName(id), iecTypes[id]
+			}
+			if level, ok := results[1].Value.(byte); ok && results[1].StatusCode.IsGood() {
+				v.AccessLevel = level
+			}
+			if text, ok := results[2].Value.(ua.LocalizedText); ok && results[2].StatusCode.IsGood() {
+				v.Description = text.Text
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) readAttributes(ctx context.Context, nodes []ua.ReadValueID) (*ua.ReadResponse, error) {
+	ctx, cancel := c.request(ctx)
+	defer cancel()
+	res, err := c.client.Read(ctx, &ua.ReadRequest{NodesToRead: nodes})
+	return res, requestError(ctx, err)
+}
+
+// dataTypeNames are the built-in OPC UA data types by NodeID
+var dataTypeNames = map[ua.NodeID]string{
+	ua.DataTypeIDBoolean:    "Boolean",
+	ua.DataTypeIDSByte:      "SByte",
+	ua.DataTypeIDByte:       "Byte",
+	ua.DataTypeIDInt16:      "Int16",
+	ua.DataTypeIDUInt16:     "UInt16",
+	ua.DataTypeIDInt32:      "Int32",
+	ua.DataTypeIDUInt32:     "UInt32",
+	ua.DataTypeIDInt64:      "Int64",
+	ua.DataTypeIDUInt64:     "UInt64",
+	ua.DataTypeIDFloat:      "Float",
+	ua.DataTypeIDDouble:     "Double",
+	ua.DataTypeIDString:     "String",
+	ua.DataTypeIDDateTime:   "DateTime",
+	ua.DataTypeIDGUID:       "Guid",
+	ua.DataTypeIDByteString: "ByteString",
+}
+
+// iecTypes are the IEC types the client reads, by the OPC UA type they are
+// served as. It is the inverse of the simulator's dataTypeNodeID, with WORD
+// and DWORD reported as UINT and UDINT and strings without a length.
+var iecTypes = map[ua.NodeID]string{
+	ua.DataTypeIDBoolean: model.TypeBOOL,
+	ua.DataTypeIDInt16:   model.TypeINT,
+	ua.DataTypeIDUInt16:  model.TypeUINT,
+	ua.DataTypeIDInt32:   model.TypeDINT,
+	ua.DataTypeIDUInt32:  model.TypeUDINT,
+	ua.DataTypeIDInt64:   model.TypeLINT,
+	ua.DataTypeIDFloat:   model.TypeREAL,
+	ua.DataTypeIDDouble:  model.TypeLREAL,
+	ua.DataTypeIDString:  model.TypeSTRING,
+}
+
+// DataTypeName returns the name of a built-in OPC UA data type, or the
+// NodeID of any other type
+func DataTypeName(id ua.NodeID) string {
+	if name, ok := dataTypeNames[id]; ok {
+		return name
+	}
+	return fmt.Sprint(id)
+}
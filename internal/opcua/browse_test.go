@@ -0,0 +1,172 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+func TestBrowseVariables_Simulator(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	variables, err := client.BrowseVariables(context.Background(), BrowseOptions{})
+	if err != nil {
+		t.Fatalf("BrowseVariables failed: %v", err)
+	}
+
+	byNodeID := make(map[string]Variable)
+	for _, v := range variables {
+		byNodeID[v.NodeID] = v
+	}
+	for _, tag := range simulatorTags {
+		v, ok := byNodeID[tag.NodeID]
+		if !ok {
+			t.Errorf("%s: %s not found", tag.Name, tag.NodeID)
+			continue
+		}
+		expected := tag.DataType
+		switch expected {
+		case "WORD":
+			expected = "UINT"
+		case "STRING[10]":
+			expected = "STRING"
+		}
+		if v.IECType != expected {
+			t.Errorf("%s: expected IEC type %s, got %s (%s)", tag.Name, expected, v.IECType, v.DataType)
+		}
+		if v.Access() != "RW" {
+			t.Errorf("%s: expected access RW, got %s", tag.Name, v.Access())
+		}
+	}
+
+	if len(variables) != len(simulatorTags) {
+		t.Errorf("Expected only the %d tags without the Server object, got %d variables", len(simulatorTags), len(variables))
+	}
+
+	pump := byNodeID["ns=4;s=Plant.Pump"]
+	if pump.Path != "Plant/Pump" || pump.BrowseName != "Pump" {
+		t.Errorf("Expected Pump at Plant/Pump, got %q named %q", pump.Path, pump.BrowseName)
+	}
+	if pump.DataType != "Boolean" || pump.Description != "Feed pump running" {
+		t.Errorf("Expected a described Boolean, got %s %q", pump.DataType, pump.Description)
+	}
+	if real := byNodeID["ns=2;s=Test.RealValue"]; real.Path != "Test/RealValue" || real.DataType != "Float" {
+		t.Errorf("Expected a Float at Test/RealValue, got %s at %q", real.DataType, real.Path)
+	}
+}
+
+func TestBrowseVariables_RootAndDepth(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	variables, err := client.BrowseVariables(context.Background(), BrowseOptions{Root: "ns=4;s=Plant"})
+	if err != nil {
+		t.Fatalf("BrowseVariables failed: %v", err)
+	}
+	if len(variables) != 9 {
+		t.Errorf("Expected the 9 Plant variables, got %d", len(variables))
+	}
+	for _, v := range variables {
+		if v.Path != v.BrowseName {
+			t.Errorf("Expected paths relative to the root, got %q", v.Path)
+		}
+	}
+
+	variables, err = client.BrowseVariables(context.Background(), BrowseOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("BrowseVariables failed: %v", err)
+	}
+	if len(variables) != 0 {
+		t.Errorf("Expected depth 1 to stop above the Plant folder, got %d variables", len(variables))
+	}
+
+	variables, err = client.BrowseVariables(context.Background(), BrowseOptions{MaxDepth: 2, Standard: true})
+	if err != nil {
+		t.Fatalf("BrowseVariables failed: %v", err)
+	}
+	var server bool
+	for _, v := range variables {
+		server = server || v.NodeID == "i=2256" // Server/ServerStatus
+	}
+	if !server {
+		t.Error("Expected the Server object's variables with Standard")
+	}
+}
+
+// pagingBrowser returns one reference per request, so every browse needs
+// continuation points
+type pagingBrowser struct {
+	MockOPCClient
+	children map[string][]ua.ReferenceDescription
+	next     int // BrowseNext calls
+}
+
+func (p *pagingBrowser) page(id string, offset int) ua.BrowseResult {
+	refs := p.children[id]
+	if offset >= len(refs) {
+		return ua.BrowseResult{}
+	}
+	result := ua.BrowseResult{References: refs[offset : offset+1]}
+	if offset+1 < len(refs) {
+		result.ContinuationPoint = ua.ByteString(fmt.Sprintf("%s|%d", id, offset+1))
+	}
+	return result
+}
+
+func (p *pagingBrowser) Browse(ctx context.Context, req *ua.BrowseRequest) (*ua.BrowseResponse, error) {
+	return &ua.BrowseResponse{Results: []ua.BrowseResult{p.page(fmt.Sprint(req.NodesToBrowse[0].NodeID), 0)}}, nil
+}
+
+func (p *pagingBrowser) BrowseNext(ctx context.Context, req *ua.BrowseNextRequest) (*ua.BrowseNextResponse, error) {
+	p.next++
+	id, offset, _ := strings.Cut(string(req.ContinuationPoints[0]), "|")
+	n, _ := strconv.Atoi(offset)
+	return &ua.BrowseNextResponse{Results: []ua.BrowseResult{p.page(id, n)}}, nil
+}
+
+func TestBrowseVariables_ContinuationPoints(t *testing.T) {
+	ref := func(id string, class ua.NodeClass) ua.ReferenceDescription {
+		return ua.ReferenceDescription{
+			NodeID:     ua.NewExpandedNodeID(ua.ParseNodeID(id)),
+			BrowseName: ua.NewQualifiedName(4, id[len("ns=4;s="):]),
+			NodeClass:  class,
+		}
+	}
+	mock := &pagingBrowser{
+		MockOPCClient: MockOPCClient{values: map[string]any{}},
+		children: map[string][]ua.ReferenceDescription{
+			"i=85":     {ref("ns=4;s=A", ua.NodeClassObject), ref("ns=4;s=X", ua.NodeClassVariable)},
+			"ns=4;s=A": {ref("ns=4;s=A1", ua.NodeClassVariable), ref("ns=4;s=A2", ua.NodeClassVariable), ref("ns=4;s=X", ua.NodeClassVariable)},
+		},
+	}
+	client := NewClientWithOPC(mock)
+
+	variables, err := client.BrowseVariables(context.Background(), BrowseOptions{})
+	if err != nil {
+		t.Fatalf("BrowseVariables failed: %v", err)
+	}
+
+	var paths []string
+	for _, v := range variables {
+		paths = append(paths, v.Path)
+	}
+	if fmt.Sprint(paths) != "[X A/A1 A/A2]" {
+		t.Errorf("Expected each variable once, breadth first, got %v", paths)
+	}
+	if mock.next != 3 {
+		t.Errorf("Expected 3 BrowseNext calls, got %d", mock.next)
+	}
+	if variables[0].DataType != "" || variables[0].Access() != "-" {
+		t.Errorf("Expected no attributes for unknown nodes, got %+v", variables[0])
+	}
+}
+
+func TestBrowseVariables_Unsupported(t *testing.T) {
+	client := NewClientWithOPC(&MockOPCClient{})
+	if _, err := client.BrowseVariables(context.Background(), BrowseOptions{}); err == nil {
+		t.Error("Expected an error for a client without browsing")
+	}
+}
@@ -2,35 +2,196 @@ package opcua
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"opcmss/internal/model"
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
 
 	"github.com/awcullen/opcua/client"
 	"github.com/awcullen/opcua/ua"
 )
 
+type OPCClient interface {
+	Read(ctx context.Context, request *ua.ReadRequest) (*ua.ReadResponse, error)
+	Write(ctx context.Context, request *ua.WriteRequest) (*ua.WriteResponse, error)
+	Close(ctx context.Context) error
+}
+
 type Client struct {
-	client *client.Client
-	ctx    context.Context
+	client      OPCClient
+	timeout     time.Duration // bounds every request, no limit when 0
+	concurrency int           // requests ReadTags sends at the same time
+
+	// maxNodesPerRead is discovered from the server on the first batch read
+	limitMu         sync.Mutex // guards maxNodesPerRead, not the read that discovers it
+	maxNodesPerRead uint32
+
+	mu           sync.Mutex    // guards subscription
+	subscription *Subscription // the active subscription, if any
+}
+
+// ClientOption configures a Client
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	security    Security
+	recovery    retry.Recovery
+	timeout     time.Duration
+	concurrency int
+	limiter     *throttle.Limiter
+}
+
+// DefaultTimeout is how long NewClient lets a request take, its retries
+// included
+const DefaultTimeout = 10 * time.Second
+
+// WithSecurity sets the security policy, certificates and user identity
+func WithSecurity(s Security) ClientOption {
+	return func(c *clientConfig) {
+		c.security = s
+	}
+}
+
+// WithRecovery sets how failed calls are retried and how the session is
+// reestablished after the connection was lost
+func WithRecovery(r retry.Recovery) ClientOption {
+	return func(c *clientConfig) {
+		c.recovery = r
+	}
+}
+
+// WithTimeout sets how long a request may take, its retries included, and
+// how long connecting may take. 0 removes the limit.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.timeout = d
+	}
+}
+
+// WithConcurrency sets how many requests may be in flight at once, which is
+// also how many ReadTags sends at the same time. Without it there is no limit
+// and ReadTags sends one request at a time.
+func WithConcurrency(n int) ClientOption {
+	return func(c *clientConfig) {
+		c.concurrency = n
+	}
 }
 
-func NewClient(endpoint string) (*Client, error) {
-	ctx := context.Background()
+// WithRateLimit makes every request, retries included, wait for its turn
+// with l. A nil Limiter does not limit. Subscriptions are not limited.
+func WithRateLimit(l *throttle.Limiter) ClientOption {
+	return func(c *clientConfig) {
+		c.limiter = l
+	}
+}
+
+// NewClient connects to the OPC UA server at endpoint. Calls that fail with a
+// transient error are retried and a lost session is reestablished, with
+// retry.DefaultRecovery unless WithRecovery says otherwise.
+func NewClient(endpoint string, opts ...ClientOption) (*Client, error) {
+	cfg := clientConfig{recovery: retry.DefaultRecovery(), timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.security.discovers() {
+		if err := cfg.security.Validate(); err != nil {
+			return nil, err
+		}
+		ctx, cancel := withTimeout(context.Background(), cfg.timeout)
+		endpoints, err := GetEndpoints(ctx, endpoint)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		selected, err := SelectEndpoint(endpoints, cfg.security.Preferences())
+		if err != nil {
+			return nil, err
+		}
+		cfg.security.Policy = selected.SecurityPolicy
+		cfg.security.Mode = selected.SecurityMode.String()
+		cfg.security.PreferPolicies, cfg.security.PreferModes = nil, nil
+	}
+
+	dialOpts, err := cfg.security.dialOptions()
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := client.Dial(ctx, endpoint, client.WithInsecureSkipVerify())
+	dial := func() (OPCClient, error) {
+		ctx, cancel := withTimeout(context.Background(), cfg.timeout)
+		defer cancel()
+		conn, err := dialContext(ctx, endpoint, dialOpts...)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	conn, err := dial()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to OPC UA server: %w", err)
 	}
 
 	return &Client{
-		client: client,
-		ctx:    ctx,
+		client:      newReconnectingClient(conn, dial, cfg.recovery, cfg.limiter, cfg.concurrency),
+		timeout:     cfg.timeout,
+		concurrency: cfg.concurrency,
 	}, nil
 }
 
-func (c *Client) ReadTag(tag model.OPCTag) (any, error) {
+// dialContext connects like client.Dial. The library waits for the server's
+// handshake without watching ctx, so a server that accepts the connection but
+// never answers is abandoned when ctx is done, and closed if it answers late.
+func dialContext(ctx context.Context, endpoint string, opts ...client.Option) (*client.Client, error) {
+	type result struct {
+		conn *client.Client
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial(ctx, endpoint, opts...)
+		done <- result{conn, err}
+	}()
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil {
+				r.conn.Abort(context.Background())
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// withTimeout bounds ctx by timeout, if there is one
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// request returns the context for a single request to the server
+func (c *Client) request(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, c.timeout)
+}
+
+// requestError reports a request that failed because its context is done
+// with the context's error, as the OPC UA client reports it as a timeout
+func requestError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		return fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return err
+}
+
+func (c *Client) ReadTag(ctx context.Context, tag model.OPCTag) (any, error) {
 	node := ua.ParseNodeID(tag.NodeID)
 
 	req := &ua.ReadRequest{
@@ -41,8 +202,10 @@ func (c *Client) ReadTag(tag model.OPCTag) (any, error) {
 			},
 		},
 	}
-	val, err := c.client.Read(c.ctx, req)
-	if err != nil {
+	ctx, cancel := c.request(ctx)
+	defer cancel()
+	val, err := c.client.Read(ctx, req)
+	if err = requestError(ctx, err); err != nil {
 		return nil, fmt.Errorf("read error: %w", err)
 	}
 
@@ -56,8 +219,11 @@ func (c *Client) ReadTag(tag model.OPCTag) (any, error) {
 	}
 
 	// Use result.Value directly (no .Value() method needed)
-	actualValue := result.Value
+	return convertValue(tag, result.Value)
+}
 
+// convertValue converts a value read from the server to the Go type of the tag's data type
+func convertValue(tag model.OPCTag, actualValue any) (any, error) {
 	switch tag.DataType {
 	case "BOOL":
 		if b, ok := actualValue.(bool); ok {
@@ -65,27 +231,17 @@ func (c *Client) ReadTag(tag model.OPCTag) (any, error) {
 		}
 		return false, fmt.Errorf("failed to convert value to bool, got type %T", actualValue)
 	case "INT":
-		// Handle different integer types that might be returned
-		switch v := actualValue.(type) {
-		case int16:
-			return v, nil
-		case int32:
-			return int16(v), nil
-		case int:
-			return int16(v), nil
-		case uint16:
-			return int16(v), nil
-		case uint32:
-			return int16(v), nil
-		default:
-			return int16(0), fmt.Errorf("failed to convert value to int16, got type %T with value %v", actualValue, actualValue)
-		}
+		n, err := convertInt(actualValue, "int16", math.MinInt16, math.MaxInt16)
+		return int16(n), err
 	case "REAL":
 		// Handle different numeric types that might be returned for REAL
 		switch v := actualValue.(type) {
 		case float32:
 			return v, nil
 		case float64:
+			if math.Abs(v) > math.MaxFloat32 && !math.IsInf(v, 0) {
+				return float32(0), fmt.Errorf("value %v out of range for float32", v)
+			}
 			return float32(v), nil
 		case int16:
 			return float32(v), nil
@@ -100,13 +256,94 @@ func (c *Client) ReadTag(tag model.OPCTag) (any, error) {
 		default:
 			return float32(0), fmt.Errorf("failed to convert value to float32, got type %T with value %v", actualValue, actualValue)
 		}
+	case "UINT", "WORD":
+		n, err := convertInt(actualValue, "uint16", 0, math.MaxUint16)
+		return uint16(n), err
+	case "DINT":
+		n, err := convertInt(actualValue, "int32", math.MinInt32, math.MaxInt32)
+		return int32(n), err
+	case "UDINT", "DWORD":
+		n, err := convertInt(actualValue, "uint32", 0, math.MaxUint32)
+		return uint32(n), err
+	case "LINT":
+		return convertInt(actualValue, "int64", math.MinInt64, math.MaxInt64)
+	case "LREAL":
+		switch v := actualValue.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		}
+		if n, ok := toInt64(actualValue); ok {
+			return float64(n), nil
+		}
+		return float64(0), fmt.Errorf("failed to convert value to float64, got type %T with value %v", actualValue, actualValue)
 	default:
+		if model.IsString(tag.DataType) {
+			if str, ok := actualValue.(string); ok {
+				return str, nil
+			}
+			return "", fmt.Errorf("failed to convert value to string, got type %T", actualValue)
+		}
 		// Return the raw value for debugging
 		return actualValue, nil
 	}
 }
 
-func (c *Client) WriteTag(tag model.OPCTag, value any) error {
+// convertInt widens an integer value returned by the server and checks that
+// it lies within lo and hi, so a value that does not fit the tag's type is
+// an error instead of wrapping around
+func convertInt(value any, typeName string, lo, hi int64) (int64, error) {
+	n, ok := toInt64(value)
+	if !ok {
+		switch value.(type) {
+		case uint64, uint:
+			return 0, fmt.Errorf("value %v out of range for %s", value, typeName)
+		}
+		return 0, fmt.Errorf("failed to convert value to %s, got type %T with value %v", typeName, value, value)
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("value %d out of range for %s", n, typeName)
+	}
+	return n, nil
+}
+
+// toInt64 widens any integer type returned by the server. A uint64 above
+// math.MaxInt64 does not fit and is not converted.
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func (c *Client) WriteTag(ctx context.Context, tag model.OPCTag, value any) error {
 	node := ua.ParseNodeID(tag.NodeID)
 
 	req := &ua.WriteRequest{
@@ -121,8 +358,10 @@ func (c *Client) WriteTag(tag model.OPCTag, value any) error {
 		},
 	}
 
-	res, err := c.client.Write(c.ctx, req)
-	if err != nil {
+	ctx, cancel := c.request(ctx)
+	defer cancel()
+	res, err := c.client.Write(ctx, req)
+	if err = requestError(ctx, err); err != nil {
 		return fmt.Errorf("error writing to node %s: %w", tag.NodeID, err)
 	}
 	if len(res.Results) > 0 && res.Results[0].IsGood() {
@@ -131,6 +370,10 @@ func (c *Client) WriteTag(tag model.OPCTag, value any) error {
 	return fmt.Errorf("failed to write to node %s: %s", tag.NodeID, res.Results[0])
 }
 
+func NewClientWithOPC(client OPCClient) *Client {
+	return &Client{client: client}
+}
+
 func (c *Client) Close() {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -1,17 +1,21 @@
 package opcua
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
 	"testing"
+	"time"
 
 	"opcmss/internal/model"
+
+	"github.com/awcullen/opcua/ua"
 )
 
 func TestOPCClient_ReadWrite(t *testing.T) {
-	client, err := NewClient("opc.tcp://localhost:4840")
-	if err != nil {
-		t.Skipf("OPC server not available: %v", err)
-	}
-	defer client.Close()
+	sim, client := dialSimulator(t)
 
 	tag := model.OPCTag{
 		Name:     "TestReal",
@@ -20,12 +24,12 @@ func TestOPCClient_ReadWrite(t *testing.T) {
 	}
 
 	writeVal := float32(23.5)
-	err = client.WriteTag(tag, writeVal)
+	err := client.WriteTag(context.Background(), tag, writeVal)
 	if err != nil {
 		t.Fatalf("Write failed: %v", err)
 	}
 
-	readVal, err := client.ReadTag(tag)
+	readVal, err := client.ReadTag(context.Background(), tag)
 	if err != nil {
 		t.Fatalf("Read failed: %v", err)
 	}
@@ -38,4 +42,255 @@ func TestOPCClient_ReadWrite(t *testing.T) {
 	if valFloat != writeVal {
 		t.Errorf("Mismatch: wrote %.2f, read %.2f", writeVal, valFloat)
 	}
+
+	if stored, _ := sim.Value(tag); stored != writeVal {
+		t.Errorf("Expected the simulator to hold %.2f, got %v", writeVal, stored)
+	}
+}
+
+// MockOPCClient serves reads from a map keyed by NodeID and records the
+// number of nodes in every ReadRequest
+type MockOPCClient struct {
+	values          map[string]any
+	maxNodesPerRead any
+	readError       error
+	requestSizes    []int
+}
+
+func (m *MockOPCClient) Read(ctx context.Context, req *ua.ReadRequest) (*ua.ReadResponse, error) {
+	if m.readError != nil {
+		return nil, m.readError
+	}
+
+	res := &ua.ReadResponse{}
+	for _, node := range req.NodesToRead {
+		if node.NodeID == ua.VariableIDServerServerCapabilitiesOperationLimitsMaxNodesPerRead {
+			res.Results = append(res.Results, ua.DataValue{Value: m.maxNodesPerRead})
+			continue
+		}
+		value, ok := m.values[fmt.Sprint(node.NodeID)]
+		if !ok {
+			res.Results = append(res.Results, ua.DataValue{StatusCode: ua.BadNodeIDUnknown})
+			continue
+		}
+		res.Results = append(res.Results, ua.DataValue{Value: value, SourceTimestamp: time.Now()})
+	}
+
+	if len(req.NodesToRead) != 1 || req.NodesToRead[0].NodeID != ua.VariableIDServerServerCapabilitiesOperationLimitsMaxNodesPerRead {
+		m.requestSizes = append(m.requestSizes, len(req.NodesToRead))
+	}
+	return res, nil
+}
+
+func (m *MockOPCClient) Write(ctx context.Context, req *ua.WriteRequest) (*ua.WriteResponse, error) {
+	return &ua.WriteResponse{Results: []ua.StatusCode{ua.Good}}, nil
+}
+
+func (m *MockOPCClient) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestConvertValue_Range(t *testing.T) {
+	testCases := []struct {
+		dataType string
+		value    any
+		expected any // nil when the conversion must fail
+	}{
+		{"INT", int32(-32768), int16(-32768)},
+		{"INT", int32(40000), nil},
+		{"INT", uint16(32768), nil},
+		{"UINT", int32(65535), uint16(65535)},
+		{"UINT", int32(-1), nil},
+		{"WORD", uint32(65536), nil},
+		{"DINT", int64(math.MaxInt32), int32(math.MaxInt32)},
+		{"DINT", int64(math.MaxInt32) + 1, nil},
+		{"UDINT", int32(-1), nil},
+		{"DWORD", uint64(math.MaxUint32) + 1, nil},
+		{"LINT", uint64(math.MaxInt64), int64(math.MaxInt64)},
+		{"LINT", uint64(math.MaxInt64) + 1, nil},
+		{"REAL", float64(1e39), nil},
+		{"REAL", math.Inf(1), float32(math.Inf(1))},
+		{"DINT", "12", nil},
+	}
+	for _, tc := range testCases {
+		value, err := convertValue(model.OPCTag{Name: "T", DataType: tc.dataType}, tc.value)
+		if tc.expected == nil {
+			if err == nil {
+				t.Errorf("%s from %T %v: expected an error, got %v", tc.dataType, tc.value, tc.value, value)
+			}
+			continue
+		}
+		if err != nil || value != tc.expected {
+			t.Errorf("%s from %T %v: expected %v, got %v (err %v)", tc.dataType, tc.value, tc.value, tc.expected, value, err)
+		}
+	}
+}
+
+func TestReadTags_ChunksByMaxNodesPerRead(t *testing.T) {
+	mock := &MockOPCClient{
+		values:          map[string]any{},
+		maxNodesPerRead: uint32(4),
+	}
+	var tags []model.OPCTag
+	for i := 0; i < 10; i++ {
+		nodeID := fmt.Sprintf("ns=4;s=Tag%d", i)
+		mock.values[nodeID] = int16(i)
+		tags = append(tags, model.OPCTag{Name: fmt.Sprintf("Tag%d", i), NodeID: nodeID, DataType: "INT"})
+	}
+	client := NewClientWithOPC(mock)
+
+	results := client.ReadTags(context.Background(), tags)
+
+	if len(results) != 10 {
+		t.Fatalf("Expected 10 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Err != nil || result.Value != int16(i) {
+			t.Errorf("Tag%d: expected %d, got %v (err %v)", i, i, result.Value, result.Err)
+		}
+		if result.SourceTimestamp.IsZero() {
+			t.Errorf("Tag%d: expected source timestamp", i)
+		}
+	}
+
+	expected := []int{4, 4, 2}
+	if fmt.Sprint(mock.requestSizes) != fmt.Sprint(expected) {
+		t.Errorf("Expected request sizes %v, got %v", expected, mock.requestSizes)
+	}
+}
+
+func TestReadTags_DefaultLimitAndBadStatus(t *testing.T) {
+	mock := &MockOPCClient{
+		values: map[string]any{"ns=4;s=Good": float64(1.5)},
+	}
+	client := NewClientWithOPC(mock)
+
+	if limit := client.MaxNodesPerRead(context.Background()); limit != DefaultMaxNodesPerRead {
+		t.Errorf("Expected default limit %d, got %d", DefaultMaxNodesPerRead, limit)
+	}
+
+	results := client.ReadTags(context.Background(), []model.OPCTag{
+		{Name: "Good", NodeID: "ns=4;s=Good", DataType: "REAL"},
+		{Name: "Missing", NodeID: "ns=4;s=Missing", DataType: "REAL"},
+	})
+
+	if results[0].Err != nil || results[0].Value != float32(1.5) {
+		t.Errorf("Expected float32 1.5, got %v (err %v)", results[0].Value, results[0].Err)
+	}
+	if results[1].Err == nil || results[1].StatusCode != ua.BadNodeIDUnknown {
+		t.Errorf("Expected BadNodeIdUnknown, got %v (err %v)", results[1].StatusCode, results[1].Err)
+	}
+}
+
+func TestMaxNodesPerRead_CachesOnlySuccess(t *testing.T) {
+	mock := &MockOPCClient{readError: errors.New("secure channel closed")}
+	client := NewClientWithOPC(mock)
+
+	if limit := client.MaxNodesPerRead(context.Background()); limit != DefaultMaxNodesPerRead {
+		t.Errorf("Expected default limit %d after a failed read, got %d", DefaultMaxNodesPerRead, limit)
+	}
+
+	// The next call asks again, and concurrent callers share the answer
+	mock.readError = nil
+	mock.maxNodesPerRead = uint32(4)
+	var wg sync.WaitGroup
+	limits := make([]uint32, 8)
+	for i := range limits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limits[i] = client.MaxNodesPerRead(context.Background())
+		}()
+	}
+	wg.Wait()
+	for i, limit := range limits {
+		if limit != 4 {
+			t.Errorf("Call %d: expected limit 4, got %d", i, limit)
+		}
+	}
+}
+
+// slowOPCClient holds every read until release is closed
+type slowOPCClient struct {
+	MockOPCClient
+	release chan struct{}
+}
+
+func (s *slowOPCClient) Read(ctx context.Context, req *ua.ReadRequest) (*ua.ReadResponse, error) {
+	<-s.release
+	return s.MockOPCClient.Read(ctx, req)
+}
+
+func TestMaxNodesPerRead_NotHeldAcrossRead(t *testing.T) {
+	slow := &slowOPCClient{MockOPCClient: MockOPCClient{maxNodesPerRead: uint32(4)}, release: make(chan struct{})}
+	client := NewClientWithOPC(slow)
+
+	// A caller stuck on a slow server does not block one with a cached value
+	go client.MaxNodesPerRead(context.Background())
+	time.Sleep(10 * time.Millisecond)
+	client.limitMu.Lock()
+	client.maxNodesPerRead = 7
+	client.limitMu.Unlock()
+
+	done := make(chan uint32)
+	go func() { done <- client.MaxNodesPerRead(context.Background()) }()
+	select {
+	case limit := <-done:
+		if limit != 7 {
+			t.Errorf("Expected the cached limit 7, got %d", limit)
+		}
+	case <-time.After(time.Second):
+		t.Error("MaxNodesPerRead waited for another caller's read")
+	}
+	close(slow.release)
+}
+
+func TestReadTags_RequestError(t *testing.T) {
+	mock := &MockOPCClient{readError: errors.New("secure channel closed")}
+	client := NewClientWithOPC(mock)
+
+	results := client.ReadTags(context.Background(), []model.OPCTag{{NodeID: "ns=4;s=A"}, {NodeID: "ns=4;s=B"}})
+	for i, result := range results {
+		if result.Err == nil {
+			t.Errorf("Result %d: expected error, got none", i)
+		}
+	}
+}
+
+// blockingOPCClient answers no read until the request's context is done,
+// and then fails it as a timeout like the OPC UA client does
+type blockingOPCClient struct {
+	MockOPCClient
+}
+
+func (b *blockingOPCClient) Read(ctx context.Context, req *ua.ReadRequest) (*ua.ReadResponse, error) {
+	<-ctx.Done()
+	return nil, ua.BadRequestTimeout
+}
+
+func TestReadTag_Timeout(t *testing.T) {
+	client := NewClientWithOPC(&blockingOPCClient{})
+	client.timeout = 20 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.ReadTag(context.Background(), model.OPCTag{NodeID: "ns=2;s=Slow", DataType: "INT"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the read to time out, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the timeout to end the read, took %s", elapsed)
+	}
+}
+
+func TestReadTags_Cancelled(t *testing.T) {
+	client := NewClientWithOPC(&blockingOPCClient{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	tags := []model.OPCTag{{NodeID: "ns=2;s=A", DataType: "INT"}, {NodeID: "ns=2;s=B", DataType: "INT"}}
+	for i, result := range client.ReadTags(ctx, tags) {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("Tag %d: expected context.Canceled, got %v", i, result.Err)
+		}
+	}
 }
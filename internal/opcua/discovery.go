@@ -0,0 +1,163 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/awcullen/opcua/client"
+	"github.com/awcullen/opcua/ua"
+)
+
+// Endpoint is one way of connecting to a server, as advertised by the
+// server's GetEndpoints service
+type Endpoint struct {
+	URL            string
+	SecurityPolicy string // URI, see SecurityPolicyName
+	SecurityMode   ua.MessageSecurityMode
+	SecurityLevel  byte // the server's own ranking, higher is more secure
+	UserTokens     []ua.UserTokenType
+}
+
+func (e Endpoint) String() string {
+	tokens := make([]string, len(e.UserTokens))
+	for i, t := range e.UserTokens {
+		tokens[i] = t.String()
+	}
+	return fmt.Sprintf("%s %s/%s (level %d, logins: %s)",
+		e.URL, SecurityPolicyName(e.SecurityPolicy), e.SecurityMode, e.SecurityLevel, strings.Join(tokens, ", "))
+}
+
+// Supported reports whether the client can connect with the endpoint's policy
+func (e Endpoint) Supported() bool {
+	for _, uri := range securityPolicies {
+		if e.SecurityPolicy == uri {
+			return e.SecurityMode != ua.MessageSecurityModeInvalid
+		}
+	}
+	return false
+}
+
+// GetEndpoints asks the server at endpointURL for its endpoints, most secure
+// first. It gives up when ctx is done.
+func GetEndpoints(ctx context.Context, endpointURL string) ([]Endpoint, error) {
+	res, err := getEndpoints(ctx, &ua.GetEndpointsRequest{
+		EndpointURL: endpointURL,
+		ProfileURIs: []string{ua.TransportProfileURIUaTcpTransport},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints from %s: %w", endpointURL, requestError(ctx, err))
+	}
+
+	endpoints := make([]Endpoint, 0, len(res.Endpoints))
+	for _, e := range res.Endpoints {
+		endpoint := Endpoint{
+			URL:            e.EndpointURL,
+			SecurityPolicy: e.SecurityPolicyURI,
+			SecurityMode:   e.SecurityMode,
+			SecurityLevel:  e.SecurityLevel,
+		}
+		for _, token := range e.UserIdentityTokens {
+			if !slices.Contains(endpoint.UserTokens, token.TokenType) {
+				endpoint.UserTokens = append(endpoint.UserTokens, token.TokenType)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].SecurityLevel > endpoints[j].SecurityLevel
+	})
+	return endpoints, nil
+}
+
+// getEndpoints calls the GetEndpoints service. The library waits for the
+// server's handshake without watching ctx, so a server that accepts the
+// connection but never answers is abandoned when ctx is done.
+func getEndpoints(ctx context.Context, req *ua.GetEndpointsRequest) (*ua.GetEndpointsResponse, error) {
+	type result struct {
+		res *ua.GetEndpointsResponse
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := client.GetEndpoints(ctx, req)
+		done <- result{res, err}
+	}()
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// EndpointPreferences ranks the endpoints of a server
+type EndpointPreferences struct {
+	Policies  []string         // short names or URIs, most preferred first; empty accepts any
+	Modes     []string         // None, Sign or SignAndEncrypt, most preferred first; empty accepts any
+	UserToken ua.UserTokenType // how the client logs in
+}
+
+// SelectEndpoint returns the best endpoint the client can use: it must
+// accept the client's login and a listed policy and mode. Endpoints are
+// ranked by the order of the policies, then of the modes, then by the
+// server's security level.
+func SelectEndpoint(endpoints []Endpoint, prefs EndpointPreferences) (Endpoint, error) {
+	policies := make([]string, len(prefs.Policies))
+	for i, p := range prefs.Policies {
+		uri, err := ParseSecurityPolicy(p)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		policies[i] = uri
+	}
+	modes := make([]ua.MessageSecurityMode, len(prefs.Modes))
+	for i, m := range prefs.Modes {
+		mode, err := ParseSecurityMode(m)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		modes[i] = mode
+	}
+
+	// rank is the position in a preference list, 0 for an empty list and -1
+	// when the value is not listed
+	rank := func(list []string, v string) int {
+		if len(list) == 0 {
+			return 0
+		}
+		return slices.Index(list, v)
+	}
+	modeRank := func(v ua.MessageSecurityMode) int {
+		if len(modes) == 0 {
+			return 0
+		}
+		return slices.Index(modes, v)
+	}
+
+	var candidates []Endpoint
+	for _, e := range endpoints {
+		if e.Supported() && slices.Contains(e.UserTokens, prefs.UserToken) &&
+			rank(policies, e.SecurityPolicy) >= 0 && modeRank(e.SecurityMode) >= 0 {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return Endpoint{}, fmt.Errorf("no endpoint matches the preferred policies %v and modes %v with a %s login",
+			prefs.Policies, prefs.Modes, prefs.UserToken)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ra, rb := rank(policies, a.SecurityPolicy), rank(policies, b.SecurityPolicy); ra != rb {
+			return ra < rb
+		}
+		if ra, rb := modeRank(a.SecurityMode), modeRank(b.SecurityMode); ra != rb {
+			return ra < rb
+		}
+		return a.SecurityLevel > b.SecurityLevel
+	})
+	return candidates[0], nil
+}
@@ -0,0 +1,153 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+var testEndpoints = []Endpoint{
+	{SecurityPolicy: ua.SecurityPolicyURINone, SecurityMode: ua.MessageSecurityModeNone, SecurityLevel: 0,
+		UserTokens: []ua.UserTokenType{ua.UserTokenTypeAnonymous, ua.UserTokenTypeUserName}},
+	{SecurityPolicy: ua.SecurityPolicyURIBasic256Sha256, SecurityMode: ua.MessageSecurityModeSign, SecurityLevel: 3,
+		UserTokens: []ua.UserTokenType{ua.UserTokenTypeUserName}},
+	{SecurityPolicy: ua.SecurityPolicyURIBasic256Sha256, SecurityMode: ua.MessageSecurityModeSignAndEncrypt, SecurityLevel: 8,
+		UserTokens: []ua.UserTokenType{ua.UserTokenTypeUserName}},
+	{SecurityPolicy: ua.SecurityPolicyURIAes256Sha256RsaPss, SecurityMode: ua.MessageSecurityModeSignAndEncrypt, SecurityLevel: 10,
+		UserTokens: []ua.UserTokenType{ua.UserTokenTypeUserName, ua.UserTokenTypeCertificate}},
+	{SecurityPolicy: "http://example.com/UA/SecurityPolicy#Custom", SecurityMode: ua.MessageSecurityModeSignAndEncrypt, SecurityLevel: 20,
+		UserTokens: []ua.UserTokenType{ua.UserTokenTypeUserName}},
+}
+
+func TestSelectEndpoint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		prefs  EndpointPreferences
+		policy string
+		mode   ua.MessageSecurityMode
+	}{
+		{"highest supported level", EndpointPreferences{UserToken: ua.UserTokenTypeUserName},
+			ua.SecurityPolicyURIAes256Sha256RsaPss, ua.MessageSecurityModeSignAndEncrypt},
+		{"policy order wins over level", EndpointPreferences{Policies: []string{"Basic256Sha256", "Aes256"}, UserToken: ua.UserTokenTypeUserName},
+			ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSignAndEncrypt},
+		{"mode order", EndpointPreferences{Policies: []string{"Basic256Sha256"}, Modes: []string{"Sign", "SignAndEncrypt"}, UserToken: ua.UserTokenTypeUserName},
+			ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSign},
+		{"login narrows the choice", EndpointPreferences{UserToken: ua.UserTokenTypeAnonymous},
+			ua.SecurityPolicyURINone, ua.MessageSecurityModeNone},
+		{"fallback policy", EndpointPreferences{Policies: []string{"Aes128", "Aes256"}, UserToken: ua.UserTokenTypeCertificate},
+			ua.SecurityPolicyURIAes256Sha256RsaPss, ua.MessageSecurityModeSignAndEncrypt},
+	}
+
+	for _, tc := range testCases {
+		got, err := SelectEndpoint(testEndpoints, tc.prefs)
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %v", tc.name, err)
+			continue
+		}
+		if got.SecurityPolicy != tc.policy || got.SecurityMode != tc.mode {
+			t.Errorf("%s: expected %s/%s, got %s/%s", tc.name,
+				SecurityPolicyName(tc.policy), tc.mode, SecurityPolicyName(got.SecurityPolicy), got.SecurityMode)
+		}
+	}
+
+	_, err := SelectEndpoint(testEndpoints, EndpointPreferences{Policies: []string{"Aes128"}, UserToken: ua.UserTokenTypeUserName})
+	if err == nil {
+		t.Error("Expected an error when no endpoint matches")
+	}
+	if _, err := SelectEndpoint(testEndpoints, EndpointPreferences{Modes: []string{"Encrypt"}}); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestSecurity_Preferences(t *testing.T) {
+	prefs := Security{}.Preferences()
+	if len(prefs.Policies) != 1 || prefs.Policies[0] != "None" || prefs.UserToken != ua.UserTokenTypeAnonymous {
+		t.Errorf("Expected only None without a certificate, got %+v", prefs)
+	}
+
+	prefs = Security{Policy: "Aes256", Mode: "Sign", UserName: "op"}.Preferences()
+	if len(prefs.Policies) != 1 || prefs.Policies[0] != "Aes256" || prefs.Modes[0] != "Sign" || prefs.UserToken != ua.UserTokenTypeUserName {
+		t.Errorf("Expected the fixed policy and mode, got %+v", prefs)
+	}
+
+	if err := (Security{Policy: "None", PreferPolicies: []string{"Aes256"}}).Validate(); err == nil {
+		t.Error("Expected an error for a fixed policy with preferences")
+	}
+}
+
+func TestGetEndpoints_Unresponsive(t *testing.T) {
+	// A server that accepts the connection but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	endpoint := "opc.tcp://" + listener.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := GetEndpoints(ctx, endpoint); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected GetEndpoints to give up with ctx, took %v", elapsed)
+	}
+
+	// Discovery and dialing in NewClient are bounded by its timeout
+	security := Security{PreferPolicies: []string{"None"}, PKIDir: t.TempDir()}
+	for _, opts := range [][]ClientOption{
+		{WithSecurity(security), WithTimeout(200 * time.Millisecond)},
+		{WithTimeout(200 * time.Millisecond)},
+	} {
+		start = time.Now()
+		if _, err := NewClient(endpoint, opts...); err == nil {
+			t.Error("Expected NewClient to fail")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected NewClient to give up after its timeout, took %v", elapsed)
+		}
+	}
+}
+
+func TestGetEndpoints_Simulator(t *testing.T) {
+	sim := startSimulator(t)
+
+	endpoints, err := GetEndpoints(context.Background(), sim.Endpoint())
+	if err != nil {
+		t.Fatalf("GetEndpoints failed: %v", err)
+	}
+	// None, plus Sign and SignAndEncrypt for each of the five secure policies
+	if len(endpoints) != 11 {
+		t.Fatalf("Expected 11 endpoints, got %d: %v", len(endpoints), endpoints)
+	}
+	for i := 1; i < len(endpoints); i++ {
+		if endpoints[i].SecurityLevel > endpoints[i-1].SecurityLevel {
+			t.Errorf("Expected endpoints ordered by security level, got %v", endpoints)
+			break
+		}
+	}
+
+	security := Security{PreferPolicies: []string{"Aes256", "Basic256Sha256"}, PreferModes: []string{"Sign"}, PKIDir: t.TempDir()}
+	client, err := NewClient(sim.Endpoint(), WithSecurity(security))
+	if err != nil {
+		t.Fatalf("NewClient with preferences failed: %v", err)
+	}
+	defer client.Close()
+	if value, err := client.ReadTag(context.Background(), simulatorTags[0]); err != nil || value != simulatorValues["Pump"] {
+		t.Errorf("Expected to read %v, got %v, %v", simulatorValues["Pump"], value, err)
+	}
+}
@@ -0,0 +1,305 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// ErrDisconnected is returned by calls made while the connection is down and
+// the next reconnect attempt is not due yet
+var ErrDisconnected = errors.New("not connected to the OPC UA server")
+
+// IsTransient reports whether a call that failed with err may succeed when
+// repeated: a lost session or secure channel, a timeout or a busy server
+func IsTransient(err error) bool {
+	return connectionLost(err) ||
+		errors.Is(err, ErrDisconnected) ||
+		netTimeout(err) ||
+		errors.Is(err, ua.BadTimeout) ||
+		errors.Is(err, ua.BadRequestTimeout) ||
+		errors.Is(err, ua.BadTooManyOperations) ||
+		errors.Is(err, ua.BadResourceUnavailable)
+}
+
+// lostStatuses mean the session or the secure channel is gone
+var lostStatuses = []ua.StatusCode{
+	ua.BadSessionIDInvalid,
+	ua.BadSessionClosed,
+	ua.BadSessionNotActivated,
+	ua.BadSecureChannelClosed,
+	ua.BadSecureChannelIDInvalid,
+	ua.BadTCPSecureChannelUnknown,
+	ua.BadConnectionClosed,
+	ua.BadNotConnected,
+	ua.BadServerNotConnected,
+	ua.BadCommunicationError,
+	ua.BadServerHalted,
+	ua.BadShutdown,
+}
+
+// connectionLost reports whether the client has to reconnect after a call
+// failed with err. A call that ran out of time, its context's or a network
+// read or write deadline, or was cancelled leaves the session as it was.
+func connectionLost(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || netTimeout(err) {
+		return false
+	}
+	for _, status := range lostStatuses {
+		if errors.Is(err, status) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
+
+// netTimeout reports whether err is a network read or write deadline running
+// out. context.DeadlineExceeded is a net.Error too, but not one of them.
+func netTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout() && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// aborter is implemented by OPC clients that can drop their connection
+// without closing the session first
+type aborter interface {
+	Abort(ctx context.Context) error
+}
+
+// reconnectingClient dials a new session after the connection was lost and
+// retries the calls that failed with a transient error. Subscriptions belong
+// to the lost session, so they end with an error instead of being recreated.
+// Every request, retries included, waits for a free slot and for its turn
+// with the limiter.
+type reconnectingClient struct {
+	dial     func() (OPCClient, error)
+	recovery retry.Recovery
+	limiter  *throttle.Limiter // nil for no limit
+	slots    chan struct{}     // one per request in flight, nil for no limit
+
+	mu       sync.Mutex
+	conn     OPCClient // nil while the connection is down
+	lostErr  error     // why the connection went down
+	failures int       // failed reconnect attempts in a row
+	nextDial time.Time // when the next reconnect attempt is due
+}
+
+// newReconnectingClient wraps conn. concurrency bounds the requests in
+// flight, 0 or less for no limit.
+func newReconnectingClient(conn OPCClient, dial func() (OPCClient, error), recovery retry.Recovery, limiter *throttle.Limiter, concurrency int) *reconnectingClient {
+	r := &reconnectingClient{conn: conn, dial: dial, recovery: recovery, limiter: limiter}
+	if concurrency > 0 {
+		r.slots = make(chan struct{}, concurrency)
+	}
+	return r
+}
+
+// connection returns the open connection, reconnecting once the backoff
+// after the previous attempt has passed
+func (r *reconnectingClient) connection() (OPCClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn != nil {
+		return r.conn, nil
+	}
+	if wait := time.Until(r.nextDial); wait > 0 {
+		return nil, fmt.Errorf("%w, reconnecting in %s: %v", ErrDisconnected, wait.Round(time.Millisecond), r.lostErr)
+	}
+
+	r.recovery.Notify(retry.StateReconnecting, nil)
+	conn, err := r.dial()
+	if err != nil {
+		r.lostErr = err
+		r.nextDial = time.Now().Add(r.recovery.Reconnect.Delay(r.failures))
+		r.failures++
+		r.recovery.Notify(retry.StateDisconnected, err)
+		return nil, fmt.Errorf("failed to reconnect: %w", err)
+	}
+
+	r.conn, r.failures = conn, 0
+	r.recovery.Notify(retry.StateConnected, nil)
+	return conn, nil
+}
+
+// lost drops conn after a call on it failed with err, unless another call
+// already replaced it
+func (r *reconnectingClient) lost(conn OPCClient, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn != conn {
+		return
+	}
+	r.conn, r.lostErr, r.nextDial = nil, err, time.Time{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if a, ok := conn.(aborter); ok {
+		a.Abort(ctx)
+	} else {
+		conn.Close(ctx)
+	}
+	r.recovery.Notify(retry.StateDisconnected, err)
+}
+
+// acquire waits for a free request slot and then for the limiter. The
+// returned func frees the slot.
+func (r *reconnectingClient) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if r.slots != nil {
+		select {
+		case r.slots <- struct{}{}:
+			release = func() { <-r.slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := r.limiter.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// call runs fn on an open connection under the policy
+func (r *reconnectingClient) call(ctx context.Context, policy retry.Policy, fn func(conn OPCClient) error) error {
+	return policy.Do(ctx, IsTransient, func() error {
+		release, err := r.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return r.attempt(fn)
+	})
+}
+
+// attempt runs fn once on an open connection and drops the connection when
+// fn lost it
+func (r *reconnectingClient) attempt(fn func(conn OPCClient) error) error {
+	conn, err := r.connection()
+	if err != nil {
+		return err
+	}
+	err = fn(conn)
+	if err != nil && connectionLost(err) {
+		r.lost(conn, err)
+	}
+	return err
+}
+
+func (r *reconnectingClient) Read(ctx context.Context, request *ua.ReadRequest) (res *ua.ReadResponse, err error) {
+	err = r.call(ctx, r.recovery.Read, func(conn OPCClient) error {
+		res, err = conn.Read(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+func (r *reconnectingClient) Write(ctx context.Context, request *ua.WriteRequest) (res *ua.WriteResponse, err error) {
+	err = r.call(ctx, r.recovery.Write, func(conn OPCClient) error {
+		res, err = conn.Write(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+func (r *reconnectingClient) Browse(ctx context.Context, request *ua.BrowseRequest) (res *ua.BrowseResponse, err error) {
+	err = r.call(ctx, r.recovery.Read, func(conn OPCClient) error {
+		browser, ok := conn.(Browser)
+		if !ok {
+			return fmt.Errorf("OPC client does not support browsing")
+		}
+		res, err = browser.Browse(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+// BrowseNext is not retried, as continuation points do not survive a
+// reconnect
+func (r *reconnectingClient) BrowseNext(ctx context.Context, request *ua.BrowseNextRequest) (res *ua.BrowseNextResponse, err error) {
+	err = r.call(ctx, retry.Policy{}, func(conn OPCClient) error {
+		browser, ok := conn.(Browser)
+		if !ok {
+			return fmt.Errorf("OPC client does not support browsing")
+		}
+		res, err = browser.BrowseNext(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+// subscriber runs fn with the connection's subscription services. The calls
+// are not retried, as a subscription lives in a single session, and take no
+// request slot, as a Publish waits at the server for notifications.
+func (r *reconnectingClient) subscriber(ctx context.Context, fn func(s Subscriber) error) error {
+	return retry.Policy{}.Do(ctx, IsTransient, func() error {
+		return r.attempt(func(conn OPCClient) error {
+			s, ok := conn.(Subscriber)
+			if !ok {
+				return fmt.Errorf("OPC client does not support subscriptions")
+			}
+			return fn(s)
+		})
+	})
+}
+
+func (r *reconnectingClient) CreateSubscription(ctx context.Context, request *ua.CreateSubscriptionRequest) (res *ua.CreateSubscriptionResponse, err error) {
+	err = r.subscriber(ctx, func(s Subscriber) error {
+		res, err = s.CreateSubscription(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+func (r *reconnectingClient) CreateMonitoredItems(ctx context.Context, request *ua.CreateMonitoredItemsRequest) (res *ua.CreateMonitoredItemsResponse, err error) {
+	err = r.subscriber(ctx, func(s Subscriber) error {
+		res, err = s.CreateMonitoredItems(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+func (r *reconnectingClient) Publish(ctx context.Context, request *ua.PublishRequest) (res *ua.PublishResponse, err error) {
+	err = r.subscriber(ctx, func(s Subscriber) error {
+		res, err = s.Publish(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+func (r *reconnectingClient) DeleteSubscriptions(ctx context.Context, request *ua.DeleteSubscriptionsRequest) (res *ua.DeleteSubscriptionsResponse, err error) {
+	err = r.subscriber(ctx, func(s Subscriber) error {
+		res, err = s.DeleteSubscriptions(ctx, request)
+		return err
+	})
+	return res, err
+}
+
+func (r *reconnectingClient) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	conn := r.conn
+	r.conn = nil
+	return conn.Close(ctx)
+}
@@ -0,0 +1,253 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+	"opcmss/internal/retry"
+	"opcmss/internal/throttle"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+func TestIsTransient(t *testing.T) {
+	testCases := []struct {
+		err       error
+		transient bool
+		lost      bool
+	}{
+		{ua.BadSessionIDInvalid, true, true},
+		{fmt.Errorf("read error: %w", ua.BadSecureChannelClosed), true, true},
+		{io.EOF, true, true},
+		{ua.BadTimeout, true, false},
+		{ua.BadRequestTimeout, true, false},
+		{ErrDisconnected, true, false},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, true, false},
+		{&net.OpError{Op: "write", Net: "tcp", Err: errors.New("network is unreachable")}, true, true},
+		{fmt.Errorf("read error: %w", context.DeadlineExceeded), false, false},
+		{context.Canceled, false, false},
+		{ua.BadNodeIDUnknown, false, false},
+		{ua.BadUserAccessDenied, false, false},
+	}
+	for _, tc := range testCases {
+		if got := IsTransient(tc.err); got != tc.transient {
+			t.Errorf("IsTransient(%v): expected %v, got %v", tc.err, tc.transient, got)
+		}
+		if got := connectionLost(tc.err); got != tc.lost {
+			t.Errorf("connectionLost(%v): expected %v, got %v", tc.err, tc.lost, got)
+		}
+	}
+}
+
+// failingOPCClient fails the next reads with the queued errors
+type failingOPCClient struct {
+	MockOPCClient
+	failures []error
+	closed   bool
+}
+
+func (f *failingOPCClient) Read(ctx context.Context, req *ua.ReadRequest) (*ua.ReadResponse, error) {
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return nil, err
+	}
+	return f.MockOPCClient.Read(ctx, req)
+}
+
+func (f *failingOPCClient) Close(ctx context.Context) error {
+	f.closed = true
+	return nil
+}
+
+func TestReconnectingClient_Read(t *testing.T) {
+	tag := model.OPCTag{Name: "Flow", NodeID: "ns=4;s=Flow", DataType: "REAL"}
+	values := map[string]any{tag.NodeID: float32(2.5)}
+
+	testCases := []struct {
+		name     string
+		failures []error
+		attempts int
+		err      error
+		dials    int
+		states   string
+	}{
+		{"session lost", []error{ua.BadSessionIDInvalid}, 2, nil, 1, "[disconnected reconnecting connected]"},
+		{"timeout", []error{ua.BadTimeout, ua.BadTimeout}, 3, nil, 0, "[]"},
+		{"read deadline", []error{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}}, 2, nil, 0, "[]"},
+		{"gives up", []error{ua.BadTimeout, ua.BadTimeout}, 2, ua.BadTimeout, 0, "[]"},
+		{"permanent", []error{ua.BadNodeIDUnknown}, 3, ua.BadNodeIDUnknown, 0, "[]"},
+	}
+
+	for _, tc := range testCases {
+		first := &failingOPCClient{MockOPCClient: MockOPCClient{values: values}, failures: tc.failures}
+		dials := 0
+		dial := func() (OPCClient, error) {
+			dials++
+			return &failingOPCClient{MockOPCClient: MockOPCClient{values: values}}, nil
+		}
+
+		var states []string
+		recovery := retry.Recovery{
+			Read:    retry.Policy{Attempts: tc.attempts, Backoff: retry.Backoff{Initial: time.Millisecond}},
+			OnState: func(state retry.State, err error) { states = append(states, string(state)) },
+		}
+		client := NewClientWithOPC(newReconnectingClient(first, dial, recovery, nil, 0))
+
+		value, err := client.ReadTag(context.Background(), tag)
+		if !errors.Is(err, tc.err) || (tc.err == nil && value != float32(2.5)) {
+			t.Errorf("%s: expected 2.5 and %v, got %v and %v", tc.name, tc.err, value, err)
+		}
+		if dials != tc.dials || fmt.Sprint(states) != tc.states {
+			t.Errorf("%s: expected %d dials and states %s, got %d and %v", tc.name, tc.dials, tc.states, dials, states)
+		}
+		if first.closed != (tc.dials > 0) {
+			t.Errorf("%s: expected the lost connection to be closed", tc.name)
+		}
+	}
+}
+
+// concurrentOPCClient records the most reads in flight at the same time
+type concurrentOPCClient struct {
+	MockOPCClient
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (c *concurrentOPCClient) Read(ctx context.Context, req *ua.ReadRequest) (*ua.ReadResponse, error) {
+	c.mu.Lock()
+	c.running++
+	c.peak = max(c.peak, c.running)
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+	return c.MockOPCClient.Read(ctx, req)
+}
+
+func TestReconnectingClient_BoundsConcurrency(t *testing.T) {
+	mock := &concurrentOPCClient{MockOPCClient: MockOPCClient{values: map[string]any{}, maxNodesPerRead: uint32(1)}}
+	var tags []model.OPCTag
+	for i := range 12 {
+		nodeID := fmt.Sprintf("ns=4;s=Tag%d", i)
+		mock.values[nodeID] = int16(i)
+		tags = append(tags, model.OPCTag{Name: fmt.Sprintf("Tag%d", i), NodeID: nodeID, DataType: "INT"})
+	}
+
+	// ReadTags sends 4 requests at once, but only 2 are let through
+	client := NewClientWithOPC(newReconnectingClient(mock, nil, retry.Recovery{}, nil, 2))
+	client.concurrency = 4
+
+	for i, result := range client.ReadTags(context.Background(), tags) {
+		if result.Err != nil || result.Value != int16(i) {
+			t.Errorf("Tag%d: expected %d, got %v (err %v)", i, i, result.Value, result.Err)
+		}
+	}
+	if mock.peak != 2 {
+		t.Errorf("Expected 2 reads in flight at most, got %d", mock.peak)
+	}
+}
+
+func TestReconnectingClient_RateLimit(t *testing.T) {
+	mock := &MockOPCClient{values: map[string]any{"ns=4;s=Flow": float32(2.5)}}
+	client := NewClientWithOPC(newReconnectingClient(mock, nil, retry.Recovery{}, throttle.NewLimiter(50), 0))
+	tag := model.OPCTag{Name: "Flow", NodeID: "ns=4;s=Flow", DataType: "REAL"}
+
+	start := time.Now()
+	for range 3 {
+		if _, err := client.ReadTag(context.Background(), tag); err != nil {
+			t.Fatalf("ReadTag failed: %v", err)
+		}
+	}
+	// Three requests at 50 per second take at least 40ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the reads to be rate limited, took %s", elapsed)
+	}
+}
+
+func TestReconnectingClient_BacksOffFailedReconnects(t *testing.T) {
+	first := &failingOPCClient{failures: []error{ua.BadSecureChannelClosed}}
+	dials := 0
+	dial := func() (OPCClient, error) {
+		dials++
+		return nil, ua.BadServerNotConnected
+	}
+	recovery := retry.Recovery{
+		Read:      retry.Policy{Attempts: 2},
+		Reconnect: retry.Backoff{Initial: time.Hour},
+	}
+	client := NewClientWithOPC(newReconnectingClient(first, dial, recovery, nil, 0))
+	tag := model.OPCTag{Name: "Flow", NodeID: "ns=4;s=Flow", DataType: "REAL"}
+
+	if _, err := client.ReadTag(context.Background(), tag); err == nil {
+		t.Fatal("Expected an error while the server is down")
+	}
+	if _, err := client.ReadTag(context.Background(), tag); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("Expected ErrDisconnected before the next attempt is due, got %v", err)
+	}
+	if dials != 1 {
+		t.Errorf("Expected one reconnect attempt, got %d", dials)
+	}
+}
+
+func TestClient_ReconnectsToRestartedServer(t *testing.T) {
+	tags := simulatorTags[:2]
+	start := func(address string) *Simulator {
+		t.Helper()
+		sim, err := NewSimulator(address)
+		if err != nil {
+			t.Fatalf("NewSimulator failed: %v", err)
+		}
+		if err := sim.Seed(tags, simulatorValues); err != nil {
+			t.Fatalf("Seed failed: %v", err)
+		}
+		if err := sim.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		return sim
+	}
+	sim := start("127.0.0.1:0")
+
+	var states []string
+	recovery := retry.DefaultRecovery()
+	recovery.Read.Backoff = retry.Backoff{Initial: 50 * time.Millisecond}
+	recovery.Reconnect = retry.Backoff{Initial: 10 * time.Millisecond}
+	recovery.OnState = func(state retry.State, err error) { states = append(states, string(state)) }
+
+	client, err := NewClient(sim.Endpoint(), WithRecovery(recovery))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ReadTag(context.Background(), tags[0]); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	sim.Stop()
+	sim = start(strings.TrimPrefix(sim.Endpoint(), "opc.tcp://"))
+	defer sim.Stop()
+
+	value, err := client.ReadTag(context.Background(), tags[1])
+	if err != nil {
+		t.Fatalf("Expected the read to reconnect, got: %v", err)
+	}
+	if value != simulatorValues[tags[1].Name] {
+		t.Errorf("Expected %v, got %v", simulatorValues[tags[1].Name], value)
+	}
+	if len(states) < 3 || states[len(states)-1] != string(retry.StateConnected) {
+		t.Errorf("Expected the client to report the reconnect, got %v", states)
+	}
+}
@@ -0,0 +1,235 @@
+package opcua
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awcullen/opcua/client"
+	"github.com/awcullen/opcua/ua"
+)
+
+// Security selects how the client secures its connection to the server and
+// which user it logs in as. The zero value connects like before: the most
+// secure endpoint usable without a client certificate, no server certificate
+// verification and an anonymous login.
+type Security struct {
+	Policy string // None, Basic256Sha256, Aes128_Sha256_RsaOaep, ...; empty for the best available
+	Mode   string // None, Sign or SignAndEncrypt; empty for any
+
+	// Instead of a fixed policy and mode, the endpoint can be picked from
+	// the server's endpoints by preference, see SelectEndpoint
+	PreferPolicies []string
+	PreferModes    []string
+
+	// Client certificate and key in PEM files. When they are not given and the
+	// policy needs them, a self-signed pair is generated in PKIDir and reused.
+	CertFile string
+	KeyFile  string
+	PKIDir   string // empty for DefaultPKIDir
+
+	// TrustedCerts holds the trusted server certificates: a file, a comma
+	// separated list of files or a directory. Untrusted certificates are
+	// copied to PKIDir/rejected. Empty skips server certificate verification.
+	TrustedCerts string
+
+	// User identity, anonymous when neither is set
+	UserName     string
+	Password     string
+	UserCertFile string
+	UserKeyFile  string
+}
+
+// securityPolicies maps the short policy names to their URIs
+var securityPolicies = map[string]string{
+	"none":                  ua.SecurityPolicyURINone,
+	"basic128rsa15":         ua.SecurityPolicyURIBasic128Rsa15,
+	"basic256":              ua.SecurityPolicyURIBasic256,
+	"basic256sha256":        ua.SecurityPolicyURIBasic256Sha256,
+	"aes128_sha256_rsaoaep": ua.SecurityPolicyURIAes128Sha256RsaOaep,
+	"aes128":                ua.SecurityPolicyURIAes128Sha256RsaOaep,
+	"aes256_sha256_rsapss":  ua.SecurityPolicyURIAes256Sha256RsaPss,
+	"aes256":                ua.SecurityPolicyURIAes256Sha256RsaPss,
+}
+
+// ParseSecurityPolicy returns the URI of a security policy given by its short
+// name, like Basic256Sha256 or Aes256, or by its URI. Empty selects the best
+// available policy.
+func ParseSecurityPolicy(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "http://opcfoundation.org/UA/SecurityPolicy#") {
+		return s, nil
+	}
+	if uri, ok := securityPolicies[strings.ToLower(s)]; ok {
+		return uri, nil
+	}
+	return "", fmt.Errorf("unknown security policy %q (expected None, Basic256Sha256, Aes128 or Aes256)", s)
+}
+
+// SecurityPolicyName returns the short name of a security policy URI
+func SecurityPolicyName(uri string) string {
+	if name, ok := strings.CutPrefix(uri, "http://opcfoundation.org/UA/SecurityPolicy#"); ok {
+		return name
+	}
+	return uri
+}
+
+// ParseSecurityMode reads None, Sign or SignAndEncrypt. Empty accepts any mode.
+func ParseSecurityMode(s string) (ua.MessageSecurityMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return ua.MessageSecurityModeInvalid, nil
+	case "none":
+		return ua.MessageSecurityModeNone, nil
+	case "sign":
+		return ua.MessageSecurityModeSign, nil
+	case "signandencrypt", "sign-and-encrypt", "sign_and_encrypt":
+		return ua.MessageSecurityModeSignAndEncrypt, nil
+	default:
+		return ua.MessageSecurityModeInvalid, fmt.Errorf("unknown security mode %q (expected None, Sign or SignAndEncrypt)", s)
+	}
+}
+
+// DefaultPKIDir is where generated client certificates are kept when no
+// directory is configured
+func DefaultPKIDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "opcmss", "pki")
+}
+
+// Validate reports settings that cannot work together, without touching any file
+func (s Security) Validate() error {
+	if _, err := ParseSecurityPolicy(s.Policy); err != nil {
+		return err
+	}
+	if _, err := ParseSecurityMode(s.Mode); err != nil {
+		return err
+	}
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return errors.New("client certificate and key must be given together")
+	}
+	if (s.UserCertFile == "") != (s.UserKeyFile == "") {
+		return errors.New("user certificate and key must be given together")
+	}
+	if s.UserName != "" && s.UserCertFile != "" {
+		return errors.New("log in with either a user name or a user certificate, not both")
+	}
+	if s.discovers() && (s.Policy != "" || s.Mode != "") {
+		return errors.New("set either a security policy and mode or endpoint preferences, not both")
+	}
+	return nil
+}
+
+// discovers reports whether the endpoint is picked by preference
+func (s Security) discovers() bool {
+	return len(s.PreferPolicies) > 0 || len(s.PreferModes) > 0
+}
+
+// Preferences returns the endpoint preferences equivalent to the settings:
+// the preference lists if set, otherwise the fixed policy and mode. Without
+// either only the None policy is usable unless a client certificate is given.
+func (s Security) Preferences() EndpointPreferences {
+	prefs := EndpointPreferences{Policies: s.PreferPolicies, Modes: s.PreferModes, UserToken: s.userToken()}
+	if !s.discovers() {
+		prefs.Policies, prefs.Modes = nil, nil
+		if s.Policy != "" {
+			prefs.Policies = []string{s.Policy}
+		} else if s.CertFile == "" {
+			prefs.Policies = []string{"None"}
+		}
+		if s.Mode != "" {
+			prefs.Modes = []string{s.Mode}
+		}
+	}
+	return prefs
+}
+
+func (s Security) userToken() ua.UserTokenType {
+	switch {
+	case s.UserName != "":
+		return ua.UserTokenTypeUserName
+	case s.UserCertFile != "":
+		return ua.UserTokenTypeCertificate
+	default:
+		return ua.UserTokenTypeAnonymous
+	}
+}
+
+func (s Security) pkiDir() string {
+	if s.PKIDir == "" {
+		return DefaultPKIDir()
+	}
+	return s.PKIDir
+}
+
+// dialOptions translates the settings into client options, generating the
+// client certificate if one is needed
+func (s Security) dialOptions() ([]client.Option, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	policy, _ := ParseSecurityPolicy(s.Policy)
+	mode, _ := ParseSecurityMode(s.Mode)
+
+	opts := []client.Option{client.WithSecurityPolicyURI(policy, mode)}
+
+	switch {
+	case s.CertFile != "":
+		opts = append(opts, client.WithClientCertificatePaths(s.CertFile, s.KeyFile))
+	case policy != ua.SecurityPolicyURIBestAvailable && policy != ua.SecurityPolicyURINone:
+		certFile, keyFile, err := s.ensureCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client certificate: %w", err)
+		}
+		opts = append(opts, client.WithClientCertificatePaths(certFile, keyFile))
+	}
+
+	if s.TrustedCerts != "" {
+		rejected := filepath.Join(s.pkiDir(), "rejected")
+		if err := os.MkdirAll(rejected, 0755); err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			client.WithTrustedCertificatesPaths(s.TrustedCerts, ""),
+			client.WithRejectedCertificatesPath(rejected))
+	} else {
+		opts = append(opts, client.WithInsecureSkipVerify())
+	}
+
+	switch {
+	case s.UserName != "":
+		opts = append(opts, client.WithUserNameIdentity(s.UserName, s.Password))
+	case s.UserCertFile != "":
+		opts = append(opts, client.WithX509IdentityPaths(s.UserCertFile, s.UserKeyFile))
+	}
+	return opts, nil
+}
+
+// ensureCertificate returns the generated client certificate in the PKI
+// directory, creating it on first use
+func (s Security) ensureCertificate() (certFile, keyFile string, err error) {
+	dir := filepath.Join(s.pkiDir(), "own")
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	if _, err := os.Stat(certFile); err == nil {
+		return certFile, keyFile, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	if err := writeCertificate(certFile, keyFile, "opcmss", "urn:"+host+":opcmss"); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
@@ -0,0 +1,168 @@
+package opcua
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+func TestParseSecurityPolicy(t *testing.T) {
+	valid := map[string]string{
+		"":                                 ua.SecurityPolicyURIBestAvailable,
+		"None":                             ua.SecurityPolicyURINone,
+		"basic256sha256":                   ua.SecurityPolicyURIBasic256Sha256,
+		"Aes128":                           ua.SecurityPolicyURIAes128Sha256RsaOaep,
+		"Aes256_Sha256_RsaPss":             ua.SecurityPolicyURIAes256Sha256RsaPss,
+		ua.SecurityPolicyURIBasic256Sha256: ua.SecurityPolicyURIBasic256Sha256,
+	}
+	for in, want := range valid {
+		got, err := ParseSecurityPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSecurityPolicy(%q) = %q, %v; expected %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseSecurityPolicy("Rot13"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+
+	if name := SecurityPolicyName(ua.SecurityPolicyURIAes256Sha256RsaPss); name != "Aes256_Sha256_RsaPss" {
+		t.Errorf("Expected the short policy name, got %q", name)
+	}
+}
+
+func TestParseSecurityMode(t *testing.T) {
+	valid := map[string]ua.MessageSecurityMode{
+		"":               ua.MessageSecurityModeInvalid,
+		"none":           ua.MessageSecurityModeNone,
+		"Sign":           ua.MessageSecurityModeSign,
+		"SignAndEncrypt": ua.MessageSecurityModeSignAndEncrypt,
+	}
+	for in, want := range valid {
+		got, err := ParseSecurityMode(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSecurityMode(%q) = %v, %v; expected %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseSecurityMode("encrypt"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestSecurity_Validate(t *testing.T) {
+	invalid := map[string]Security{
+		"cert without key":      {CertFile: "client.pem"},
+		"user cert without key": {UserCertFile: "user.pem"},
+		"two identities":        {UserName: "op", UserCertFile: "user.pem", UserKeyFile: "user.key"},
+		"bad mode":              {Mode: "Encrypt"},
+	}
+	for name, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestClient_SecureConnection(t *testing.T) {
+	sim := startSimulator(t)
+	pki := t.TempDir()
+	security := Security{Policy: "Basic256Sha256", Mode: "SignAndEncrypt", PKIDir: pki}
+
+	client, err := NewClient(sim.Endpoint(), WithSecurity(security))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	value, err := client.ReadTag(context.Background(), simulatorTags[0])
+	client.Close()
+	if err != nil || value != simulatorValues["Pump"] {
+		t.Errorf("Expected to read %v over an encrypted channel, got %v, %v", simulatorValues["Pump"], value, err)
+	}
+
+	certFile := filepath.Join(pki, "own", "cert.pem")
+	before, err := os.Stat(certFile)
+	if err != nil {
+		t.Fatalf("Expected a generated client certificate: %v", err)
+	}
+
+	// The generated certificate is reused on the next connection
+	client, err = NewClient(sim.Endpoint(), WithSecurity(security))
+	if err != nil {
+		t.Fatalf("Second NewClient failed: %v", err)
+	}
+	client.Close()
+	if after, _ := os.Stat(certFile); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("Expected the client certificate to be reused")
+	}
+}
+
+func TestClient_TrustedCertificates(t *testing.T) {
+	sim := startSimulator(t)
+	pki := t.TempDir()
+	trusted := filepath.Join(pki, "trusted")
+	if err := os.MkdirAll(trusted, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	security := Security{Policy: "Basic256Sha256", PKIDir: pki, TrustedCerts: trusted}
+	if client, err := NewClient(sim.Endpoint(), WithSecurity(security)); err == nil {
+		client.Close()
+		t.Fatal("Expected an untrusted server certificate to be rejected")
+	}
+	if rejected, _ := os.ReadDir(filepath.Join(pki, "rejected")); len(rejected) == 0 {
+		t.Error("Expected the server certificate to be stored as rejected")
+	}
+
+	serverCert, err := os.ReadFile(filepath.Join(sim.pkiDir, "server.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(trusted, "simulator.crt"), serverCert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(sim.Endpoint(), WithSecurity(security))
+	if err != nil {
+		t.Fatalf("Expected the trusted server to be accepted, got: %v", err)
+	}
+	client.Close()
+}
+
+func TestClient_UserNameLogin(t *testing.T) {
+	sim, err := NewSimulator("127.0.0.1:0", WithSimulatorUser("operator", "secret"))
+	if err != nil {
+		t.Fatalf("NewSimulator failed: %v", err)
+	}
+	tag := simulatorTags[1]
+	if err := sim.Seed(simulatorTags[:2], simulatorValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Stop()
+
+	if client, err := NewClient(sim.Endpoint()); err == nil {
+		client.Close()
+		t.Error("Expected an anonymous login to be refused")
+	}
+	if client, err := NewClient(sim.Endpoint(), WithSecurity(Security{UserName: "operator", Password: "wrong"})); err == nil {
+		client.Close()
+		t.Error("Expected a wrong password to be refused")
+	}
+
+	client, err := NewClient(sim.Endpoint(), WithSecurity(Security{
+		Policy: "Basic256Sha256", PKIDir: t.TempDir(), UserName: "operator", Password: "secret",
+	}))
+	if err != nil {
+		t.Fatalf("Expected the login to succeed, got: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteTag(context.Background(), tag, int16(7)); err != nil {
+		t.Errorf("Expected an authenticated user to write, got: %v", err)
+	}
+	if value, _ := client.ReadTag(context.Background(), tag); value != int16(7) {
+		t.Errorf("Expected 7 after the write, got %v", value)
+	}
+}
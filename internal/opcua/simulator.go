@@ -0,0 +1,457 @@
+package opcua
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"opcmss/internal/model"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// Simulator is an in-process OPC UA server that exposes one variable per tag
+// under the tag's NodeID, so the client can be exercised without a PLC. Like a
+// PLC's server, the dotted parts of the NodeIDs become nested folders below
+// Objects. It offers every security policy, trusts any client certificate and
+// accepts anonymous logins unless a user is set.
+type Simulator struct {
+	server   *server.Server
+	endpoint string
+	pkiDir   string
+	done     chan error
+
+	mu      sync.RWMutex
+	nodes   map[string]*server.VariableNode // by NodeID
+	folders map[string]bool                 // by NodeID
+}
+
+// SimulatorOption configures a Simulator
+type SimulatorOption func(*simulatorConfig)
+
+type simulatorConfig struct {
+	maxNodesPerRead uint32
+	user, password  string
+}
+
+// WithSimulatorMaxNodesPerRead sets the MaxNodesPerRead operation limit the
+// simulator advertises and enforces instead of the server's default.
+func WithSimulatorMaxNodesPerRead(n uint32) SimulatorOption {
+	return func(c *simulatorConfig) {
+		c.maxNodesPerRead = n
+	}
+}
+
+// WithSimulatorUser requires clients to log in with this user name and
+// password instead of anonymously
+func WithSimulatorUser(user, password string) SimulatorOption {
+	return func(c *simulatorConfig) {
+		c.user, c.password = user, password
+	}
+}
+
+// NewSimulator creates a server for address (host:port). Port 0 picks a free
+// port, see Endpoint. A throwaway self-signed certificate is generated for it.
+func NewSimulator(address string, opts ...SimulatorOption) (*Simulator, error) {
+	var cfg simulatorConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	address, err := resolvePort(address)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := "opc.tcp://" + address
+
+	pkiDir, err := os.MkdirTemp("", "opcmss-simulator")
+	if err != nil {
+		return nil, err
+	}
+	certFile := filepath.Join(pkiDir, "server.crt")
+	keyFile := filepath.Join(pkiDir, "server.key")
+	applicationURI := "urn:opcmss:simulator"
+	if err := writeCertificate(certFile, keyFile, "opcmss simulator", applicationURI); err != nil {
+		os.RemoveAll(pkiDir)
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	capabilities := ua.NewServerCapabilities()
+	if cfg.maxNodesPerRead != 0 {
+		capabilities.OperationLimits.MaxNodesPerRead = cfg.maxNodesPerRead
+	}
+
+	serverOpts := []server.Option{
+		server.WithServerCapabilities(capabilities),
+		server.WithSecurityPolicyNone(true),
+		server.WithInsecureSkipVerify(),
+		server.WithServerDiagnostics(false),
+	}
+	if cfg.user != "" {
+		serverOpts = append(serverOpts,
+			server.WithAnonymousIdentity(false),
+			server.WithAuthenticateUserNameIdentityFunc(func(id ua.UserNameIdentity, applicationURI, endpointURL string) error {
+				if id.UserName != cfg.user || id.Password != cfg.password {
+					return ua.BadUserAccessDenied
+				}
+				return nil
+			}))
+	} else {
+		serverOpts = append(serverOpts, server.WithAnonymousIdentity(true))
+	}
+
+	srv, err := server.New(
+		ua.ApplicationDescription{
+			ApplicationURI:  applicationURI,
+			ProductURI:      "urn:opcmss",
+			ApplicationName: ua.LocalizedText{Text: "opcmss simulator", Locale: "en"},
+			ApplicationType: ua.ApplicationTypeServer,
+			DiscoveryURLs:   []string{endpoint},
+		},
+		certFile,
+		keyFile,
+		endpoint,
+		serverOpts...,
+	)
+	if err != nil {
+		os.RemoveAll(pkiDir)
+		return nil, err
+	}
+
+	return &Simulator{
+		server:   srv,
+		endpoint: endpoint,
+		pkiDir:   pkiDir,
+		nodes:    make(map[string]*server.VariableNode),
+		folders:  make(map[string]bool),
+	}, nil
+}
+
+// resolvePort replaces port 0 with a free port, as the server needs to know
+// its endpoint URL before it listens
+func resolvePort(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if port != "0" {
+		return address, nil
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// Endpoint returns the opc.tcp URL clients connect to
+func (s *Simulator) Endpoint() string {
+	return s.endpoint
+}
+
+// Start serves the address space in the background and waits until the
+// server accepts connections
+func (s *Simulator) Start() error {
+	if s.done != nil {
+		return fmt.Errorf("simulator already started on %s", s.endpoint)
+	}
+	s.done = make(chan error, 1)
+	go func() {
+		s.done <- s.server.ListenAndServe()
+	}()
+
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", u.Host, 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case err := <-s.done:
+			return fmt.Errorf("failed to start OPC UA server: %w", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("OPC UA server did not start listening on %s", u.Host)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Stop shuts the server down and removes its certificate. Connected clients
+// get a few seconds to disconnect.
+func (s *Simulator) Stop() error {
+	err := s.server.Close()
+	if s.done != nil {
+		<-s.done
+	}
+	os.RemoveAll(s.pkiDir)
+	return err
+}
+
+// Seed adds a variable for every tag, with its value from values keyed by tag
+// name, or the zero value of its data type. Namespaces up to the highest index
+// used by the tags are created as needed.
+func (s *Simulator) Seed(tags []model.OPCTag, values map[string]any) error {
+	nm := s.server.NamespaceManager()
+	nodes := make([]server.Node, 0, len(tags))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		nodeID := ua.ParseNodeID(tag.NodeID)
+		id, ok := nodeID.(ua.NodeIDString)
+		if !ok {
+			return fmt.Errorf("tag %s: unsupported NodeID %s", tag.Name, tag.NodeID)
+		}
+		if _, ok := s.nodes[tag.NodeID]; ok {
+			continue // the tag map has duplicate names
+		}
+		for nm.Len() <= int(id.NamespaceIndex) {
+			nm.Add(fmt.Sprintf("urn:opcmss:simulator:ns%d", nm.Len()))
+		}
+		parent, browseName, err := s.folder(id, &nodes)
+		if err != nil {
+			return fmt.Errorf("tag %s: %w", tag.Name, err)
+		}
+
+		dataType, err := dataTypeNodeID(tag.DataType)
+		if err != nil {
+			return fmt.Errorf("tag %s: %w", tag.Name, err)
+		}
+		value, ok := values[tag.Name]
+		if !ok {
+			value = zeroValue(tag.DataType)
+		}
+		if err := checkValueType(tag, value); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		node := server.NewVariableNode(
+			s.server,
+			nodeID,
+			ua.NewQualifiedName(id.NamespaceIndex, browseName),
+			ua.NewLocalizedText(browseName, ""),
+			ua.NewLocalizedText(tag.Description, ""),
+			tagRolePermissions,
+			[]ua.Reference{
+				ua.NewReference(ua.ReferenceTypeIDHasTypeDefinition, false, ua.NewExpandedNodeID(ua.VariableTypeIDBaseDataVariableType)),
+				ua.NewReference(ua.ReferenceTypeIDOrganizes, true, ua.NewExpandedNodeID(parent)),
+			},
+			ua.NewDataValue(value, ua.Good, now, 0, now, 0),
+			dataType,
+			ua.ValueRankScalar,
+			nil,
+			ua.AccessLevelsCurrentRead|ua.AccessLevelsCurrentWrite,
+			0,
+			false,
+			nil,
+		)
+		s.nodes[tag.NodeID] = node
+		nodes = append(nodes, node)
+	}
+	return nm.AddNodes(nodes...)
+}
+
+// folder returns the folder the variable with id is organised under and the
+// variable's browse name. The folders for the dotted parts of id are created
+// as needed and appended to nodes.
+func (s *Simulator) folder(id ua.NodeIDString, nodes *[]server.Node) (ua.NodeID, string, error) {
+	if s.folders[id.String()] {
+		return nil, "", fmt.Errorf("%s is already a folder", id)
+	}
+
+	var parent ua.NodeID = ua.ObjectIDObjectsFolder
+	parts := strings.Split(id.ID, ".")
+	for i, part := range parts[:len(parts)-1] {
+		folderID := ua.NewNodeIDString(id.NamespaceIndex, strings.Join(parts[:i+1], "."))
+		key := folderID.String()
+		if _, ok := s.nodes[key]; ok {
+			return nil, "", fmt.Errorf("folder %s is already a tag", key)
+		}
+		if !s.folders[key] {
+			*nodes = append(*nodes, server.NewObjectNode(
+				s.server,
+				folderID,
+				ua.NewQualifiedName(id.NamespaceIndex, part),
+				ua.NewLocalizedText(part, ""),
+				ua.NewLocalizedText("", ""),
+				folderRolePermissions,
+				[]ua.Reference{
+					ua.NewReference(ua.ReferenceTypeIDHasTypeDefinition, false, ua.NewExpandedNodeID(ua.ObjectTypeIDFolderType)),
+					ua.NewReference(ua.ReferenceTypeIDOrganizes, true, ua.NewExpandedNodeID(parent)),
+				},
+				0,
+			))
+			s.folders[key] = true
+		}
+		parent = folderID
+	}
+	return parent, parts[len(parts)-1], nil
+}
+
+// folderRolePermissions lets every client browse the folders
+var folderRolePermissions = []ua.RolePermissionType{
+	{RoleID: ua.ObjectIDWellKnownRoleAnonymous, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead},
+	{RoleID: ua.ObjectIDWellKnownRoleAuthenticatedUser, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead},
+}
+
+// tagRolePermissions lets every client, including anonymous ones, write tags
+var tagRolePermissions = []ua.RolePermissionType{
+	{RoleID: ua.ObjectIDWellKnownRoleAnonymous, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead | ua.PermissionTypeWrite},
+	{RoleID: ua.ObjectIDWellKnownRoleAuthenticatedUser, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead | ua.PermissionTypeWrite},
+}
+
+// SetValue changes the value of a seeded tag, as if the PLC had updated it
+func (s *Simulator) SetValue(tag model.OPCTag, value any) error {
+	node, err := s.node(tag)
+	if err != nil {
+		return err
+	}
+	if err := checkValueType(tag, value); err != nil {
+		return err
+	}
+	now := time.Now()
+	node.SetValue(ua.NewDataValue(value, ua.Good, now, 0, now, 0))
+	return nil
+}
+
+// Value returns the current value of a seeded tag, including client writes
+func (s *Simulator) Value(tag model.OPCTag) (any, error) {
+	node, err := s.node(tag)
+	if err != nil {
+		return nil, err
+	}
+	return node.Value().Value, nil
+}
+
+func (s *Simulator) node(tag model.OPCTag) (*server.VariableNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	node, ok := s.nodes[tag.NodeID]
+	if !ok {
+		return nil, fmt.Errorf("tag %s: %s is not in the simulator", tag.Name, tag.NodeID)
+	}
+	return node, nil
+}
+
+// dataTypeNodeID returns the OPC UA built-in type for an IEC data type
+func dataTypeNodeID(dataType string) (ua.NodeID, error) {
+	switch dataType {
+	case model.TypeBOOL:
+		return ua.DataTypeIDBoolean, nil
+	case model.TypeINT:
+		return ua.DataTypeIDInt16, nil
+	case model.TypeUINT, model.TypeWORD:
+		return ua.DataTypeIDUInt16, nil
+	case model.TypeDINT:
+		return ua.DataTypeIDInt32, nil
+	case model.TypeUDINT, model.TypeDWORD:
+		return ua.DataTypeIDUInt32, nil
+	case model.TypeLINT:
+		return ua.DataTypeIDInt64, nil
+	case model.TypeREAL:
+		return ua.DataTypeIDFloat, nil
+	case model.TypeLREAL:
+		return ua.DataTypeIDDouble, nil
+	}
+	if model.IsString(dataType) {
+		return ua.DataTypeIDString, nil
+	}
+	return nil, fmt.Errorf("unsupported data type: %s", dataType)
+}
+
+// zeroValue returns the zero value of the Go type used for an IEC data type
+func zeroValue(dataType string) any {
+	switch dataType {
+	case model.TypeBOOL:
+		return false
+	case model.TypeINT:
+		return int16(0)
+	case model.TypeUINT, model.TypeWORD:
+		return uint16(0)
+	case model.TypeDINT:
+		return int32(0)
+	case model.TypeUDINT, model.TypeDWORD:
+		return uint32(0)
+	case model.TypeLINT:
+		return int64(0)
+	case model.TypeREAL:
+		return float32(0)
+	case model.TypeLREAL:
+		return float64(0)
+	default:
+		return ""
+	}
+}
+
+// checkValueType makes sure a value has the Go type the tag's variable holds,
+// so the server never serves a different type than it advertises
+func checkValueType(tag model.OPCTag, value any) error {
+	expected := zeroValue(tag.DataType)
+	if fmt.Sprintf("%T", value) != fmt.Sprintf("%T", expected) {
+		return fmt.Errorf("tag %s: expected a %T value for %s, got %T", tag.Name, expected, tag.DataType, value)
+	}
+	return nil
+}
+
+// writeCertificate creates a self-signed certificate and key in PEM files
+func writeCertificate(certFile, keyFile, commonName, applicationURI string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	uri, err := url.Parse(applicationURI)
+	if err != nil {
+		return err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		URIs:                  []*url.URL{uri},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+}
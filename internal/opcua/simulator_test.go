@@ -0,0 +1,164 @@
+package opcua
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+// The simulator takes a few seconds to shut down, so the tests share one
+var (
+	sharedSimulator     *Simulator
+	sharedSimulatorErr  error
+	sharedSimulatorOnce sync.Once
+)
+
+// simulatorTags covers every data type the client converts
+var simulatorTags = []model.OPCTag{
+	{Name: "Pump", NodeID: "ns=4;s=Plant.Pump", DataType: "BOOL", Description: "Feed pump running"},
+	{Name: "Setpoint", NodeID: "ns=4;s=Plant.Setpoint", DataType: "INT"},
+	{Name: "Mask", NodeID: "ns=4;s=Plant.Mask", DataType: "WORD"},
+	{Name: "Position", NodeID: "ns=4;s=Plant.Position", DataType: "DINT"},
+	{Name: "Counter", NodeID: "ns=4;s=Plant.Counter", DataType: "UDINT"},
+	{Name: "Total", NodeID: "ns=4;s=Plant.Total", DataType: "LINT"},
+	{Name: "Flow", NodeID: "ns=4;s=Plant.Flow", DataType: "REAL"},
+	{Name: "Energy", NodeID: "ns=4;s=Plant.Energy", DataType: "LREAL"},
+	{Name: "Label", NodeID: "ns=4;s=Plant.Label", DataType: "STRING[10]"},
+	{Name: "TestReal", NodeID: "ns=2;s=Test.RealValue", DataType: "REAL"},
+}
+
+var simulatorValues = map[string]any{
+	"Pump":     true,
+	"Setpoint": int16(-42),
+	"Mask":     uint16(0xBEEF),
+	"Position": int32(-100000),
+	"Counter":  uint32(4000000000),
+	"Total":    int64(1) << 40,
+	"Flow":     float32(12.5),
+	"Energy":   float64(1234.5678),
+	"Label":    "PUMP-1",
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if sharedSimulator != nil {
+		sharedSimulator.Stop()
+	}
+	os.Exit(code)
+}
+
+// startSimulator returns the shared simulator seeded with simulatorTags
+func startSimulator(t *testing.T) *Simulator {
+	t.Helper()
+
+	sharedSimulatorOnce.Do(func() {
+		sim, err := NewSimulator("127.0.0.1:0", WithSimulatorMaxNodesPerRead(4))
+		if err != nil {
+			sharedSimulatorErr = err
+			return
+		}
+		if err := sim.Seed(simulatorTags, simulatorValues); err != nil {
+			sharedSimulatorErr = err
+			return
+		}
+		if err := sim.Start(); err != nil {
+			sharedSimulatorErr = err
+			return
+		}
+		sharedSimulator = sim
+	})
+	if sharedSimulatorErr != nil {
+		t.Fatalf("Failed to start simulator: %v", sharedSimulatorErr)
+	}
+	return sharedSimulator
+}
+
+func dialSimulator(t *testing.T) (*Simulator, *Client) {
+	t.Helper()
+
+	sim := startSimulator(t)
+	client, err := NewClient(sim.Endpoint())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return sim, client
+}
+
+func TestSimulator_ReadTag(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	for _, tag := range simulatorTags {
+		expected, ok := simulatorValues[tag.Name]
+		if !ok {
+			continue
+		}
+		value, err := client.ReadTag(context.Background(), tag)
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %v", tag.Name, err)
+			continue
+		}
+		if value != expected {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", tag.Name, expected, expected, value, value)
+		}
+	}
+}
+
+func TestSimulator_ReadTagsHonoursLimit(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	if limit := client.MaxNodesPerRead(context.Background()); limit != 4 {
+		t.Errorf("Expected MaxNodesPerRead 4, got %d", limit)
+	}
+
+	values := client.ReadTags(context.Background(), simulatorTags)
+	if len(values) != len(simulatorTags) {
+		t.Fatalf("Expected %d values, got %d", len(simulatorTags), len(values))
+	}
+	for i, tag := range simulatorTags {
+		if values[i].Err != nil {
+			t.Errorf("%s: expected no error, got: %v", tag.Name, values[i].Err)
+			continue
+		}
+		if expected, ok := simulatorValues[tag.Name]; ok && values[i].Value != expected {
+			t.Errorf("%s: expected %v, got %v", tag.Name, expected, values[i].Value)
+		}
+		if values[i].SourceTimestamp.IsZero() {
+			t.Errorf("%s: expected a source timestamp", tag.Name)
+		}
+	}
+}
+
+func TestSimulator_UnknownNode(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	tag := model.OPCTag{Name: "Missing", NodeID: "ns=4;s=Plant.Missing", DataType: "INT"}
+	if _, err := client.ReadTag(context.Background(), tag); err == nil {
+		t.Error("Expected an error reading an unknown node")
+	}
+}
+
+func TestSimulator_SetValue(t *testing.T) {
+	sim, client := dialSimulator(t)
+
+	tag := simulatorTags[1]
+	if err := sim.SetValue(tag, int16(7)); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	defer sim.SetValue(tag, simulatorValues[tag.Name])
+
+	value, err := client.ReadTag(context.Background(), tag)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if value != int16(7) {
+		t.Errorf("Expected 7, got %v (%T)", value, value)
+	}
+
+	if err := sim.SetValue(tag, float32(1)); err == nil {
+		t.Error("Expected an error setting a value of the wrong type")
+	}
+}
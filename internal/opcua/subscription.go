@@ -0,0 +1,335 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"opcmss/internal/model"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// Subscriber is implemented by OPC clients that support subscriptions
+type Subscriber interface {
+	CreateSubscription(ctx context.Context, request *ua.CreateSubscriptionRequest) (*ua.CreateSubscriptionResponse, error)
+	CreateMonitoredItems(ctx context.Context, request *ua.CreateMonitoredItemsRequest) (*ua.CreateMonitoredItemsResponse, error)
+	Publish(ctx context.Context, request *ua.PublishRequest) (*ua.PublishResponse, error)
+	DeleteSubscriptions(ctx context.Context, request *ua.DeleteSubscriptionsRequest) (*ua.DeleteSubscriptionsResponse, error)
+}
+
+// MaxMonitoredItemsPerCall is the number of monitored items created per
+// CreateMonitoredItems request
+const MaxMonitoredItemsPerCall = 1000
+
+// SubscriptionOptions configures a subscription. Zero values pick the defaults.
+type SubscriptionOptions struct {
+	PublishingInterval time.Duration // how often the server sends changes, default 1s
+	SamplingInterval   time.Duration // how often the server samples each tag, default the publishing interval
+	QueueSize          uint32        // changes kept per tag between publishes, default 1
+	Deadband           float64       // absolute deadband for numeric tags, 0 reports every change
+	Buffer             int           // capacity of the Changes channel, default 100
+}
+
+func (o SubscriptionOptions) withDefaults() SubscriptionOptions {
+	if o.PublishingInterval <= 0 {
+		o.PublishingInterval = time.Second
+	}
+	if o.SamplingInterval <= 0 {
+		o.SamplingInterval = o.PublishingInterval
+	}
+	if o.QueueSize == 0 {
+		o.QueueSize = 1
+	}
+	if o.Buffer <= 0 {
+		o.Buffer = 100
+	}
+	return o
+}
+
+// DataChange is a new value for a subscribed tag
+type DataChange struct {
+	Index           int // position of the tag in the list passed to Subscribe
+	Tag             model.OPCTag
+	Value           any
+	StatusCode      ua.StatusCode
+	Err             error
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+	ReceivedAt      time.Time
+}
+
+// Subscription delivers data changes for a set of tags until it is closed
+type Subscription struct {
+	client  Subscriber
+	id      uint32
+	tags    []model.OPCTag
+	changes chan DataChange
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+	owner   *Client
+}
+
+// Subscribe creates a subscription with one monitored item per tag. The
+// server sends the current value of every tag first and then only changes.
+// Tags the server rejects are reported once on the channel with an error.
+// A client supports one subscription at a time. It ends when ctx is done, the
+// connection fails or it is closed, and the client can subscribe again.
+func (c *Client) Subscribe(ctx context.Context, tags []model.OPCTag, opts SubscriptionOptions) (*Subscription, error) {
+	subscriber, ok := c.client.(Subscriber)
+	if !ok {
+		return nil, fmt.Errorf("OPC client does not support subscriptions")
+	}
+	if c.active() != nil {
+		return nil, errSubscribed
+	}
+	opts = opts.withDefaults()
+
+	createCtx, cancel := c.request(ctx)
+	defer cancel()
+	res, err := subscriber.CreateSubscription(createCtx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: float64(opts.PublishingInterval.Milliseconds()),
+		RequestedMaxKeepAliveCount:  10,
+		RequestedLifetimeCount:      30,
+		PublishingEnabled:           true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	s := &Subscription{
+		client:  subscriber,
+		id:      res.SubscriptionID,
+		tags:    tags,
+		changes: make(chan DataChange, opts.Buffer),
+		done:    make(chan struct{}),
+		owner:   c,
+	}
+
+	rejected, err := s.createMonitoredItems(createCtx, opts)
+	if err != nil {
+		s.delete()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.subscription != nil {
+		c.mu.Unlock()
+		s.delete()
+		return nil, errSubscribed
+	}
+	c.subscription = s
+	c.mu.Unlock()
+
+	publishCtx, stop := context.WithCancel(ctx)
+	s.cancel = stop
+	go s.publish(publishCtx, rejected)
+	return s, nil
+}
+
+var errSubscribed = errors.New("client already has an active subscription")
+
+// active returns the client's subscription, nil when it has none
+func (c *Client) active() *Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscription
+}
+
+// release forgets s once it has ended, so the client can subscribe again
+func (c *Client) release(s *Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subscription == s {
+		c.subscription = nil
+	}
+}
+
+// createMonitoredItems adds the tags in chunks and returns the ones the
+// server rejected. The client handle of each item is its index in tags.
+func (s *Subscription) createMonitoredItems(ctx context.Context, opts SubscriptionOptions) ([]DataChange, error) {
+	var rejected []DataChange
+	for start := 0; start < len(s.tags); start += MaxMonitoredItemsPerCall {
+		end := min(start+MaxMonitoredItemsPerCall, len(s.tags))
+
+		items := make([]ua.MonitoredItemCreateRequest, 0, end-start)
+		for i := start; i < end; i++ {
+			items = append(items, ua.MonitoredItemCreateRequest{
+				ItemToMonitor: ua.ReadValueID{
+					NodeID:      ua.ParseNodeID(s.tags[i].NodeID),
+					AttributeID: ua.AttributeIDValue,
+				},
+				MonitoringMode: ua.MonitoringModeReporting,
+				RequestedParameters: ua.MonitoringParameters{
+					ClientHandle:     uint32(i),
+					SamplingInterval: float64(opts.SamplingInterval.Milliseconds()),
+					Filter:           dataChangeFilter(s.tags[i], opts.Deadband),
+					QueueSize:        opts.QueueSize,
+					DiscardOldest:    true,
+				},
+			})
+		}
+
+		res, err := s.client.CreateMonitoredItems(ctx, &ua.CreateMonitoredItemsRequest{
+			SubscriptionID:     s.id,
+			TimestampsToReturn: ua.TimestampsToReturnBoth,
+			ItemsToCreate:      items,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create monitored items: %w", err)
+		}
+		if len(res.Results) != len(items) {
+			return nil, fmt.Errorf("expected %d monitored items, got %d", len(items), len(res.Results))
+		}
+
+		for i, result := range res.Results {
+			if !result.StatusCode.IsGood() {
+				index := start + i
+				rejected = append(rejected, DataChange{
+					Index:      index,
+					Tag:        s.tags[index],
+					StatusCode: result.StatusCode,
+					Err:        fmt.Errorf("monitored item rejected with status: %v", result.StatusCode),
+					ReceivedAt: time.Now(),
+				})
+			}
+		}
+	}
+	return rejected, nil
+}
+
+// dataChangeFilter applies the deadband to numeric tags only, as servers
+// refuse deadbands on booleans and strings
+func dataChangeFilter(tag model.OPCTag, deadband float64) ua.ExtensionObject {
+	if deadband <= 0 || tag.DataType == model.TypeBOOL || model.IsString(tag.DataType) {
+		return nil
+	}
+	return ua.DataChangeFilter{
+		Trigger:       ua.DataChangeTriggerStatusValue,
+		DeadbandType:  uint32(ua.DeadbandTypeAbsolute),
+		DeadbandValue: deadband,
+	}
+}
+
+// publish keeps a publish request outstanding and forwards the notifications
+// until the subscription is closed, ctx is done or the connection fails
+func (s *Subscription) publish(ctx context.Context, rejected []DataChange) {
+	defer close(s.done)
+	defer s.owner.release(s)
+	defer close(s.changes)
+
+	for _, change := range rejected {
+		if !s.send(ctx, change) {
+			return
+		}
+	}
+
+	var acks []ua.SubscriptionAcknowledgement
+	for {
+		res, err := s.client.Publish(ctx, &ua.PublishRequest{
+			RequestHeader:                ua.RequestHeader{TimeoutHint: 60000},
+			SubscriptionAcknowledgements: acks,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				s.err = fmt.Errorf("publish failed: %w", err)
+			}
+			return
+		}
+
+		acks = nil
+		if res.SubscriptionID != s.id {
+			continue
+		}
+		if len(res.NotificationMessage.NotificationData) > 0 {
+			acks = []ua.SubscriptionAcknowledgement{{SubscriptionID: s.id, SequenceNumber: res.NotificationMessage.SequenceNumber}}
+		}
+
+		receivedAt := time.Now()
+		for _, data := range res.NotificationMessage.NotificationData {
+			switch body := data.(type) {
+			case ua.DataChangeNotification:
+				for _, item := range body.MonitoredItems {
+					if int(item.ClientHandle) >= len(s.tags) {
+						continue
+					}
+					if !s.send(ctx, s.change(item, receivedAt)) {
+						return
+					}
+				}
+			case ua.StatusChangeNotification:
+				if !body.Status.IsGood() {
+					s.err = fmt.Errorf("subscription ended with status: %v", body.Status)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Subscription) change(item ua.MonitoredItemNotification, receivedAt time.Time) DataChange {
+	index := int(item.ClientHandle)
+	change := DataChange{
+		Index:           index,
+		Tag:             s.tags[index],
+		StatusCode:      item.Value.StatusCode,
+		SourceTimestamp: item.Value.SourceTimestamp,
+		ServerTimestamp: item.Value.ServerTimestamp,
+		ReceivedAt:      receivedAt,
+	}
+	if !item.Value.StatusCode.IsGood() {
+		change.Err = fmt.Errorf("read failed with status: %v", item.Value.StatusCode)
+	} else {
+		change.Value, change.Err = convertValue(change.Tag, item.Value.Value)
+	}
+	return change
+}
+
+// send delivers a change unless the subscription is being closed
+func (s *Subscription) send(ctx context.Context, change DataChange) bool {
+	select {
+	case s.changes <- change:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Changes returns the channel data changes are delivered on. It is closed
+// when the subscription ends, see Err.
+func (s *Subscription) Changes() <-chan DataChange {
+	return s.changes
+}
+
+// Err returns the error that ended the subscription, nil after Close
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close stops delivering changes and deletes the subscription on the server
+func (s *Subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return s.delete()
+}
+
+func (s *Subscription) delete() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	res, err := s.client.DeleteSubscriptions(ctx, &ua.DeleteSubscriptionsRequest{SubscriptionIDs: []uint32{s.id}})
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	if len(res.Results) > 0 && !res.Results[0].IsGood() && res.Results[0] != ua.BadSubscriptionIDInvalid {
+		return fmt.Errorf("failed to delete subscription: %v", res.Results[0])
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package opcua
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+)
+
+// nextChange waits for a data change or fails the test
+func nextChange(t *testing.T, sub *Subscription) DataChange {
+	t.Helper()
+	select {
+	case change, ok := <-sub.Changes():
+		if !ok {
+			t.Fatalf("Subscription ended: %v", sub.Err())
+		}
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a data change")
+	}
+	return DataChange{}
+}
+
+func TestSubscribe_InitialValuesAndChanges(t *testing.T) {
+	sim, client := dialSimulator(t)
+
+	tags := simulatorTags[:3]
+	sub, err := client.Subscribe(context.Background(), tags, SubscriptionOptions{PublishingInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+
+	// The server reports the current value of every tag first
+	seen := map[int]bool{}
+	for len(seen) < len(tags) {
+		change := nextChange(t, sub)
+		if change.Err != nil {
+			t.Fatalf("%s: expected no error, got: %v", change.Tag.Name, change.Err)
+		}
+		if expected := simulatorValues[change.Tag.Name]; change.Value != expected {
+			t.Errorf("%s: expected %v, got %v", change.Tag.Name, expected, change.Value)
+		}
+		if change.Tag != tags[change.Index] {
+			t.Errorf("Index %d does not match tag %s", change.Index, change.Tag.Name)
+		}
+		seen[change.Index] = true
+	}
+
+	tag := tags[1]
+	if err := sim.SetValue(tag, int16(99)); err != nil {
+		t.Fatalf("SetValue failed: %v", err)
+	}
+	defer sim.SetValue(tag, simulatorValues[tag.Name])
+
+	change := nextChange(t, sub)
+	if change.Index != 1 || change.Value != int16(99) {
+		t.Errorf("Expected %s to change to 99, got %+v", tag.Name, change)
+	}
+	if change.SourceTimestamp.IsZero() {
+		t.Error("Expected a source timestamp")
+	}
+}
+
+func TestSubscribe_RejectedItem(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	tags := []model.OPCTag{
+		simulatorTags[0],
+		{Name: "Missing", NodeID: "ns=4;s=Plant.Missing", DataType: "INT"},
+	}
+	sub, err := client.Subscribe(context.Background(), tags, SubscriptionOptions{PublishingInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+
+	var rejected, delivered bool
+	for !rejected || !delivered {
+		change := nextChange(t, sub)
+		switch change.Index {
+		case 0:
+			delivered = change.Err == nil
+		case 1:
+			rejected = change.Err != nil
+		}
+	}
+}
+
+func TestSubscribe_OnePerClient(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	sub, err := client.Subscribe(context.Background(), simulatorTags[:1], SubscriptionOptions{})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, err := client.Subscribe(context.Background(), simulatorTags[:1], SubscriptionOptions{}); err == nil {
+		t.Error("Expected an error for a second subscription")
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	// The channel is closed once the subscription has ended
+	for range sub.Changes() {
+	}
+	if sub.Err() != nil {
+		t.Errorf("Expected no error after Close, got: %v", sub.Err())
+	}
+
+	sub, err = client.Subscribe(context.Background(), simulatorTags[:1], SubscriptionOptions{})
+	if err != nil {
+		t.Fatalf("Subscribe after Close failed: %v", err)
+	}
+	sub.Close()
+}
+
+func TestSubscribe_AgainAfterContextDone(t *testing.T) {
+	_, client := dialSimulator(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := client.Subscribe(ctx, simulatorTags[:1], SubscriptionOptions{})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	cancel()
+	for range sub.Changes() {
+	}
+
+	// The ended subscription no longer blocks a new one
+	sub, err = client.Subscribe(context.Background(), simulatorTags[:1], SubscriptionOptions{})
+	if err != nil {
+		t.Fatalf("Subscribe after the context was done failed: %v", err)
+	}
+	nextChange(t, sub)
+	sub.Close()
+}
+
+func TestSubscribe_Unsupported(t *testing.T) {
+	client := NewClientWithOPC(&MockOPCClient{})
+	if _, err := client.Subscribe(context.Background(), simulatorTags[:1], SubscriptionOptions{}); err == nil {
+		t.Error("Expected an error for a client without subscriptions")
+	}
+}
@@ -2,6 +2,9 @@ package parser
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -9,6 +12,13 @@ import (
 	"opcmss/internal/model"
 )
 
+// ParseTagsTSV reads a tab separated tag file with the columns Name,
+// RegisterType, Address, ModbusAddress, Size, Range and the optional DataType,
+// ByteOrder and Tolerance. Tags without a data type get the default for their
+// register type and size; tags without a byte order use the connection's and
+// tags without a tolerance use the configured rules. Rows may differ in how
+// many optional columns they fill, but an optional value that does not parse
+// is an error.
 func ParseTagsTSV(filename string) ([]model.ModbusTag, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -17,17 +27,21 @@ func ParseTagsTSV(filename string) ([]model.ModbusTag, error) {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
-	reader.Comma = '\t' // Tab-separated values
-
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
-	}
+	reader.Comma = '\t'         // Tab-separated values
+	reader.FieldsPerRecord = -1 // The optional columns may be left off
 
 	var tags []model.ModbusTag
-	for _, record := range records {
-		if len(record) < 1 {
-			continue // Skip empty records
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 6 {
+			return nil, fmt.Errorf("%s: line %d: wrong number of fields, expected at least 6, got %d", filename, line, len(record))
 		}
 
 		address, err := strconv.ParseUint(record[2], 10, 16)
@@ -54,6 +68,32 @@ func ParseTagsTSV(filename string) ([]model.ModbusTag, error) {
 			Range:         strings.TrimSpace(record[5]),
 		}
 
+		if len(record) > 6 && strings.TrimSpace(record[6]) != "" {
+			dataType, err := model.ParseDataType(record[6])
+			if err != nil {
+				return nil, fmt.Errorf("%s: line %d: %s: %w", filename, line, tag.Name, err)
+			}
+			tag.DataType = dataType
+		} else {
+			tag.DataType = model.DefaultDataType(tag.RegisterType, tag.Size)
+		}
+
+		if len(record) > 7 && strings.TrimSpace(record[7]) != "" {
+			byteOrder, err := model.ParseByteOrder(record[7])
+			if err != nil {
+				return nil, fmt.Errorf("%s: line %d: %s: %w", filename, line, tag.Name, err)
+			}
+			tag.ByteOrder = byteOrder
+		}
+
+		if len(record) > 8 && strings.TrimSpace(record[8]) != "" {
+			tolerance, err := model.ParseTolerance(record[8])
+			if err != nil {
+				return nil, fmt.Errorf("%s: line %d: %s: %w", filename, line, tag.Name, err)
+			}
+			tag.Tolerance = tolerance.String()
+		}
+
 		tags = append(tags, tag)
 	}
 
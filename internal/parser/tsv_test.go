@@ -38,6 +38,7 @@ Pressure	HoldingRegister	10	40010	1	10..10`
 		ModbusAddress: 40001,
 		Size:          2,
 		Range:         "1..2",
+		DataType:      "REAL",
 	}
 	if tags[0] != expectedTag1 {
 		t.Errorf("First tag incorrect. Expected: %+v, Got: %+v", expectedTag1, tags[0])
@@ -51,6 +52,7 @@ Pressure	HoldingRegister	10	40010	1	10..10`
 		ModbusAddress: 5,
 		Size:          1,
 		Range:         "5..5",
+		DataType:      "BOOL",
 	}
 	if tags[1] != expectedTag2 {
 		t.Errorf("Second tag incorrect. Expected: %+v, Got: %+v", expectedTag2, tags[1])
@@ -251,3 +253,162 @@ AnotherValidTag	Coil	5	00005	1	5..5`
 		}
 	}
 }
+
+func TestParseTagsTSV_DataTypeColumn(t *testing.T) {
+	// Optional seventh column with the IEC data type
+	tsv := `Counter	HoldingRegister	1	400001	2	1..2	dint
+Status	HoldingRegister	3	400003	1	3..3	WORD
+Name	HoldingRegister	4	400004	5	4..8	STRING[10]
+Level	HoldingRegister	9	400009	2	9..10	`
+
+	tmp := "test_data_types.tsv"
+	err := os.WriteFile(tmp, []byte(tsv), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp)
+
+	tags, err := ParseTagsTSV(tmp)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []string{"DINT", "WORD", "STRING[10]", "REAL"}
+	if len(tags) != len(expected) {
+		t.Fatalf("Expected %d tags, got %d", len(expected), len(tags))
+	}
+	for i, exp := range expected {
+		if tags[i].DataType != exp {
+			t.Errorf("Tag %s: expected data type %s, got %s", tags[i].Name, exp, tags[i].DataType)
+		}
+	}
+
+	// An unknown data type fails the file instead of dropping the tag
+	bogus := tsv + "\nBogus\tHoldingRegister\t11\t400011\t2\t11..12\tRAEL"
+	if err := os.WriteFile(tmp, []byte(bogus), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseTagsTSV(tmp)
+	if err == nil || !strings.Contains(err.Error(), "line 5") || !strings.Contains(err.Error(), "RAEL") {
+		t.Errorf("Expected an error naming line 5 and RAEL, got: %v", err)
+	}
+}
+
+func TestParseTagsTSV_UnevenOptionalColumns(t *testing.T) {
+	// Only some rows fill the optional columns
+	tsv := `Level	HoldingRegister	1	400001	2	1..2
+Counter	HoldingRegister	3	400003	2	3..4	DINT
+Flow	HoldingRegister	5	400005	2	5..6	REAL	CDAB	abs:0.1
+Pump	Coil	7	000007	1	7..7`
+
+	tmp := "test_uneven.tsv"
+	err := os.WriteFile(tmp, []byte(tsv), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp)
+
+	tags, err := ParseTagsTSV(tmp)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := []string{"REAL", "DINT", "REAL", "BOOL"}
+	if len(tags) != len(expected) {
+		t.Fatalf("Expected %d tags, got %d", len(expected), len(tags))
+	}
+	for i, exp := range expected {
+		if tags[i].DataType != exp {
+			t.Errorf("Tag %s: expected data type %s, got %s", tags[i].Name, exp, tags[i].DataType)
+		}
+	}
+	if tags[2].ByteOrder != "CDAB" || tags[2].Tolerance == "" {
+		t.Errorf("Expected Flow to keep its byte order and tolerance, got %+v", tags[2])
+	}
+
+	// Rows still need the six required columns
+	short := tsv + "\nShort\tHoldingRegister\t9"
+	if err := os.WriteFile(tmp, []byte(short), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseTagsTSV(tmp)
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("Expected an error naming line 5, got: %v", err)
+	}
+}
+
+func TestParseTagsTSV_ByteOrderColumn(t *testing.T) {
+	// Optional eighth column with the byte order, data type may be left empty
+	tsv := `Swapped	HoldingRegister	1	400001	2	1..2	REAL	cdab
+Default	HoldingRegister	3	400003	2	3..4		`
+
+	tmp := "test_byte_order.tsv"
+	err := os.WriteFile(tmp, []byte(tsv), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp)
+
+	tags, err := ParseTagsTSV(tmp)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(tags) != 2 {
+		t.Fatalf("Expected 2 tags, got %d", len(tags))
+	}
+	if tags[0].ByteOrder != "CDAB" {
+		t.Errorf("Expected byte order CDAB, got %q", tags[0].ByteOrder)
+	}
+	if tags[1].ByteOrder != "" || tags[1].DataType != "REAL" {
+		t.Errorf("Expected default byte order and REAL, got %q and %q", tags[1].ByteOrder, tags[1].DataType)
+	}
+
+	// An invalid byte order fails the file instead of dropping the tag
+	bogus := tsv + "\nBogus\tHoldingRegister\t5\t400005\t2\t5..6\tDINT\tACBD"
+	if err := os.WriteFile(tmp, []byte(bogus), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseTagsTSV(tmp)
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "ACBD") {
+		t.Errorf("Expected an error naming line 3 and ACBD, got: %v", err)
+	}
+}
+
+func TestParseTagsTSV_ToleranceColumn(t *testing.T) {
+	// Optional ninth column with the tolerance, earlier optional columns may be empty
+	tsv := `Level	HoldingRegister	1	400001	2	1..2	REAL		REL:0.5%
+Count	HoldingRegister	3	400003	1	3..3	INT		exact
+Default	HoldingRegister	4	400004	2	4..5	REAL	CDAB	`
+
+	tmp := "test_tolerance.tsv"
+	err := os.WriteFile(tmp, []byte(tsv), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp)
+
+	tags, err := ParseTagsTSV(tmp)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(tags) != 3 {
+		t.Fatalf("Expected 3 tags, got %d", len(tags))
+	}
+	want := []string{"rel:0.5%", "exact", ""}
+	for i, tag := range tags {
+		if tag.Tolerance != want[i] {
+			t.Errorf("%s: expected tolerance %q, got %q", tag.Name, want[i], tag.Tolerance)
+		}
+	}
+
+	// An invalid tolerance fails the file instead of dropping the tag
+	bogus := tsv + "\nBogus\tHoldingRegister\t6\t400006\t2\t6..7\tREAL\t\twithin:3"
+	if err := os.WriteFile(tmp, []byte(bogus), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseTagsTSV(tmp)
+	if err == nil || !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "within:3") {
+		t.Errorf("Expected an error naming line 4 and within:3, got: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"opcmss/internal/model"
+)
+
+// Issue describes a problem found in a tag map
+type Issue struct {
+	Tag     string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Tag, i.Message)
+}
+
+var knownRegisterTypes = map[string]bool{
+	"Coil":            true,
+	"DiscreteInput":   true,
+	"HoldingRegister": true,
+	"InputRegister":   true,
+}
+
+// ValidateTags checks a parsed tag map for duplicate names, unknown register
+// types, inconsistent ranges and overlapping addresses
+func ValidateTags(tags []model.ModbusTag) []Issue {
+	var issues []Issue
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		if tag.Name == "" {
+			issues = append(issues, Issue{Tag: fmt.Sprintf("(%s %d)", tag.RegisterType, tag.Address), Message: "empty tag name"})
+		} else if seen[tag.Name] {
+			issues = append(issues, Issue{Tag: tag.Name, Message: "duplicate tag name"})
+		}
+		seen[tag.Name] = true
+
+		knownType := knownRegisterTypes[tag.RegisterType]
+		if !knownType {
+			issues = append(issues, Issue{Tag: tag.Name, Message: fmt.Sprintf("unknown register type %q", tag.RegisterType)})
+		}
+
+		if tag.Size == 0 {
+			issues = append(issues, Issue{Tag: tag.Name, Message: "size is zero"})
+			continue
+		}
+
+		if tag.Address == 0 {
+			issues = append(issues, Issue{Tag: tag.Name, Message: "address must be 1-based"})
+		}
+
+		if knownType {
+			if issue := checkDataType(tag); issue != "" {
+				issues = append(issues, Issue{Tag: tag.Name, Message: issue})
+			}
+		}
+
+		expectedRange := fmt.Sprintf("%d..%d", tag.Address, uint32(tag.Address)+uint32(tag.Size)-1)
+		if tag.Range != expectedRange {
+			issues = append(issues, Issue{Tag: tag.Name, Message: fmt.Sprintf("range %q does not match address and size (expected %q)", tag.Range, expectedRange)})
+		}
+	}
+
+	return append(issues, findOverlaps(tags)...)
+}
+
+// checkDataType checks that the data type suits the register type and fits the size
+func checkDataType(tag model.ModbusTag) string {
+	dataType := tag.ResolvedDataType()
+	if dataType == "" {
+		return fmt.Sprintf("no data type given for %d registers", tag.Size)
+	}
+
+	if _, err := model.ParseDataType(dataType); err != nil {
+		return err.Error()
+	}
+
+	switch tag.RegisterType {
+	case "Coil", "DiscreteInput":
+		if dataType != model.TypeBOOL {
+			return fmt.Sprintf("%s cannot hold %s", tag.RegisterType, dataType)
+		}
+	case "HoldingRegister", "InputRegister":
+		count, _ := model.RegisterCount(dataType)
+		if model.IsString(dataType) && count > tag.Size {
+			return fmt.Sprintf("%s needs %d registers, size is %d", dataType, count, tag.Size)
+		}
+		if !model.IsString(dataType) && count != tag.Size {
+			return fmt.Sprintf("%s needs %d registers, size is %d", dataType, count, tag.Size)
+		}
+	}
+	return ""
+}
+
+// findOverlaps reports tags whose address ranges overlap within the same register type
+func findOverlaps(tags []model.ModbusTag) []Issue {
+	byType := make(map[string][]model.ModbusTag)
+	for _, tag := range tags {
+		if tag.Size > 0 {
+			byType[tag.RegisterType] = append(byType[tag.RegisterType], tag)
+		}
+	}
+
+	var types []string
+	for regType := range byType {
+		types = append(types, regType)
+	}
+	sort.Strings(types)
+
+	var issues []Issue
+	for _, regType := range types {
+		group := byType[regType]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Address < group[j].Address })
+
+		// Compare against the tag reaching furthest so far, so a wide tag
+		// is caught overlapping every tag inside it
+		widest := group[0]
+		for _, tag := range group[1:] {
+			end := uint32(widest.Address) + uint32(widest.Size)
+			if uint32(tag.Address) < end {
+				issues = append(issues, Issue{
+					Tag:     tag.Name,
+					Message: fmt.Sprintf("%s address %d overlaps %s (%s)", regType, tag.Address, widest.Name, widest.Range),
+				})
+			}
+			if uint32(tag.Address)+uint32(tag.Size) > end {
+				widest = tag
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func TestValidateTags_Clean(t *testing.T) {
+	tags := []model.ModbusTag{
+		{Name: "A", RegisterType: "Coil", Address: 1, Size: 1, Range: "1..1"},
+		{Name: "B", RegisterType: "HoldingRegister", Address: 1, Size: 2, Range: "1..2"},
+		{Name: "C", RegisterType: "HoldingRegister", Address: 3, Size: 1, Range: "3..3"},
+	}
+
+	if issues := ValidateTags(tags); len(issues) != 0 {
+		t.Errorf("Expected no issues, got: %v", issues)
+	}
+}
+
+func TestValidateTags_Problems(t *testing.T) {
+	tags := []model.ModbusTag{
+		{Name: "Wide", RegisterType: "HoldingRegister", Address: 1, Size: 10, Range: "1..10", DataType: "STRING[20]"},
+		{Name: "Inside", RegisterType: "HoldingRegister", Address: 2, Size: 1, Range: "2..2"},
+		{Name: "AlsoInside", RegisterType: "HoldingRegister", Address: 5, Size: 1, Range: "5..5"},
+		{Name: "Inside", RegisterType: "Coil", Address: 1, Size: 1, Range: "1..1"},
+		{Name: "BadRange", RegisterType: "Coil", Address: 2, Size: 1, Range: "2..3"},
+		{Name: "BadType", RegisterType: "Register", Address: 1, Size: 1, Range: "1..1"},
+		{Name: "ZeroSize", RegisterType: "Coil", Address: 9, Size: 0, Range: "9..9"},
+		{Name: "TooSmall", RegisterType: "InputRegister", Address: 1, Size: 2, Range: "1..2", DataType: "LREAL"},
+		{Name: "BoolCoil", RegisterType: "Coil", Address: 20, Size: 1, Range: "20..20", DataType: "INT"},
+		{Name: "Untyped", RegisterType: "InputRegister", Address: 3, Size: 3, Range: "3..5"},
+	}
+
+	issues := ValidateTags(tags)
+
+	expected := []string{
+		"Inside: duplicate tag name",
+		"BadRange: range \"2..3\" does not match address and size",
+		"BadType: unknown register type \"Register\"",
+		"ZeroSize: size is zero",
+		"TooSmall: LREAL needs 4 registers, size is 2",
+		"BoolCoil: Coil cannot hold INT",
+		"Untyped: no data type given for 3 registers",
+		"Inside: HoldingRegister address 2 overlaps Wide",
+		"AlsoInside: HoldingRegister address 5 overlaps Wide",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %d: %v", len(expected), len(issues), issues)
+	}
+	for i, exp := range expected {
+		if !strings.HasPrefix(issues[i].String(), exp) {
+			t.Errorf("Issue %d: expected prefix %q, got %q", i, exp, issues[i].String())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseValuesTSV reads a tab separated file of tag values with the columns
+// Name and Value. Empty lines and lines starting with # are ignored. Values
+// are kept as text, see converter.ParseValue.
+func ParseValuesTSV(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = '\t'
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(records))
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s: record %d: expected name and value, got %d fields", filename, i+1, len(record))
+		}
+		name := strings.TrimSpace(record[0])
+		if _, ok := values[name]; ok {
+			return nil, fmt.Errorf("%s: record %d: duplicate value for %s", filename, i+1, name)
+		}
+		values[name] = record[1]
+	}
+	return values, nil
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseValuesTSV(t *testing.T) {
+	tsv := "# name\tvalue\nTemperature\t21.5\nPumpStatus\ttrue\n\nLabel\tPUMP 1\n"
+
+	tmp := "test_values.tsv"
+	if err := os.WriteFile(tmp, []byte(tsv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp)
+
+	values, err := ParseValuesTSV(tmp)
+	if err != nil {
+		t.Fatalf("Error loading values: %v", err)
+	}
+
+	expected := map[string]string{"Temperature": "21.5", "PumpStatus": "true", "Label": "PUMP 1"}
+	if len(values) != len(expected) {
+		t.Fatalf("Expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, values[name])
+		}
+	}
+}
+
+func TestParseValuesTSV_Duplicate(t *testing.T) {
+	tmp := "test_values_duplicate.tsv"
+	if err := os.WriteFile(tmp, []byte("A\t1\nA\t2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp)
+
+	if _, err := ParseValuesTSV(tmp); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+}
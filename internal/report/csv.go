@@ -0,0 +1,47 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// CSVWriter writes one row per compared tag
+type CSVWriter struct{}
+
+func (CSVWriter) Write(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{
+		"name", "node_id", "data_type", "register_type", "address", "modbus_address",
+		"opc_value", "modbus_raw", "modbus_value", "status", "error",
+		"opc_read_at", "modbus_read_at", "opc_latency_ms", "modbus_latency_ms",
+		"opc_source_timestamp", "opc_server_timestamp", "attempts", "tolerance",
+	})
+	for _, res := range r.Results {
+		cw.Write([]string{
+			res.Name,
+			res.NodeID,
+			res.DataType,
+			res.RegisterType,
+			strconv.Itoa(int(res.Address)),
+			strconv.FormatUint(uint64(res.ModbusAddress), 10),
+			formatValue(res.OPCValue),
+			formatValue(res.ModbusRaw),
+			formatValue(res.ModbusValue),
+			string(res.Status),
+			res.Error(),
+			formatTime(res.OPCReadAt),
+			formatTime(res.ModbusReadAt),
+			strconv.FormatFloat(res.OPCLatency.Seconds()*1000, 'f', 3, 64),
+			strconv.FormatFloat(res.ModbusLatency.Seconds()*1000, 'f', 3, 64),
+			formatTime(res.OPCSourceTimestamp),
+			formatTime(res.OPCServerTimestamp),
+			strconv.Itoa(res.Attempts),
+			res.Tolerance,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"opcmss/internal/compare"
+)
+
+// JSONWriter writes the full report as indented JSON. JSON has no NaN or
+// infinity, so such values are written as the strings "NaN", "+Inf" and
+// "-Inf".
+type JSONWriter struct{}
+
+func (JSONWriter) Write(w io.Writer, r Report) error {
+	results := r.Results
+	r.Results = make([]compare.Result, len(results))
+	for i, result := range results {
+		result.OPCValue = jsonValue(result.OPCValue)
+		result.ModbusRaw = jsonValue(result.ModbusRaw)
+		result.ModbusValue = jsonValue(result.ModbusValue)
+		r.Results[i] = result
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// jsonValue replaces non-finite floats in a tag value, or in a slice of
+// them, by their string form
+func jsonValue(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case reflect.Slice, reflect.Array:
+		if k := rv.Type().Elem().Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return v
+		}
+		values := make([]any, rv.Len())
+		for i := range values {
+			values[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return values
+	}
+	return v
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"opcmss/internal/compare"
+)
+
+// JUnitWriter writes a JUnit XML test suite with one test case per tag, so
+// CI systems can gate on mismatches
+type JUnitWriter struct{}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func (JUnitWriter) Write(w io.Writer, r Report) error {
+	name := r.Name
+	if name == "" {
+		name = "opcmss"
+	}
+
+	suite := junitSuite{
+		Name:      name,
+		Tests:     len(r.Results),
+		Time:      fmt.Sprintf("%.3f", r.Summary.Duration.Seconds()),
+		Timestamp: formatTime(r.Summary.StartedAt),
+	}
+
+	for _, res := range r.Results {
+		tc := junitCase{
+			Name:      res.Name,
+			ClassName: res.RegisterType,
+			Time:      fmt.Sprintf("%.3f", (res.OPCLatency + res.ModbusLatency).Seconds()),
+		}
+
+		detail := fmt.Sprintf("NodeID: %s\nModbus address: %d\nOPC UA value: %s\nModbus value: %s (raw %s)\nTolerance: %s",
+			res.NodeID, res.ModbusAddress, formatValue(res.OPCValue), formatValue(res.ModbusValue), formatValue(res.ModbusRaw), res.Tolerance)
+
+		switch res.Status {
+		case compare.StatusMatch, compare.StatusTransient:
+		case compare.StatusMismatch:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: "values differ", Type: string(res.Status), Body: detail}
+		default:
+			suite.Errors++
+			tc.Error = &junitMessage{Message: res.Error(), Type: string(res.Status), Body: detail}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"opcmss/internal/compare"
+)
+
+// MarkdownWriter writes a summary and a table of all tags that did not match
+type MarkdownWriter struct{}
+
+func (MarkdownWriter) Write(w io.Writer, r Report) error {
+	var b strings.Builder
+
+	title := "Comparison report"
+	if r.Name != "" {
+		title += ": " + r.Name
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+
+	s := r.Summary
+	fmt.Fprintf(&b, "Started %s, took %s.\n\n", formatTime(s.StartedAt), s.Duration.Round(1e6))
+	if r.Interrupted {
+		fmt.Fprintf(&b, "The run was interrupted, only %d tags were compared.\n\n", s.Total)
+	}
+	fmt.Fprintf(&b, "| Total | Match | Mismatch | OPC UA errors | Modbus errors | Transient |\n")
+	fmt.Fprintf(&b, "|------:|------:|---------:|--------------:|--------------:|----------:|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d |\n\n", s.Total, s.Matches, s.Mismatches, s.OPCErrors, s.ModbusErrors, s.Transients)
+
+	var failed []compare.Result
+	for _, res := range r.Results {
+		if res.Failed() {
+			failed = append(failed, res)
+		}
+	}
+
+	if len(failed) == 0 {
+		b.WriteString("All tags match.\n")
+	} else {
+		b.WriteString("## Failed tags\n\n")
+		b.WriteString("| Tag | Modbus address | OPC UA value | Modbus value | Tolerance | Status | Error |\n")
+		b.WriteString("|-----|---------------:|--------------|--------------|-----------|--------|-------|\n")
+		for _, res := range failed {
+			fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %s | %s |\n",
+				escapeCell(res.Name), res.ModbusAddress, escapeCell(formatValue(res.OPCValue)),
+				escapeCell(formatValue(res.ModbusValue)), res.Tolerance, res.Status, escapeCell(res.Error()))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeCell keeps a value from breaking the table layout
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
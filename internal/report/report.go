@@ -0,0 +1,67 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"opcmss/internal/compare"
+)
+
+// Report is the outcome of one comparison run
+type Report struct {
+	Name    string           `json:"name"` // typically the config profile
+	Summary compare.Summary  `json:"summary"`
+	Results []compare.Result `json:"results"`
+
+	// Interrupted is set when the run was stopped before every selected tag
+	// was compared, the results cover the tags compared until then
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Writer renders a report in a single format
+type Writer interface {
+	Write(w io.Writer, r Report) error
+}
+
+var writers = map[string]Writer{
+	"json":     JSONWriter{},
+	"csv":      CSVWriter{},
+	"junit":    JUnitWriter{},
+	"markdown": MarkdownWriter{},
+}
+
+// NewWriter returns the writer for a format name
+func NewWriter(format string) (Writer, error) {
+	w, ok := writers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q (available: %v)", format, Formats())
+	}
+	return w, nil
+}
+
+// Formats returns the supported report format names
+func Formats() []string {
+	names := make([]string, 0, len(writers))
+	for name := range writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatValue renders a tag value for the text based formats
+func formatValue(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
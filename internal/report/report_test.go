@@ -0,0 +1,208 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"opcmss/internal/compare"
+)
+
+func testReport() Report {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	results := []compare.Result{
+		{Name: "Pump.Run", NodeID: "ns=4;s=Pump.Run", RegisterType: "Coil", ModbusAddress: 1,
+			OPCValue: true, ModbusRaw: true, ModbusValue: true, Status: compare.StatusMatch, OPCReadAt: now},
+		{Name: "Tank.Level", NodeID: "ns=4;s=Tank.Level", RegisterType: "HoldingRegister", ModbusAddress: 400010,
+			OPCValue: float32(12.5), ModbusRaw: []uint16{0x4148, 0}, ModbusValue: float32(12.5), Status: compare.StatusMismatch},
+		{Name: "Valve|Pos", NodeID: "ns=4;s=Valve", RegisterType: "HoldingRegister", ModbusAddress: 400012,
+			Status: compare.StatusOPCError, OPCError: "BadNodeIdUnknown"},
+	}
+	return Report{Name: "plant", Summary: compare.Summarize(results, now), Results: results}
+}
+
+func TestNewWriter(t *testing.T) {
+	for _, format := range Formats() {
+		if _, err := NewWriter(format); err != nil {
+			t.Errorf("Format %s: %v", format, err)
+		}
+	}
+	if _, err := NewWriter("pdf"); err == nil {
+		t.Error("Expected error for unknown format, got none")
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONWriter{}).Write(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Name    string
+		Summary struct{ Total, Mismatches int }
+		Results []struct {
+			Name     string `json:"name"`
+			Status   string `json:"status"`
+			OPCError string `json:"opc_error"`
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if decoded.Name != "plant" || decoded.Summary.Total != 3 || decoded.Summary.Mismatches != 1 {
+		t.Errorf("Unexpected report header: %+v", decoded)
+	}
+	if len(decoded.Results) != 3 || decoded.Results[2].OPCError != "BadNodeIdUnknown" {
+		t.Errorf("Unexpected results: %+v", decoded.Results)
+	}
+}
+
+func TestJSONWriter_NonFinite(t *testing.T) {
+	r := testReport()
+	r.Results[1].OPCValue = float32(math.NaN())
+	r.Results[1].ModbusValue = math.Inf(-1)
+	r.Results[1].ModbusRaw = []float64{1.5, math.Inf(1)}
+
+	var buf bytes.Buffer
+	if err := (JSONWriter{}).Write(&buf, r); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var decoded struct {
+		Results []struct {
+			OPCValue    any `json:"opc_value"`
+			ModbusRaw   any `json:"modbus_raw"`
+			ModbusValue any `json:"modbus_value"`
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	got := decoded.Results[1]
+	if got.OPCValue != "NaN" || got.ModbusValue != "-Inf" {
+		t.Errorf("Expected NaN and -Inf, got %v and %v", got.OPCValue, got.ModbusValue)
+	}
+	if raw, ok := got.ModbusRaw.([]any); !ok || len(raw) != 2 || raw[0] != 1.5 || raw[1] != "+Inf" {
+		t.Errorf("Expected [1.5 +Inf], got %v", got.ModbusRaw)
+	}
+
+	// The report itself is left untouched
+	if r.Results[1].ModbusValue != math.Inf(-1) {
+		t.Errorf("Expected the report to keep -Inf, got %v", r.Results[1].ModbusValue)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (CSVWriter{}).Write(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("Expected header and 3 rows, got %d", len(rows))
+	}
+	if rows[2][0] != "Tank.Level" || rows[2][9] != "mismatch" {
+		t.Errorf("Unexpected row: %v", rows[2])
+	}
+	if rows[3][10] != "OPC UA: BadNodeIdUnknown" {
+		t.Errorf("Expected error column, got %q", rows[3][10])
+	}
+}
+
+func TestJUnitWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JUnitWriter{}).Write(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("Expected one suite, got %d", len(suites.Suites))
+	}
+
+	suite := suites.Suites[0]
+	if suite.Name != "plant" || suite.Tests != 3 || suite.Failures != 1 || suite.Errors != 1 {
+		t.Errorf("Unexpected suite counts: %+v", suite)
+	}
+	if suite.Cases[0].Failure != nil || suite.Cases[0].Error != nil {
+		t.Error("Matching tag should pass")
+	}
+	if suite.Cases[1].Failure == nil {
+		t.Error("Mismatching tag should fail")
+	}
+	if suite.Cases[2].Error == nil || suite.Cases[2].Error.Message != "OPC UA: BadNodeIdUnknown" {
+		t.Errorf("Unreadable tag should be an error, got %+v", suite.Cases[2].Error)
+	}
+}
+
+func TestMarkdownWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (MarkdownWriter{}).Write(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "# Comparison report: plant\n") {
+		t.Errorf("Unexpected title: %q", strings.SplitN(out, "\n", 2)[0])
+	}
+	if !strings.Contains(out, "| 3 | 1 | 1 | 1 | 0 |") {
+		t.Error("Expected summary row")
+	}
+	if strings.Contains(out, "| Pump.Run |") {
+		t.Error("Matching tags should not be listed")
+	}
+	if !strings.Contains(out, "| Valve\\|Pos |") {
+		t.Error("Expected escaped tag name in failed table")
+	}
+}
+
+func TestMarkdownWriter_Interrupted(t *testing.T) {
+	r := testReport()
+	var buf bytes.Buffer
+	if err := (MarkdownWriter{}).Write(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "interrupted") {
+		t.Error("Expected no interruption note for a complete run")
+	}
+
+	r.Interrupted = true
+	buf.Reset()
+	if err := (MarkdownWriter{}).Write(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "The run was interrupted, only 3 tags were compared.") {
+		t.Errorf("Expected an interruption note, got:\n%s", buf.String())
+	}
+}
+
+func TestJUnitWriter_TransientPasses(t *testing.T) {
+	results := []compare.Result{{Name: "Flow", Status: compare.StatusTransient, Attempts: 2}}
+	r := Report{Summary: compare.Summarize(results, time.Now()), Results: results}
+
+	var buf bytes.Buffer
+	if err := (JUnitWriter{}).Write(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+	if suite := suites.Suites[0]; suite.Failures != 0 || suite.Errors != 0 {
+		t.Errorf("Transient mismatch should pass, got %+v", suite)
+	}
+}
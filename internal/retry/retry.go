@@ -0,0 +1,112 @@
+// Package retry holds the policies clients use to retry calls that failed
+// with a transient error and to reconnect after the connection dropped.
+package retry
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Backoff is a delay that doubles with every attempt, up to Max
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration // no limit when 0
+}
+
+// Delay returns the wait before retry n, starting at 0 for the first one
+func (b Backoff) Delay(n int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < n && delay > 0 && delay <= math.MaxInt64/2; i++ {
+		if b.Max > 0 && delay >= b.Max {
+			break
+		}
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// Policy decides how often a failed call is retried
+type Policy struct {
+	Attempts int // calls in total, 1 or less never retries
+	Backoff  Backoff
+}
+
+// Do calls fn until it succeeds, fails with an error transient rejects, the
+// attempts are used up or ctx is done, and returns the last error. A ctx that
+// is done before the first call returns ctx.Err() without calling fn.
+func (p Policy) Do(ctx context.Context, transient func(error) bool, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || !transient(err) || attempt+1 >= p.Attempts || ctx.Err() != nil {
+			return err
+		}
+		if !Sleep(ctx, p.Backoff.Delay(attempt)) {
+			return err
+		}
+	}
+}
+
+// Sleep pauses for d and reports whether it did so before ctx was done
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// State is the state of a client's connection
+type State string
+
+const (
+	StateConnected    State = "connected"
+	StateDisconnected State = "disconnected" // the connection failed
+	StateReconnecting State = "reconnecting" // a reconnect is being attempted
+)
+
+// StateFunc is called on every connection state change, with the error that
+// caused a disconnect
+type StateFunc func(state State, err error)
+
+// Recovery configures how a client recovers from failed calls
+type Recovery struct {
+	Read  Policy // reads and browses
+	Write Policy // writes, which set absolute values and are safe to repeat
+
+	// Reconnect spaces the attempts to reopen a failed connection. Calls
+	// made before the next attempt is due fail straight away.
+	Reconnect Backoff
+
+	OnState StateFunc // may be nil
+}
+
+// DefaultRecovery retries every call twice and reconnects with a backoff of
+// up to 30 seconds
+func DefaultRecovery() Recovery {
+	policy := Policy{Attempts: 3, Backoff: Backoff{Initial: 200 * time.Millisecond, Max: 2 * time.Second}}
+	return Recovery{
+		Read:      policy,
+		Write:     policy,
+		Reconnect: Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second},
+	}
+}
+
+// Notify reports a state change to OnState, if set
+func (r Recovery) Notify(state State, err error) {
+	if r.OnState != nil {
+		r.OnState(state, err)
+	}
+}
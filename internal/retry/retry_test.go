@@ -0,0 +1,85 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for n, ms := range expected {
+		if got := b.Delay(n); got != ms*time.Millisecond {
+			t.Errorf("Delay(%d): expected %dms, got %s", n, ms, got)
+		}
+	}
+
+	if got := (Backoff{Initial: time.Second}).Delay(40); got <= 0 {
+		t.Errorf("Expected an unlimited backoff to keep growing, got %s", got)
+	}
+	if got := (Backoff{}).Delay(3); got != 0 {
+		t.Errorf("Expected no delay without an initial one, got %s", got)
+	}
+}
+
+func TestPolicy_Do(t *testing.T) {
+	errTransient := errors.New("timeout")
+	errPermanent := errors.New("illegal address")
+	transient := func(err error) bool { return err == errTransient }
+
+	testCases := []struct {
+		name     string
+		attempts int
+		errs     []error // returned by successive calls, nil afterwards
+		calls    int
+		err      error
+	}{
+		{"success", 3, nil, 1, nil},
+		{"recovers", 3, []error{errTransient, errTransient}, 3, nil},
+		{"gives up", 3, []error{errTransient, errTransient, errTransient, errTransient}, 3, errTransient},
+		{"permanent", 3, []error{errPermanent}, 1, errPermanent},
+		{"no retries", 0, []error{errTransient}, 1, errTransient},
+	}
+
+	for _, tc := range testCases {
+		policy := Policy{Attempts: tc.attempts, Backoff: Backoff{Initial: time.Millisecond}}
+		calls := 0
+		err := policy.Do(context.Background(), transient, func() error {
+			calls++
+			if calls <= len(tc.errs) {
+				return tc.errs[calls-1]
+			}
+			return nil
+		})
+		if err != tc.err || calls != tc.calls {
+			t.Errorf("%s: expected %d calls and %v, got %d calls and %v", tc.name, tc.calls, tc.err, calls, err)
+		}
+	}
+}
+
+func TestPolicy_DoCancelled(t *testing.T) {
+	errTransient := errors.New("timeout")
+	transient := func(error) bool { return true }
+	policy := Policy{Attempts: 5, Backoff: Backoff{Initial: time.Hour}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	if err := policy.Do(ctx, transient, func() error { calls++; return nil }); !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Errorf("Expected no call and context.Canceled, got %d calls and %v", calls, err)
+	}
+
+	// A cancel during the backoff returns the last error straight away
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	err := policy.Do(ctx, transient, func() error { calls++; return errTransient })
+	if err != errTransient || calls != 1 {
+		t.Errorf("Expected one call and the transient error, got %d calls and %v", calls, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the backoff to end with the context, took %s", elapsed)
+	}
+}
@@ -0,0 +1,235 @@
+// Package roundtrip checks that a value written over one protocol can be
+// read back over the other, then restores the original value.
+package roundtrip
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"opcmss/internal/compare"
+	"opcmss/internal/model"
+	"opcmss/internal/retry"
+)
+
+// Direction is the protocol written with and the protocol read back with
+type Direction string
+
+const (
+	OPCToModbus Direction = "opc-to-modbus"
+	ModbusToOPC Direction = "modbus-to-opc"
+)
+
+// ParseDirections reads "both" or a single direction
+func ParseDirections(s string) ([]Direction, error) {
+	switch Direction(s) {
+	case "both":
+		return []Direction{OPCToModbus, ModbusToOPC}, nil
+	case OPCToModbus, ModbusToOPC:
+		return []Direction{Direction(s)}, nil
+	default:
+		return nil, fmt.Errorf("unknown direction %q (expected both, %s or %s)", s, OPCToModbus, ModbusToOPC)
+	}
+}
+
+// Status is the outcome of a round trip
+type Status string
+
+const (
+	StatusPass  Status = "pass"  // the test value showed up on the other side
+	StatusFail  Status = "fail"  // the other side never showed the test value
+	StatusError Status = "error" // a read or write failed
+)
+
+// OPCClient reads and writes tags over OPC UA
+type OPCClient interface {
+	ReadTag(ctx context.Context, tag model.OPCTag) (any, error)
+	WriteTag(ctx context.Context, tag model.OPCTag, value any) error
+}
+
+// ModbusClient reads and writes tags over Modbus
+type ModbusClient interface {
+	ReadTag(ctx context.Context, tag model.ModbusTag) (any, error)
+	WriteTag(ctx context.Context, tag model.ModbusTag, value any) error
+}
+
+// Tag is one tag of the tag map as seen by both protocols
+type Tag struct {
+	OPC    model.OPCTag
+	Modbus model.ModbusTag
+}
+
+// Writable reports whether the tag can be written over Modbus
+func (t Tag) Writable() bool {
+	return t.Modbus.RegisterType == "Coil" || t.Modbus.RegisterType == "HoldingRegister"
+}
+
+// Options controls how long a written value may take to show up
+type Options struct {
+	Timeout time.Duration // how long the other side may take to show a write
+	Poll    time.Duration // pause between reads of the other side
+}
+
+// DefaultOptions waits up to two seconds, reading every 50ms
+func DefaultOptions() Options {
+	return Options{Timeout: 2 * time.Second, Poll: 50 * time.Millisecond}
+}
+
+// Step is a planned round trip
+type Step struct {
+	Name      string    `json:"name"`
+	Direction Direction `json:"direction"`
+	Original  any       `json:"original"`
+	TestValue any       `json:"test_value"`
+}
+
+// Result is the outcome of a round trip
+type Result struct {
+	Step
+	Status   Status        `json:"status"`
+	Observed any           `json:"observed"`   // last value read on the other side
+	Latency  time.Duration `json:"latency_ns"` // from the write until the value was seen
+	Reads    int           `json:"reads"`      // reads of the other side until it was seen
+	Error    string        `json:"error,omitempty"`
+
+	// Restored is false when the original value could not be written back, or
+	// was not seen on the other side afterwards. After a failed write of the
+	// test value, it is also false when the tag could not be read to check.
+	Restored     bool   `json:"restored"`
+	RestoreError string `json:"restore_error,omitempty"`
+}
+
+// side reads and writes a tag over one protocol
+type side struct {
+	protocol string
+	read     func(ctx context.Context) (any, error)
+	write    func(ctx context.Context, value any) error
+}
+
+// Tester runs round trips between an OPC UA and a Modbus connection
+type Tester struct {
+	OPC    OPCClient
+	Modbus ModbusClient
+	Options
+}
+
+func (t Tester) sides(tag Tag, dir Direction) (from, to side) {
+	opc := side{
+		protocol: "OPC UA",
+		read:     func(ctx context.Context) (any, error) { return t.OPC.ReadTag(ctx, tag.OPC) },
+		write:    func(ctx context.Context, v any) error { return t.OPC.WriteTag(ctx, tag.OPC, v) },
+	}
+	mb := side{
+		protocol: "Modbus",
+		read:     func(ctx context.Context) (any, error) { return t.Modbus.ReadTag(ctx, tag.Modbus) },
+		write:    func(ctx context.Context, v any) error { return t.Modbus.WriteTag(ctx, tag.Modbus, v) },
+	}
+	if dir == ModbusToOPC {
+		return mb, opc
+	}
+	return opc, mb
+}
+
+// Plan reads the current value over the protocol that will be written and
+// picks a test value, without writing anything
+func (t Tester) Plan(ctx context.Context, tag Tag, dir Direction) (Step, error) {
+	from, _ := t.sides(tag, dir)
+	step := Step{Name: tag.OPC.Name, Direction: dir}
+
+	original, err := from.read(ctx)
+	if err != nil {
+		return step, fmt.Errorf("%s read failed: %w", from.protocol, err)
+	}
+	step.Original = original
+
+	step.TestValue, err = TestValue(tag.Modbus, original)
+	return step, err
+}
+
+// Run writes a test value over one protocol, waits for it on the other and
+// writes the original value back. The original is restored even when ctx is
+// done while waiting for the test value.
+func (t Tester) Run(ctx context.Context, tag Tag, dir Direction) Result {
+	step, err := t.Plan(ctx, tag, dir)
+	result := Result{Step: step, Status: StatusError}
+	if err != nil {
+		result.Error = err.Error()
+		result.Restored = true // nothing was written
+		return result
+	}
+
+	from, to := t.sides(tag, dir)
+	tolerance := compare.TagTolerance(tag.Modbus)
+
+	restore := context.WithoutCancel(ctx)
+	start := time.Now()
+	if err := from.write(ctx, step.TestValue); err != nil {
+		result.Error = fmt.Sprintf("%s write failed: %v", from.protocol, err)
+
+		// A failed write may still have reached the device, as when only
+		// its answer was lost, so check before calling the tag unchanged
+		current, err := from.read(restore)
+		switch {
+		case err != nil:
+			result.RestoreError = fmt.Sprintf("%s read failed: %v", from.protocol, err)
+		case compare.Within(tolerance, step.Original, current):
+			result.Restored = true
+		default:
+			t.restore(restore, from, to, tolerance, &result)
+		}
+		return result
+	}
+
+	seen, observed, reads, err := t.await(ctx, to, tolerance, step.TestValue)
+	result.Observed, result.Reads = observed, reads
+	switch {
+	case seen:
+		result.Status = StatusPass
+		result.Latency = time.Since(start)
+	case err != nil:
+		result.Error = fmt.Sprintf("%s read failed: %v", to.protocol, err)
+	default:
+		result.Status = StatusFail
+		result.Error = fmt.Sprintf("not seen on %s within %s", to.protocol, t.Timeout)
+	}
+
+	t.restore(restore, from, to, tolerance, &result)
+	return result
+}
+
+// restore writes the original value back and waits for it on the other side
+func (t Tester) restore(ctx context.Context, from, to side, tolerance model.Tolerance, result *Result) {
+	if err := from.write(ctx, result.Original); err != nil {
+		result.RestoreError = fmt.Sprintf("%s write failed: %v", from.protocol, err)
+		return
+	}
+	if seen, observed, _, err := t.await(ctx, to, tolerance, result.Original); !seen {
+		if err != nil {
+			result.RestoreError = fmt.Sprintf("%s read failed: %v", to.protocol, err)
+		} else {
+			result.RestoreError = fmt.Sprintf("%s still shows %v", to.protocol, observed)
+		}
+		return
+	}
+	result.Restored = true
+}
+
+// await reads a side until it shows the expected value or the timeout
+// passes. The error is the last read error, if the last read failed, or the
+// error of ctx once it is done.
+func (t Tester) await(ctx context.Context, s side, tolerance model.Tolerance, expected any) (seen bool, observed any, reads int, err error) {
+	deadline := time.Now().Add(t.Timeout)
+	for {
+		observed, err = s.read(ctx)
+		reads++
+		if err == nil && compare.Within(tolerance, expected, observed) {
+			return true, observed, reads, nil
+		}
+		if time.Now().After(deadline) {
+			return false, observed, reads, err
+		}
+		if !retry.Sleep(ctx, t.Poll) {
+			return false, observed, reads, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package roundtrip
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"opcmss/internal/model"
+)
+
+// symbolServer is a value store shared by both protocols. A write becomes
+// visible to the other protocol after lag reads.
+type symbolServer struct {
+	values  map[string]any
+	pending map[string]any
+	lag     int
+	reads   int
+
+	opcReadOnly bool // OPC UA writes are accepted but dropped
+	failWrites  int  // Modbus writes to fail, counted down
+}
+
+func newSymbolServer(values map[string]any) *symbolServer {
+	return &symbolServer{values: values, pending: map[string]any{}}
+}
+
+func (s *symbolServer) read(name string) any {
+	if v, ok := s.pending[name]; ok {
+		s.reads++
+		if s.reads > s.lag {
+			s.values[name] = v
+			delete(s.pending, name)
+		}
+	}
+	return s.values[name]
+}
+
+func (s *symbolServer) write(name string, value any) {
+	s.reads = 0
+	if s.lag == 0 {
+		s.values[name] = value
+		return
+	}
+	s.pending[name] = value
+}
+
+type fakeOPC struct{ *symbolServer }
+
+func (f fakeOPC) ReadTag(ctx context.Context, tag model.OPCTag) (any, error) {
+	return f.read(tag.Name), nil
+}
+
+func (f fakeOPC) WriteTag(ctx context.Context, tag model.OPCTag, value any) error {
+	if !f.opcReadOnly {
+		f.write(tag.Name, value)
+	}
+	return nil
+}
+
+type fakeModbus struct{ *symbolServer }
+
+func (f fakeModbus) ReadTag(ctx context.Context, tag model.ModbusTag) (any, error) {
+	return f.read(tag.Name), nil
+}
+
+func (f fakeModbus) WriteTag(ctx context.Context, tag model.ModbusTag, value any) error {
+	if f.failWrites > 0 {
+		f.failWrites--
+		return errors.New("illegal data address")
+	}
+	f.write(tag.Name, value)
+	return nil
+}
+
+func newTester(server *symbolServer) Tester {
+	return Tester{
+		OPC:     fakeOPC{server},
+		Modbus:  fakeModbus{server},
+		Options: Options{Timeout: 50 * time.Millisecond, Poll: time.Millisecond},
+	}
+}
+
+var levelTag = Tag{
+	OPC:    model.OPCTag{Name: "Level", DataType: "REAL"},
+	Modbus: model.ModbusTag{Name: "Level", RegisterType: "HoldingRegister", Address: 1, Size: 2, DataType: "REAL"},
+}
+
+func TestRun_Pass(t *testing.T) {
+	server := newSymbolServer(map[string]any{"Level": float32(21.5)})
+	server.lag = 3
+	tester := newTester(server)
+
+	for _, dir := range []Direction{OPCToModbus, ModbusToOPC} {
+		result := tester.Run(context.Background(), levelTag, dir)
+		if result.Status != StatusPass || !result.Restored {
+			t.Fatalf("%s: expected a restored pass, got %+v", dir, result)
+		}
+		if result.Original != float32(21.5) || result.TestValue != float32(22.5) || result.Observed != float32(22.5) {
+			t.Errorf("%s: unexpected values %+v", dir, result)
+		}
+		if result.Reads != 4 || result.Latency <= 0 {
+			t.Errorf("%s: expected 4 reads and a latency, got %d and %s", dir, result.Reads, result.Latency)
+		}
+	}
+	if server.values["Level"] != float32(21.5) {
+		t.Errorf("Expected the original value to be restored, got %v", server.values["Level"])
+	}
+}
+
+func TestRun_NotVisible(t *testing.T) {
+	server := newSymbolServer(map[string]any{"Level": float32(21.5)})
+	server.opcReadOnly = true
+
+	result := newTester(server).Run(context.Background(), levelTag, OPCToModbus)
+	if result.Status != StatusFail || result.Observed != float32(21.5) {
+		t.Errorf("Expected a fail with the unchanged value, got %+v", result)
+	}
+	// Writing the original back is seen straight away, so the tag counts as restored
+	if !result.Restored {
+		t.Errorf("Expected the tag to count as restored, got %s", result.RestoreError)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	server := newSymbolServer(map[string]any{"Level": float32(21.5)})
+	server.failWrites = 1
+
+	result := newTester(server).Run(context.Background(), levelTag, ModbusToOPC)
+	if result.Status != StatusError || result.Error == "" || !result.Restored {
+		t.Errorf("Expected a write error with nothing to restore, got %+v", result)
+	}
+
+	// The test write goes through but writing the original back fails
+	server.failWrites = 0
+	tester := newTester(server)
+	tester.Modbus = restoreFails{fakeModbus{server}, float32(21.5)}
+	result = tester.Run(context.Background(), levelTag, ModbusToOPC)
+	if result.Status != StatusPass || result.Restored || result.RestoreError == "" {
+		t.Errorf("Expected a pass that was not restored, got %+v", result)
+	}
+}
+
+func TestRun_FailedWriteThatWentThrough(t *testing.T) {
+	server := newSymbolServer(map[string]any{"Level": float32(21.5)})
+	tester := newTester(server)
+	lost := &lostAnswer{fakeModbus: fakeModbus{server}, original: float32(21.5)}
+	tester.Modbus = lost
+
+	result := tester.Run(context.Background(), levelTag, ModbusToOPC)
+	if result.Status != StatusError || !result.Restored {
+		t.Errorf("Expected a write error that was restored, got %+v", result)
+	}
+	if server.values["Level"] != float32(21.5) {
+		t.Errorf("Expected the original value to be written back, got %v", server.values["Level"])
+	}
+
+	// Without a way to tell what the write did, the tag is not called restored
+	lost.failed, lost.readErr = false, errors.New("connection reset")
+	result = tester.Run(context.Background(), levelTag, ModbusToOPC)
+	if result.Status != StatusError || result.Restored || result.RestoreError == "" {
+		t.Errorf("Expected a write error that was not restored, got %+v", result)
+	}
+}
+
+// lostAnswer applies writes of a test value but reports them as failed, like
+// a device whose answer was lost. Once a write failed, reads fail with readErr.
+type lostAnswer struct {
+	fakeModbus
+	original any
+	readErr  error
+	failed   bool
+}
+
+func (l *lostAnswer) ReadTag(ctx context.Context, tag model.ModbusTag) (any, error) {
+	if l.failed && l.readErr != nil {
+		return nil, l.readErr
+	}
+	return l.fakeModbus.ReadTag(ctx, tag)
+}
+
+func (l *lostAnswer) WriteTag(ctx context.Context, tag model.ModbusTag, value any) error {
+	if err := l.fakeModbus.WriteTag(ctx, tag, value); err != nil || value == l.original {
+		return err
+	}
+	l.failed = true
+	return errors.New("gateway timeout")
+}
+
+// restoreFails fails every write of the original value
+type restoreFails struct {
+	fakeModbus
+	original any
+}
+
+func (r restoreFails) WriteTag(ctx context.Context, tag model.ModbusTag, value any) error {
+	if value == r.original {
+		return errors.New("gateway timeout")
+	}
+	return r.fakeModbus.WriteTag(ctx, tag, value)
+}
+
+// cancelOnRead cancels the run on its first read
+type cancelOnRead struct {
+	fakeModbus
+	cancel context.CancelFunc
+}
+
+func (c cancelOnRead) ReadTag(ctx context.Context, tag model.ModbusTag) (any, error) {
+	c.cancel()
+	return c.fakeModbus.ReadTag(ctx, tag)
+}
+
+func TestRun_CancelledStillRestores(t *testing.T) {
+	server := newSymbolServer(map[string]any{"Level": float32(21.5)})
+	server.lag = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tester := newTester(server)
+	tester.Modbus = cancelOnRead{fakeModbus{server}, cancel}
+
+	result := tester.Run(ctx, levelTag, OPCToModbus)
+	if result.Status != StatusError || !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Expected the cancel to end the wait with an error, got %+v", result)
+	}
+	if !result.Restored || server.values["Level"] != float32(21.5) {
+		t.Errorf("Expected the original value to be restored, got %v: %s", server.values["Level"], result.RestoreError)
+	}
+}
+
+func TestTestValue(t *testing.T) {
+	str := model.ModbusTag{RegisterType: "HoldingRegister", Size: 2, DataType: "STRING[4]"}
+
+	testCases := []struct {
+		tag      model.ModbusTag
+		original any
+		expected any
+	}{
+		{model.ModbusTag{}, true, false},
+		{model.ModbusTag{}, []bool{true, false}, []bool{false, true}},
+		{model.ModbusTag{}, int16(32767), int16(32766)},
+		{model.ModbusTag{}, uint16(0), uint16(1)},
+		{model.ModbusTag{}, int64(-5), int64(-4)},
+		{model.ModbusTag{}, float32(1.5), float32(2.5)},
+		{model.ModbusTag{}, float32(1e10), float32(5e9)},
+		{model.ModbusTag{}, float64(-0.5), float64(0.5)},
+		{str, "PUMP", "ROUN"},
+		{str, "ROUN", "roun"},
+	}
+	for _, tc := range testCases {
+		got, err := TestValue(tc.tag, tc.original)
+		if err != nil || !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("TestValue(%v): expected %v (%T), got %v (%T), %v", tc.original, tc.expected, tc.expected, got, got, err)
+		}
+	}
+
+	if _, err := TestValue(model.ModbusTag{}, struct{}{}); err == nil {
+		t.Error("Expected an error for an unsupported type")
+	}
+}
+
+func TestParseDirections(t *testing.T) {
+	dirs, err := ParseDirections("both")
+	if err != nil || len(dirs) != 2 {
+		t.Errorf("Expected both directions, got %v, %v", dirs, err)
+	}
+	if _, err := ParseDirections("sideways"); err == nil {
+		t.Error("Expected an error for an unknown direction")
+	}
+}
@@ -0,0 +1,82 @@
+package roundtrip
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"opcmss/internal/model"
+)
+
+// TestValue picks a value of the same type as original that differs from it
+// and is valid for the tag: booleans are inverted, numbers step by one away
+// from the end of their range and strings are replaced by a marker.
+func TestValue(tag model.ModbusTag, original any) (any, error) {
+	switch v := original.(type) {
+	case bool:
+		return !v, nil
+	case []bool:
+		flipped := make([]bool, len(v))
+		for i, b := range v {
+			flipped[i] = !b
+		}
+		return flipped, nil
+	case int16:
+		return step(v, math.MaxInt16), nil
+	case uint16:
+		return step(v, math.MaxUint16), nil
+	case int32:
+		return step(v, math.MaxInt32), nil
+	case uint32:
+		return step(v, math.MaxUint32), nil
+	case int64:
+		return step(v, math.MaxInt64), nil
+	case uint64:
+		return step(v, math.MaxUint64), nil
+	case float32:
+		return float32(stepFloat(float64(v))), nil
+	case float64:
+		return stepFloat(v), nil
+	case string:
+		return testString(tag, v)
+	default:
+		return nil, fmt.Errorf("cannot pick a test value for %T", original)
+	}
+}
+
+// step adds one, or subtracts one at the top of the range
+func step[T int16 | uint16 | int32 | uint32 | int64 | uint64](v, top T) T {
+	if v == top {
+		return v - 1
+	}
+	return v + 1
+}
+
+func stepFloat(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 1
+	}
+	if next := v + 1; next != v && !math.IsInf(next, 0) && float64(float32(next)) != float64(float32(v)) {
+		return next
+	}
+	// Adding one is lost in the precision of large values
+	return v / 2
+}
+
+// testString returns a marker that fits the tag's STRING[n] length
+func testString(tag model.ModbusTag, original string) (any, error) {
+	length, err := model.StringLength(tag.ResolvedDataType())
+	if err != nil || length == 0 {
+		return nil, fmt.Errorf("cannot pick a test value for %s", tag.ResolvedDataType())
+	}
+
+	for _, marker := range []string{"ROUNDTRIP", "roundtrip"} {
+		if len(marker) > length {
+			marker = marker[:length]
+		}
+		if marker != strings.TrimRight(original, "\x00") {
+			return marker, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot pick a test value for %q", original)
+}
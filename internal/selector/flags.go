@@ -0,0 +1,57 @@
+package selector
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+// Flags binds the tag selection flags of a command
+type Flags struct {
+	opts     Options
+	types    string
+	addrText string
+	seedSet  bool
+}
+
+// BindFlags registers the selection flags on fs
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.opts.Mode, "mode", ModeEven, "sampling mode: all, even, random or nth")
+	fs.IntVar(&f.opts.Every, "every", 10, "step for -mode nth")
+	fs.IntVar(&f.opts.Offset, "offset", 0, "first tag index for -mode nth")
+	fs.Func("seed", "random seed for -mode random (default: time based)", func(s string) error {
+		f.seedSet = true
+		return parseInt64(s, &f.opts.Seed)
+	})
+	fs.StringVar(&f.opts.NameGlob, "name", "", "only tags whose name matches this glob pattern")
+	fs.StringVar(&f.opts.NameRegex, "regex", "", "only tags whose name matches this regular expression")
+	fs.StringVar(&f.types, "type", "", "only these register types (comma separated)")
+	fs.StringVar(&f.addrText, "address", "", "only tags whose start address is in <min>..<max>")
+	return f
+}
+
+// Options returns the selection options, using count for the modes that take one
+func (f *Flags) Options(count int) (Options, error) {
+	opts := f.opts
+	opts.Count = count
+
+	if !f.seedSet {
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	if f.types != "" {
+		for _, t := range strings.Split(f.types, ",") {
+			opts.RegisterTypes = append(opts.RegisterTypes, strings.TrimSpace(t))
+		}
+	}
+
+	if f.addrText != "" {
+		lo, hi, err := ParseAddressRange(f.addrText)
+		if err != nil {
+			return Options{}, err
+		}
+		opts.AddressMin, opts.AddressMax = lo, hi
+	}
+	return opts, nil
+}
@@ -0,0 +1,185 @@
+package selector
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"opcmss/internal/model"
+)
+
+// Sampling modes
+const (
+	ModeAll    = "all"    // every tag that passes the filter
+	ModeEven   = "even"   // Count evenly spaced tags
+	ModeRandom = "random" // Count random tags, reproducible with Seed
+	ModeNth    = "nth"    // every Nth tag starting at Offset
+)
+
+// Filter narrows the tag map before sampling. Zero values match everything.
+type Filter struct {
+	NameGlob      string   // shell-style pattern matched against the tag name
+	NameRegex     string   // regular expression matched against the tag name
+	RegisterTypes []string // e.g. "Coil", "HoldingRegister"
+	AddressMin    uint16   // lowest start address (inclusive)
+	AddressMax    uint16   // highest start address (inclusive), 0 means no limit
+}
+
+// Options selects a subset of the tag map
+type Options struct {
+	Filter
+	Mode   string
+	Count  int   // number of tags for ModeEven and ModeRandom
+	Every  int   // step for ModeNth
+	Offset int   // first index for ModeNth
+	Seed   int64 // random seed for ModeRandom
+}
+
+// Select returns the indexes of the selected tags in ascending order
+func Select(tags []model.ModbusTag, opts Options) ([]int, error) {
+	candidates, err := filter(tags, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Mode {
+	case ModeAll, "":
+		return candidates, nil
+
+	case ModeEven:
+		if opts.Count < 1 {
+			return nil, fmt.Errorf("count must be at least 1, got %d", opts.Count)
+		}
+		// Calculate step size for even spacing
+		step := len(candidates) / opts.Count
+		if step < 1 {
+			step = 1
+		}
+		var selected []int
+		for i := 0; i < opts.Count && i*step < len(candidates); i++ {
+			selected = append(selected, candidates[i*step])
+		}
+		return selected, nil
+
+	case ModeRandom:
+		if opts.Count < 1 {
+			return nil, fmt.Errorf("count must be at least 1, got %d", opts.Count)
+		}
+		rng := rand.New(rand.NewSource(opts.Seed))
+		perm := rng.Perm(len(candidates))
+		if opts.Count < len(perm) {
+			perm = perm[:opts.Count]
+		}
+		selected := make([]int, len(perm))
+		for i, p := range perm {
+			selected[i] = candidates[p]
+		}
+		sort.Ints(selected)
+		return selected, nil
+
+	case ModeNth:
+		if opts.Every < 1 {
+			return nil, fmt.Errorf("every must be at least 1, got %d", opts.Every)
+		}
+		if opts.Offset < 0 {
+			return nil, fmt.Errorf("offset must not be negative, got %d", opts.Offset)
+		}
+		var selected []int
+		for i := opts.Offset; i < len(candidates); i += opts.Every {
+			selected = append(selected, candidates[i])
+		}
+		return selected, nil
+
+	default:
+		return nil, fmt.Errorf("unknown sampling mode %q", opts.Mode)
+	}
+}
+
+func filter(tags []model.ModbusTag, f Filter) ([]int, error) {
+	if f.NameGlob != "" {
+		if _, err := path.Match(f.NameGlob, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", f.NameGlob, err)
+		}
+	}
+
+	var re *regexp.Regexp
+	if f.NameRegex != "" {
+		var err error
+		re, err = regexp.Compile(f.NameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name regex: %w", err)
+		}
+	}
+
+	var indexes []int
+	for i, tag := range tags {
+		if f.NameGlob != "" {
+			if ok, _ := path.Match(f.NameGlob, tag.Name); !ok {
+				continue
+			}
+		}
+		if re != nil && !re.MatchString(tag.Name) {
+			continue
+		}
+		if len(f.RegisterTypes) > 0 && !containsFold(f.RegisterTypes, tag.RegisterType) {
+			continue
+		}
+		if tag.Address < f.AddressMin {
+			continue
+		}
+		if f.AddressMax != 0 && tag.Address > f.AddressMax {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAddressRange parses an address range such as "100..200", "100.." or "..200"
+func ParseAddressRange(s string) (lo, hi uint16, err error) {
+	loText, hiText, ok := strings.Cut(s, "..")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid address range %q, expected <min>..<max>", s)
+	}
+
+	if loText != "" {
+		v, err := strconv.ParseUint(strings.TrimSpace(loText), 10, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid address range %q: %w", s, err)
+		}
+		lo = uint16(v)
+	}
+	if hiText != "" {
+		v, err := strconv.ParseUint(strings.TrimSpace(hiText), 10, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid address range %q: %w", s, err)
+		}
+		hi = uint16(v)
+	}
+	if hi != 0 && hi < lo {
+		return 0, 0, fmt.Errorf("invalid address range %q: max is below min", s)
+	}
+	return lo, hi, nil
+}
+
+func parseInt64(s string, dst *int64) error {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
@@ -0,0 +1,124 @@
+package selector
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"opcmss/internal/model"
+)
+
+func testTags() []model.ModbusTag {
+	var tags []model.ModbusTag
+	for i := 1; i <= 10; i++ {
+		regType := "HoldingRegister"
+		if i%2 == 0 {
+			regType = "Coil"
+		}
+		tags = append(tags, model.ModbusTag{
+			Name:         fmt.Sprintf("Area%d.Tag%d", i%3, i),
+			RegisterType: regType,
+			Address:      uint16(i * 10),
+			Size:         1,
+		})
+	}
+	return tags
+}
+
+func TestSelect_Modes(t *testing.T) {
+	tags := testTags()
+
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected []int
+	}{
+		{"all", Options{Mode: ModeAll}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"even", Options{Mode: ModeEven, Count: 3}, []int{0, 3, 6}},
+		{"even more than available", Options{Mode: ModeEven, Count: 20}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"nth", Options{Mode: ModeNth, Every: 4, Offset: 1}, []int{1, 5, 9}},
+		{"type filter", Options{Mode: ModeAll, Filter: Filter{RegisterTypes: []string{"coil"}}}, []int{1, 3, 5, 7, 9}},
+		{"glob filter", Options{Mode: ModeAll, Filter: Filter{NameGlob: "Area1.*"}}, []int{0, 3, 6, 9}},
+		{"regex filter", Options{Mode: ModeAll, Filter: Filter{NameRegex: `Tag(2|10)$`}}, []int{1, 9}},
+		{"address range", Options{Mode: ModeAll, Filter: Filter{AddressMin: 30, AddressMax: 50}}, []int{2, 3, 4}},
+		{"filter then sample", Options{Mode: ModeNth, Every: 2, Filter: Filter{RegisterTypes: []string{"Coil"}}}, []int{1, 5, 9}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Select(tags, tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSelect_RandomIsReproducible(t *testing.T) {
+	tags := testTags()
+	opts := Options{Mode: ModeRandom, Count: 4, Seed: 42}
+
+	first, err := Select(tags, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	second, _ := Select(tags, opts)
+
+	if len(first) != 4 {
+		t.Fatalf("Expected 4 tags, got %d", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Same seed gave different selections: %v vs %v", first, second)
+	}
+	for i := 1; i < len(first); i++ {
+		if first[i] <= first[i-1] {
+			t.Errorf("Expected ascending unique indexes, got %v", first)
+		}
+	}
+}
+
+func TestSelect_Errors(t *testing.T) {
+	tags := testTags()
+
+	for _, opts := range []Options{
+		{Mode: "bogus"},
+		{Mode: ModeEven},
+		{Mode: ModeNth},
+		{Mode: ModeNth, Every: 2, Offset: -1},
+		{Mode: ModeAll, Filter: Filter{NameRegex: "("}},
+		{Mode: ModeAll, Filter: Filter{NameGlob: "["}},
+	} {
+		if _, err := Select(tags, opts); err == nil {
+			t.Errorf("Expected error for %+v, got none", opts)
+		}
+	}
+}
+
+func TestParseAddressRange(t *testing.T) {
+	testCases := []struct {
+		input  string
+		lo, hi uint16
+		valid  bool
+	}{
+		{"100..200", 100, 200, true},
+		{"100..", 100, 0, true},
+		{"..200", 0, 200, true},
+		{"200..100", 0, 0, false},
+		{"100", 0, 0, false},
+		{"a..b", 0, 0, false},
+	}
+
+	for _, tc := range testCases {
+		lo, hi, err := ParseAddressRange(tc.input)
+		if (err == nil) != tc.valid {
+			t.Errorf("%q: expected valid=%v, got err=%v", tc.input, tc.valid, err)
+			continue
+		}
+		if lo != tc.lo || hi != tc.hi {
+			t.Errorf("%q: expected %d..%d, got %d..%d", tc.input, tc.lo, tc.hi, lo, hi)
+		}
+	}
+}
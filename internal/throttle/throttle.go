@@ -0,0 +1,84 @@
+// Package throttle bounds the load put on a server: how many requests are
+// sent per second and how much work runs at the same time.
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces requests evenly so that no more than a given number start
+// per second. A nil Limiter does not limit. It is safe for concurrent use, so
+// clients talking to the same device can share one.
+type Limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // when the next request may start
+}
+
+// NewLimiter returns a Limiter allowing perSecond requests per second, or nil
+// when perSecond is 0 or less
+func NewLimiter(perSecond float64) *Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &Limiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the next request may start, or returns ctx.Err() when
+// ctx is done first. The turn is used up either way.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Each calls fn for every index from 0 to n-1 on up to workers goroutines and
+// returns once all calls returned. Less than one worker counts as one.
+func Each(n, workers int, fn func(i int)) {
+	workers = max(1, min(workers, n))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter_SpacesRequests(t *testing.T) {
+	limiter := NewLimiter(100)
+
+	start := time.Now()
+	for range 5 {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	// The first request starts at once, the other four 10ms apart
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected 5 requests to take at least 40ms, took %s", elapsed)
+	}
+}
+
+func TestLimiter_NoLimit(t *testing.T) {
+	limiter := NewLimiter(0)
+	if limiter != nil {
+		t.Fatalf("Expected no limiter for a rate of 0, got %+v", limiter)
+	}
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Errorf("Expected a nil limiter not to wait, got %v", err)
+	}
+}
+
+func TestLimiter_Cancelled(t *testing.T) {
+	limiter := NewLimiter(1)
+	limiter.Wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the wait to end early, took %s", elapsed)
+	}
+}
+
+func TestEach_BoundsWorkers(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	done := make([]bool, 20)
+
+	Each(len(done), 3, func(i int) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		done[i] = true
+		mu.Unlock()
+	})
+
+	if peak > 3 {
+		t.Errorf("Expected at most 3 calls at the same time, got %d", peak)
+	}
+	for i, ok := range done {
+		if !ok {
+			t.Errorf("Expected index %d to be processed", i)
+		}
+	}
+}
+
+func TestEach_Empty(t *testing.T) {
+	Each(0, 0, func(i int) { t.Errorf("Unexpected call for index %d", i) })
+}
@@ -0,0 +1,140 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Alerter delivers alert events
+type Alerter interface {
+	Alert(e Event) error
+}
+
+// AlertKinds lists the alert targets NewAlerter understands
+var AlertKinds = []string{"stderr", "log=<file>", "webhook=<url>", "exec=<command>"}
+
+// NewAlerter creates an alerter from a spec of the form kind or kind=target,
+// see AlertKinds
+func NewAlerter(spec string) (Alerter, error) {
+	kind, target, _ := strings.Cut(spec, "=")
+	switch kind {
+	case "stderr":
+		return WriterAlerter{W: os.Stderr}, nil
+	case "log":
+		if target == "" {
+			return nil, fmt.Errorf("log alert needs a file, e.g. log=alerts.log")
+		}
+		return NewLogAlerter(target)
+	case "webhook":
+		if target == "" {
+			return nil, fmt.Errorf("webhook alert needs a URL, e.g. webhook=http://localhost:8080/alerts")
+		}
+		return WebhookAlerter{URL: target, Client: &http.Client{Timeout: 5 * time.Second}}, nil
+	case "exec":
+		command := strings.Fields(target)
+		if len(command) == 0 {
+			return nil, fmt.Errorf("exec alert needs a command, e.g. exec=./notify.sh")
+		}
+		return ExecAlerter{Command: command, Timeout: 30 * time.Second}, nil
+	default:
+		return nil, fmt.Errorf("unknown alert %q (available: %s)", spec, strings.Join(AlertKinds, ", "))
+	}
+}
+
+// WriterAlerter prints one line per event
+type WriterAlerter struct {
+	W io.Writer
+}
+
+func (a WriterAlerter) Alert(e Event) error {
+	_, err := fmt.Fprintf(a.W, "%s %s\n", e.At.Format(time.TimeOnly), e)
+	return err
+}
+
+// LogAlerter appends timestamped events to a file
+type LogAlerter struct {
+	logger *log.Logger
+}
+
+// NewLogAlerter opens path for appending, creating it if needed. The file
+// stays open for the life of the process.
+func NewLogAlerter(path string) (*LogAlerter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open alert log: %w", err)
+	}
+	return &LogAlerter{logger: log.New(file, "", log.LstdFlags)}, nil
+}
+
+func (a *LogAlerter) Alert(e Event) error {
+	a.logger.Println(e)
+	return nil
+}
+
+// WebhookAlerter posts each event as JSON
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (a WebhookAlerter) Alert(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.Client.Post(a.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s returned %s", a.URL, resp.Status)
+	}
+	return nil
+}
+
+// ExecAlerter runs a command for each event. The event is passed as JSON on
+// stdin and summarised in OPCMSS_ALERT_* environment variables.
+type ExecAlerter struct {
+	Command []string
+	Timeout time.Duration
+}
+
+func (a ExecAlerter) Alert(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, a.Command[0], a.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"OPCMSS_ALERT_KIND="+string(e.Kind),
+		"OPCMSS_ALERT_TAG="+e.Result.Name,
+		"OPCMSS_ALERT_STATUS="+string(e.Result.Status),
+		"OPCMSS_ALERT_MESSAGE="+e.String(),
+		fmt.Sprintf("OPCMSS_ALERT_OPC_VALUE=%v", e.Result.OPCValue),
+		fmt.Sprintf("OPCMSS_ALERT_MODBUS_VALUE=%v", e.Result.ModbusValue),
+		fmt.Sprintf("OPCMSS_ALERT_DURATION=%s", e.Duration.Round(time.Second)),
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %s: %w: %s", a.Command[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
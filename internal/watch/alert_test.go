@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opcmss/internal/compare"
+)
+
+func testEvent() Event {
+	since := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	return Event{
+		Kind:     EventRaised,
+		Result:   compare.Result{Name: "Level", Status: compare.StatusMismatch, OPCValue: float32(1.5), ModbusValue: float32(2)},
+		Since:    since,
+		At:       since.Add(42 * time.Second),
+		Duration: 42 * time.Second,
+	}
+}
+
+func TestNewAlerter(t *testing.T) {
+	valid := []string{"stderr", "webhook=http://localhost:9000/alerts", "exec=./notify.sh --loud", "log=" + filepath.Join(t.TempDir(), "alerts.log")}
+	for _, spec := range valid {
+		if _, err := NewAlerter(spec); err != nil {
+			t.Errorf("%s: expected no error, got: %v", spec, err)
+		}
+	}
+
+	invalid := []string{"", "email=ops@example.com", "webhook", "exec=", "log"}
+	for _, spec := range invalid {
+		if _, err := NewAlerter(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestWriterAlerter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (WriterAlerter{W: &buf}).Alert(testEvent()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := "08:00:42 ALERT Level: values differ for 42s (OPC UA 1.5, Modbus 2)\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWebhookAlerter(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	alerter := WebhookAlerter{URL: server.URL, Client: server.Client()}
+	if err := alerter.Alert(testEvent()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if received.Kind != EventRaised || received.Result.Name != "Level" || received.Duration != 42*time.Second {
+		t.Errorf("Unexpected payload: %+v", received)
+	}
+}
+
+func TestWebhookAlerter_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	alerter := WebhookAlerter{URL: server.URL, Client: server.Client()}
+	if err := alerter.Alert(testEvent()); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected a 500 error, got: %v", err)
+	}
+}
+
+func TestExecAlerter(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert.txt")
+	alerter := ExecAlerter{
+		Command: []string{"sh", "-c", `echo "$OPCMSS_ALERT_KIND $OPCMSS_ALERT_TAG $OPCMSS_ALERT_DURATION" > "$0"; cat >> "$0"`, out},
+		Timeout: 5 * time.Second,
+	}
+	if err := alerter.Alert(testEvent()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, payload, _ := strings.Cut(string(data), "\n")
+	if first != "raised Level 42s" {
+		t.Errorf("Unexpected environment: %q", first)
+	}
+	var received Event
+	if err := json.Unmarshal([]byte(payload), &received); err != nil {
+		t.Errorf("Expected the event as JSON on stdin, got %q: %v", payload, err)
+	}
+}
+
+func TestLogAlerter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.log")
+	alerter, err := NewLogAlerter(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := alerter.Alert(testEvent()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ALERT Level: values differ for 42s") {
+		t.Errorf("Unexpected log: %q", data)
+	}
+}
@@ -0,0 +1,109 @@
+// Package watch tracks how long tags stay mismatched across repeated
+// comparisons and raises alerts for mismatches that persist.
+package watch
+
+import (
+	"fmt"
+	"time"
+
+	"opcmss/internal/compare"
+)
+
+// EventKind tells whether an alert starts or ends
+type EventKind string
+
+const (
+	EventRaised   EventKind = "raised"
+	EventResolved EventKind = "resolved"
+)
+
+// Event is an alert about one tag
+type Event struct {
+	Kind     EventKind      `json:"kind"`
+	Result   compare.Result `json:"result"`      // the comparison that triggered the event
+	Since    time.Time      `json:"since"`       // first failed comparison in a row
+	At       time.Time      `json:"at"`          // when the event was raised
+	Duration time.Duration  `json:"duration_ns"` // how long the tag has been failing
+}
+
+func (e Event) String() string {
+	r := e.Result
+	switch {
+	case e.Kind == EventResolved:
+		return fmt.Sprintf("RESOLVED %s: matches again after %s", r.Name, e.Duration.Round(time.Second))
+	case r.Status == compare.StatusMismatch:
+		return fmt.Sprintf("ALERT %s: values differ for %s (OPC UA %v, Modbus %v)",
+			r.Name, e.Duration.Round(time.Second), r.OPCValue, r.ModbusValue)
+	default:
+		return fmt.Sprintf("ALERT %s: %s for %s (%s)", r.Name, r.Status, e.Duration.Round(time.Second), r.Error())
+	}
+}
+
+// Tracker remembers since when each tag has been failing. A tag is failing
+// when its comparison is anything but a match, including read errors.
+type Tracker struct {
+	debounce time.Duration
+	states   map[string]*tagState
+}
+
+type tagState struct {
+	result  compare.Result
+	since   time.Time
+	alerted bool
+}
+
+// NewTracker creates a tracker that alerts once a tag has been failing for
+// at least debounce. A zero debounce alerts on the first failure.
+func NewTracker(debounce time.Duration) *Tracker {
+	return &Tracker{
+		debounce: debounce,
+		states:   make(map[string]*tagState),
+	}
+}
+
+// Update records the results of one comparison cycle taken at now and
+// returns the alerts it raises or resolves
+func (t *Tracker) Update(results []compare.Result, now time.Time) []Event {
+	var events []Event
+	for _, result := range results {
+		key := resultKey(result)
+		state, failing := t.states[key]
+
+		if !result.Failed() {
+			if failing && state.alerted {
+				events = append(events, Event{Kind: EventResolved, Result: result, Since: state.since, At: now, Duration: now.Sub(state.since)})
+			}
+			delete(t.states, key)
+			continue
+		}
+
+		if !failing {
+			state = &tagState{since: now}
+			t.states[key] = state
+		}
+		state.result = result
+
+		if !state.alerted && now.Sub(state.since) >= t.debounce {
+			state.alerted = true
+			events = append(events, Event{Kind: EventRaised, Result: result, Since: state.since, At: now, Duration: now.Sub(state.since)})
+		}
+	}
+	return events
+}
+
+// Failing returns the number of tags currently failing and how many of them
+// have been alerted on
+func (t *Tracker) Failing() (failing, alerted int) {
+	for _, state := range t.states {
+		if state.alerted {
+			alerted++
+		}
+	}
+	return len(t.states), alerted
+}
+
+// resultKey identifies a tag across cycles. Tag files may reuse names, so the
+// address is part of the key.
+func resultKey(r compare.Result) string {
+	return fmt.Sprintf("%s@%s:%d", r.Name, r.RegisterType, r.Address)
+}
@@ -0,0 +1,91 @@
+package watch
+
+import (
+	"testing"
+	"time"
+
+	"opcmss/internal/compare"
+)
+
+func result(name string, status compare.Status) compare.Result {
+	return compare.Result{Name: name, RegisterType: "HoldingRegister", Address: 1, Status: status}
+}
+
+func TestTracker_Debounce(t *testing.T) {
+	tracker := NewTracker(10 * time.Second)
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		offset   time.Duration
+		status   compare.Status
+		expected []EventKind
+	}{
+		{0, compare.StatusMismatch, nil},
+		{5 * time.Second, compare.StatusMismatch, nil},
+		{10 * time.Second, compare.StatusMismatch, []EventKind{EventRaised}},
+		{15 * time.Second, compare.StatusMismatch, nil}, // raised only once
+		{20 * time.Second, compare.StatusMatch, []EventKind{EventResolved}},
+		{25 * time.Second, compare.StatusMatch, nil},
+	}
+
+	for i, step := range steps {
+		events := tracker.Update([]compare.Result{result("Level", step.status)}, start.Add(step.offset))
+		if len(events) != len(step.expected) {
+			t.Fatalf("Step %d: expected %v, got %+v", i, step.expected, events)
+		}
+		for j, kind := range step.expected {
+			if events[j].Kind != kind {
+				t.Errorf("Step %d: expected %s, got %s", i, kind, events[j].Kind)
+			}
+		}
+	}
+}
+
+func TestTracker_ShortMismatchIsNotAlerted(t *testing.T) {
+	tracker := NewTracker(10 * time.Second)
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	tracker.Update([]compare.Result{result("Level", compare.StatusMismatch)}, start)
+	if events := tracker.Update([]compare.Result{result("Level", compare.StatusMatch)}, start.Add(5*time.Second)); len(events) != 0 {
+		t.Errorf("Expected no events, got %+v", events)
+	}
+
+	// The next mismatch starts a new debounce period
+	tracker.Update([]compare.Result{result("Level", compare.StatusMismatch)}, start.Add(8*time.Second))
+	if events := tracker.Update([]compare.Result{result("Level", compare.StatusMismatch)}, start.Add(12*time.Second)); len(events) != 0 {
+		t.Errorf("Expected no events, got %+v", events)
+	}
+}
+
+func TestTracker_ErrorsCountAsFailing(t *testing.T) {
+	tracker := NewTracker(0)
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	events := tracker.Update([]compare.Result{
+		result("A", compare.StatusModbusError),
+		result("B", compare.StatusMatch),
+		result("C", compare.StatusMismatch),
+	}, now)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", events)
+	}
+
+	failing, alerted := tracker.Failing()
+	if failing != 2 || alerted != 2 {
+		t.Errorf("Expected 2 failing and 2 alerted, got %d and %d", failing, alerted)
+	}
+}
+
+func TestTracker_SameNameDifferentAddress(t *testing.T) {
+	tracker := NewTracker(0)
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	a := result("Dup", compare.StatusMismatch)
+	b := result("Dup", compare.StatusMatch)
+	b.Address = 2
+
+	tracker.Update([]compare.Result{a, b}, now)
+	if failing, _ := tracker.Failing(); failing != 1 {
+		t.Errorf("Expected 1 failing tag, got %d", failing)
+	}
+}